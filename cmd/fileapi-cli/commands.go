@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+func (c *cli) uploadCommand() *cobra.Command {
+	var (
+		remotePath string
+		recursive  bool
+		overwrite  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "upload LOCAL... [--path PATH]",
+		Short: "Upload files, or directories with --recursive, to a path of the user",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user, err := c.requireUser()
+			if err != nil {
+				return err
+			}
+
+			var uploaded []*client.File
+			for _, local := range args {
+				info, err := os.Stat(local)
+				if err != nil {
+					return err
+				}
+
+				if !info.IsDir() {
+					result, err := c.uploadFile(cmd, user, local, remotePath, overwrite)
+					if err != nil {
+						return err
+					}
+
+					uploaded = append(uploaded, result)
+					continue
+				}
+
+				if !recursive {
+					return fmt.Errorf("%s is a directory, use --recursive to upload it", local)
+				}
+
+				// the directory itself is uploaded under the path, like cp -r
+				root := filepath.Clean(local)
+				err = filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+					if err != nil || entry.IsDir() {
+						return err
+					}
+
+					dir, err := filepath.Rel(filepath.Dir(root), filepath.Dir(name))
+					if err != nil {
+						return err
+					}
+
+					result, err := c.uploadFile(cmd, user, name, joinPath(remotePath, filepath.ToSlash(dir)), overwrite)
+					if err != nil {
+						return err
+					}
+
+					uploaded = append(uploaded, result)
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if c.json {
+				return c.printJSON(cmd, uploaded)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&remotePath, "path", "", "path to upload to")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "upload directories and everything under them")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace files that already exist")
+	return cmd
+}
+
+func (c *cli) uploadFile(cmd *cobra.Command, user int, local, remotePath string, overwrite bool) (*client.File, error) {
+	content, err := os.Open(local)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	info, err := content.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(local)
+	result, err := c.api.Upload(cmd.Context(), client.UploadInput{User: user, Path: remotePath, Name: name, Overwrite: overwrite},
+		withProgress(content, name, info.Size(), c.showProgress()))
+	if err != nil {
+		return nil, fmt.Errorf("uploading %s: %w", local, err)
+	}
+
+	if !c.json {
+		c.printf(cmd, "%s -> %s (%s)\n", local, result.FullPath(), result.ID)
+	}
+
+	return result, nil
+}
+
+func (c *cli) downloadCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "download ID [LOCAL]",
+		Short: "Download a file to LOCAL, or to the current directory with its own name",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := c.api.Get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			local := result.Name
+			if len(args) == 2 {
+				local = args[1]
+				if info, err := os.Stat(local); err == nil && info.IsDir() {
+					local = filepath.Join(local, result.Name)
+				}
+			}
+
+			content, err := c.api.Content(cmd.Context(), result)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			out, err := os.Create(local)
+			if err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(out, withProgress(content, result.Name, result.Size, c.showProgress())); err != nil {
+				out.Close()
+				return err
+			}
+
+			if err := out.Close(); err != nil {
+				return err
+			}
+
+			if c.json {
+				return c.printJSON(cmd, map[string]interface{}{"file": result, "local": local})
+			}
+
+			c.printf(cmd, "%s -> %s\n", result.FullPath(), local)
+			return nil
+		},
+	}
+}
+
+func (c *cli) lsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls [PREFIX]",
+		Short: "List the files of the user under a path prefix",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := c.listFiles(cmd, args)
+			if err != nil {
+				return err
+			}
+
+			if c.json {
+				return c.printJSON(cmd, files)
+			}
+
+			writer := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "ID\tSIZE\tUPDATED\tPATH")
+			for _, f := range files {
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", f.ID, formatSize(f.Size), f.UpdatedAt.Format("2006-01-02 15:04"), f.FullPath())
+			}
+
+			return writer.Flush()
+		},
+	}
+}
+
+func (c *cli) listFiles(cmd *cobra.Command, args []string) ([]*client.File, error) {
+	user, err := c.requireUser()
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix string
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+
+	files, err := c.api.List(cmd.Context(), user, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].FullPath() < files[j].FullPath() })
+	return files, nil
+}
+
+// dir is a directory of the tree, shaped like the Dir type of the graphql schema
+type dir struct {
+	Path  string         `json:"path"`
+	Files []*client.File `json:"files"`
+	Dirs  []*dir         `json:"dirs"`
+}
+
+func (c *cli) treeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tree [PREFIX]",
+		Short: "Show the files of the user under a path prefix as a tree",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := c.listFiles(cmd, args)
+			if err != nil {
+				return err
+			}
+
+			root := buildTree(files)
+			if c.json {
+				return c.printJSON(cmd, root)
+			}
+
+			c.printf(cmd, "%d\n", c.profile.User)
+			printTree(cmd.OutOrStdout(), root, "")
+			return nil
+		},
+	}
+}
+
+// buildTree groups files into nested directories by their path
+func buildTree(files []*client.File) *dir {
+	root := &dir{Files: []*client.File{}, Dirs: []*dir{}}
+	dirs := map[string]*dir{"": root}
+
+	var find func(p string) *dir
+	find = func(p string) *dir {
+		if d, ok := dirs[p]; ok {
+			return d
+		}
+
+		parent := ""
+		if i := strings.LastIndex(p, "/"); i >= 0 {
+			parent = p[:i]
+		}
+
+		d := &dir{Path: p, Files: []*client.File{}, Dirs: []*dir{}}
+		dirs[p] = d
+		find(parent).Dirs = append(find(parent).Dirs, d)
+		return d
+	}
+
+	for _, f := range files {
+		d := find(strings.Trim(f.Path, "/"))
+		d.Files = append(d.Files, f)
+	}
+
+	return root
+}
+
+func printTree(out io.Writer, d *dir, indent string) {
+	type entry struct {
+		name string
+		dir  *dir
+	}
+
+	var entries []entry
+	for _, sub := range d.Dirs {
+		entries = append(entries, entry{name: path.Base(sub.Path) + "/", dir: sub})
+	}
+	for _, f := range d.Files {
+		entries = append(entries, entry{name: f.Name})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	for i, e := range entries {
+		branch, next := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		fmt.Fprintln(out, indent+branch+e.name)
+		if e.dir != nil {
+			printTree(out, e.dir, indent+next)
+		}
+	}
+}
+
+func (c *cli) mvCommand() *cobra.Command {
+	var overwrite bool
+	cmd := &cobra.Command{
+		Use:   "mv ID NEWPATH",
+		Short: "Move a file to a new path, given with its name, of the user",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			user := c.profile.User
+			if user == 0 {
+				// keep the owner when no user is given
+				current, err := c.api.Get(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+
+				user = current.User
+			}
+
+			result, err := c.api.Move(cmd.Context(), client.MoveInput{ID: args[0], User: user, NewPath: args[1], Overwrite: overwrite})
+			if err != nil {
+				return err
+			}
+
+			return c.printFile(cmd, result)
+		},
+	}
+
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace the file on the new path if it exists")
+	return cmd
+}
+
+// cpCommand copies by downloading and uploading the file again, as the api has no copy
+func (c *cli) cpCommand() *cobra.Command {
+	var overwrite bool
+	cmd := &cobra.Command{
+		Use:   "cp ID PATH",
+		Short: "Copy a file to a path of the user, keeping its name",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, err := c.api.Get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			user := c.profile.User
+			if user == 0 {
+				user = source.User
+			}
+
+			content, err := c.api.Content(cmd.Context(), source)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+
+			result, err := c.api.Upload(cmd.Context(), client.UploadInput{User: user, Path: args[1], Name: source.Name, ContentType: source.FileType, Overwrite: overwrite},
+				withProgress(content, source.Name, source.Size, c.showProgress()))
+			if err != nil {
+				return err
+			}
+
+			return c.printFile(cmd, result)
+		},
+	}
+
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace the file on the path if it exists")
+	return cmd
+}
+
+func (c *cli) rmCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rm ID...",
+		Short: "Delete files",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, id := range args {
+				if err := c.api.Delete(cmd.Context(), id); err != nil {
+					return fmt.Errorf("deleting %s: %w", id, err)
+				}
+
+				if !c.json {
+					c.printf(cmd, "deleted %s\n", id)
+				}
+			}
+
+			if c.json {
+				return c.printJSON(cmd, map[string]interface{}{"deleted": args})
+			}
+
+			return nil
+		},
+	}
+}
+
+func (c *cli) shareCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "share ID",
+		Short: "Print the link anyone can download a file from",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := c.api.Get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			if c.json {
+				return c.printJSON(cmd, map[string]string{"id": result.ID, "url": result.DownloadURL})
+			}
+
+			c.printf(cmd, "%s\n", result.DownloadURL)
+			return nil
+		},
+	}
+}
+
+func (c *cli) printFile(cmd *cobra.Command, f *client.File) error {
+	if c.json {
+		return c.printJSON(cmd, f)
+	}
+
+	c.printf(cmd, "%s (%s)\n", f.FullPath(), f.ID)
+	return nil
+}
+
+// joinPath joins remote path parts with slashes, skipping empty ones
+func joinPath(parts ...string) string {
+	var result []string
+	for _, part := range parts {
+		if part = strings.Trim(part, "/"); part != "" {
+			result = append(result, part)
+		}
+	}
+
+	return strings.Join(result, "/")
+}
@@ -0,0 +1,118 @@
+// fileapi-cli uploads, downloads and manages files through the graphql api
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+// cli has the global flags and the profile they resolve to
+type cli struct {
+	configPath  string
+	profileName string
+	url         string
+	token       string
+	user        int
+	json        bool
+	quiet       bool
+
+	profile profile
+	api     *client.Client
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	c := &cli{}
+	root := &cobra.Command{
+		Use:          "fileapi-cli",
+		Short:        "Upload, download and manage files on the file api",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return c.load(cmd)
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&c.configPath, "config", defaultConfigPath(), "config file with the profiles")
+	flags.StringVarP(&c.profileName, "profile", "p", os.Getenv("FILEAPI_PROFILE"), "profile to use, the one set on the config file by default")
+	flags.StringVar(&c.url, "url", "", "graphql endpoint, overrides the profile")
+	flags.StringVar(&c.token, "token", os.Getenv("FILEAPI_TOKEN"), "admin token, overrides the profile")
+	flags.IntVarP(&c.user, "user", "u", 0, "user that owns the files, overrides the profile")
+	flags.BoolVar(&c.json, "json", false, "print results as json")
+	flags.BoolVarP(&c.quiet, "quiet", "q", false, "do not show progress bars")
+
+	root.AddCommand(
+		c.uploadCommand(),
+		c.downloadCommand(),
+		c.lsCommand(),
+		c.treeCommand(),
+		c.mvCommand(),
+		c.cpCommand(),
+		c.rmCommand(),
+		c.shareCommand(),
+		c.syncCommand(),
+	)
+
+	return root
+}
+
+// load resolves the profile with the flags set on cmd
+func (c *cli) load(cmd *cobra.Command) error {
+	p, err := loadProfile(c.configPath, c.profileName)
+	if err != nil {
+		return err
+	}
+
+	if c.url != "" {
+		p.URL = c.url
+	}
+	if c.token != "" {
+		p.Token = c.token
+	}
+	if cmd.Flags().Changed("user") {
+		p.User = c.user
+	}
+
+	c.profile = p
+	c.api = client.New(p.URL, client.WithToken(p.Token))
+	return nil
+}
+
+// requireUser returns the user of the profile, which is needed to upload and list files
+func (c *cli) requireUser() (int, error) {
+	if c.profile.User == 0 {
+		return 0, errors.New("user is not set, use --user or set it on the profile")
+	}
+
+	return c.profile.User, nil
+}
+
+// showProgress tells whether progress bars are drawn, they are not when printing json for piping
+func (c *cli) showProgress() bool {
+	return !c.quiet && !c.json
+}
+
+func (c *cli) printJSON(cmd *cobra.Command, v interface{}) error {
+	encoder := json.NewEncoder(cmd.OutOrStdout())
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (c *cli) printf(cmd *cobra.Command, format string, args ...interface{}) {
+	fmt.Fprintf(cmd.OutOrStdout(), format, args...)
+}
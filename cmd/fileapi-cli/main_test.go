@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/client"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/http"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	handler, err := http.NewServer(serviceMock)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("profile: test\nprofiles:\n  test:\n    url: "+server.URL+"/graphql/\n    user: 1\n"), 0o600))
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		root := newRootCommand()
+		root.SetOut(&out)
+		root.SetErr(io.Discard)
+		root.SetArgs(append([]string{"--config", configPath}, args...))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	files := []*entity.File{
+		{ID: "1/docs/a.txt", User: 1, Path: "docs", Name: "a.txt", Size: 5, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: "1/docs/img/b.png", User: 1, Path: "docs/img", Name: "b.png", Size: 7, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+
+	t.Run("upload recursive", func(t *testing.T) {
+		local := filepath.Join(dir, "docs")
+		require.NoError(t, os.MkdirAll(filepath.Join(local, "img"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(local, "a.txt"), []byte("hello"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(local, "img", "b.png"), []byte("picture"), 0o600))
+
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 5, "a.txt", "backup/docs", "text/plain; charset=utf-8", gomock.Any(), false).
+			DoAndReturn(func(_, _, _, _, _, _ interface{}, content io.Reader, _ bool) (*entity.File, error) {
+				read, _ := io.ReadAll(content)
+				require.Equal(t, "hello", string(read))
+				return files[0], nil
+			})
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 7, "b.png", "backup/docs/img", "image/png", gomock.Any(), false).Return(files[1], nil)
+
+		out, err := run("upload", "-r", local, "--path", "backup", "--json")
+		require.NoError(t, err)
+
+		var uploaded []client.File
+		require.NoError(t, json.Unmarshal([]byte(out), &uploaded))
+		require.Len(t, uploaded, 2)
+		require.Equal(t, "docs/img", uploaded[1].Path)
+	})
+
+	t.Run("upload directory without recursive", func(t *testing.T) {
+		_, err := run("upload", dir)
+		require.ErrorContains(t, err, "--recursive")
+	})
+
+	t.Run("ls", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return(files, nil)
+
+		out, err := run("ls", "docs")
+		require.NoError(t, err)
+		require.Contains(t, out, "docs/img/b.png")
+		require.Contains(t, out, "7 B")
+	})
+
+	t.Run("tree", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "").Return(files, nil)
+
+		out, err := run("tree")
+		require.NoError(t, err)
+		require.Equal(t, "1\n└── docs/\n    ├── a.txt\n    └── img/\n        └── b.png\n", out)
+	})
+
+	t.Run("mv keeps the user of the profile", func(t *testing.T) {
+		serviceMock.EXPECT().Move(gomock.Any(), 1, "1/docs/a.txt", "other/a.txt", true).Return(files[0], nil)
+
+		_, err := run("mv", "MS9kb2NzL2EudHh0", "other/a.txt", "--overwrite")
+		require.NoError(t, err)
+	})
+
+	t.Run("rm shows the error code", func(t *testing.T) {
+		serviceMock.EXPECT().Delete(gomock.Any(), "1/docs/a.txt").Return(service.ErrNotFound)
+
+		_, err := run("rm", "MS9kb2NzL2EudHh0")
+		require.ErrorContains(t, err, "NOT_FOUND")
+	})
+
+	t.Run("share", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), "1/docs/a.txt").Return(files[0], nil)
+
+		out, err := run("share", "MS9kb2NzL2EudHh0")
+		require.NoError(t, err)
+		require.True(t, strings.HasSuffix(strings.TrimSpace(out), "/1/docs/a.txt"))
+	})
+
+	t.Run("sync uploads and deletes", func(t *testing.T) {
+		local := filepath.Join(dir, "site")
+		require.NoError(t, os.MkdirAll(local, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(local, "new.txt"), []byte("new"), 0o600))
+
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return(files, nil).Times(2)
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 3, "new.txt", "docs", gomock.Any(), gomock.Any(), true).Return(files[0], nil)
+		serviceMock.EXPECT().Delete(gomock.Any(), "1/docs/a.txt").Return(nil)
+		serviceMock.EXPECT().Delete(gomock.Any(), "1/docs/img/b.png").Return(nil)
+
+		out, err := run("sync", local, "1:docs", "--delete", "--dry-run")
+		require.NoError(t, err)
+		require.Equal(t, "(dry run) delete a.txt\n(dry run) delete img/b.png\n(dry run) upload new.txt\n", out)
+
+		out, err = run("sync", local, "1:docs", "--delete", "--json")
+		require.NoError(t, err)
+
+		var steps []syncStep
+		require.NoError(t, json.Unmarshal([]byte(out), &steps))
+		require.Len(t, steps, 3)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := run("--profile", "missing", "ls")
+		require.ErrorContains(t, err, `profile "missing" not found`)
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+const defaultURL = "https://rubbioli.com/fileapi/graphql/"
+
+// profile is where the api is and who to send requests as
+type profile struct {
+	URL   string `mapstructure:"url"`
+	Token string `mapstructure:"token"`
+	User  int    `mapstructure:"user"`
+}
+
+// loadProfile reads the profile named name from the config file in the format
+//
+//	profile: staging # used when no profile is given
+//	profiles:
+//	  staging:
+//	    url: https://staging.example.com/graphql/
+//	    token: secret
+//	    user: 1
+//
+// a missing config file or profile name gives the default profile, which uses the public api
+func loadProfile(path, name string) (profile, error) {
+	config := viper.New()
+	config.SetConfigFile(path)
+	if err := config.ReadInConfig(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return profile{}, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if name == "" {
+		name = config.GetString("profile")
+	}
+
+	result := profile{URL: defaultURL}
+	if name == "" {
+		return result, nil
+	}
+
+	if !config.IsSet("profiles." + name) {
+		return profile{}, fmt.Errorf("profile %q not found on %s", name, path)
+	}
+
+	if err := config.UnmarshalKey("profiles."+name, &result); err != nil {
+		return profile{}, fmt.Errorf("reading profile %q: %w", name, err)
+	}
+
+	if result.URL == "" {
+		result.URL = defaultURL
+	}
+
+	return result, nil
+}
+
+// defaultConfigPath is ~/.config/fileapi/config.yaml, or the FILEAPI_CONFIG environment variable
+func defaultConfigPath() string {
+	if path := os.Getenv("FILEAPI_CONFIG"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "fileapi.yaml"
+	}
+
+	return filepath.Join(dir, "fileapi", "config.yaml")
+}
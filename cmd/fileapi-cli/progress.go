@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	progressWidth    = 30
+	progressInterval = 100 * time.Millisecond
+)
+
+// progress draws a bar of how much of total was read on out while reading from reader
+type progress struct {
+	reader io.Reader
+	out    io.Writer
+	name   string
+	total  int64
+	read   int64
+	drawn  time.Time
+}
+
+// withProgress shows the progress of reading r on stderr when it is a terminal and show is set
+func withProgress(r io.Reader, name string, total int64, show bool) io.Reader {
+	if !show || !isTerminal(os.Stderr) {
+		return r
+	}
+
+	return &progress{reader: r, out: os.Stderr, name: name, total: total}
+}
+
+func (p *progress) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+
+	if err == io.EOF {
+		p.draw()
+		fmt.Fprintln(p.out)
+	} else if time.Since(p.drawn) > progressInterval {
+		p.draw()
+	}
+
+	return n, err
+}
+
+func (p *progress) draw() {
+	p.drawn = time.Now()
+	ratio := 1.0
+	if p.total > 0 {
+		ratio = float64(p.read) / float64(p.total)
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+
+	done := int(ratio * progressWidth)
+	fmt.Fprintf(p.out, "\r%-30.30s [%s%s] %3.0f%% %s/%s", p.name, strings.Repeat("=", done),
+		strings.Repeat(" ", progressWidth-done), ratio*100, formatSize(p.read), formatSize(p.total))
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
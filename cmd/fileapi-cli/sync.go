@@ -0,0 +1,402 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type syncAction string
+
+const (
+	actionUpload   syncAction = "upload"
+	actionDownload syncAction = "download"
+	actionDelete   syncAction = "delete"
+)
+
+// remoteDir is a path prefix of a user, written as "user:prefix"
+type remoteDir struct {
+	user   int
+	prefix string
+}
+
+// localFile is a file under the local directory being synced
+type localFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// syncStep is what sync does, or would do on a dry run, to a file relative to both directories
+type syncStep struct {
+	Action syncAction `json:"action"`
+	Path   string     `json:"path"`
+	Error  string     `json:"error,omitempty"`
+
+	remote *client.File
+}
+
+type syncOptions struct {
+	delete      bool
+	dryRun      bool
+	checksum    bool
+	concurrency int
+}
+
+func (c *cli) syncCommand() *cobra.Command {
+	var opts syncOptions
+	cmd := &cobra.Command{
+		Use:   "sync SOURCE DESTINATION",
+		Short: "Make DESTINATION have the same files as SOURCE, one is a local directory and the other user:prefix",
+		Long: `Sync copies the files that are missing or differ on DESTINATION, like rsync.
+
+Files differ when their sizes differ or when the source was modified after the destination,
+downloaded files get the update time of the remote file so they are not downloaded again.
+With --checksum files of the same size are compared by their md5 instead.`,
+		Example: "  fileapi-cli sync ./build 1:artifacts/latest --delete\n  fileapi-cli sync 1:artifacts/latest ./build --dry-run",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.concurrency < 1 {
+				return errors.New("concurrency must be at least 1")
+			}
+
+			remote, remoteIsSource, local, err := parseSyncArgs(args[0], args[1])
+			if err != nil {
+				return err
+			}
+
+			if info, err := os.Stat(local); !remoteIsSource && (err != nil || !info.IsDir()) {
+				return fmt.Errorf("%s is not a directory", local)
+			}
+
+			locals, err := listLocal(local)
+			if err != nil {
+				return err
+			}
+
+			files, err := c.api.List(cmd.Context(), remote.user, remote.prefix)
+			if err != nil {
+				return err
+			}
+
+			remotes := relativeRemote(files, remote.prefix)
+			steps, err := planSync(local, locals, remotes, remoteIsSource, opts)
+			if err != nil {
+				return err
+			}
+
+			if !opts.dryRun {
+				c.runSync(cmd, steps, local, remote, opts.concurrency)
+			}
+
+			return c.printSync(cmd, steps, opts.dryRun)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.delete, "delete", false, "delete files on the destination that are not on the source")
+	cmd.Flags().BoolVarP(&opts.dryRun, "dry-run", "n", false, "only show what would be done")
+	cmd.Flags().BoolVarP(&opts.checksum, "checksum", "c", false, "compare files of the same size by their md5 instead of their modification times")
+	cmd.Flags().IntVarP(&opts.concurrency, "concurrency", "j", 4, "how many files are transferred at the same time")
+	return cmd
+}
+
+// parseSyncArgs tells which of the arguments is the remote directory
+func parseSyncArgs(source, destination string) (remoteDir, bool, string, error) {
+	if remote, ok := parseRemote(source); ok {
+		if _, ok := parseRemote(destination); ok {
+			return remoteDir{}, false, "", errors.New("one of the directories has to be local")
+		}
+
+		return remote, true, destination, nil
+	}
+
+	if remote, ok := parseRemote(destination); ok {
+		return remote, false, source, nil
+	}
+
+	return remoteDir{}, false, "", errors.New("one of the directories has to be remote, as user:prefix")
+}
+
+func parseRemote(value string) (remoteDir, bool) {
+	user, prefix, ok := strings.Cut(value, ":")
+	id, err := strconv.Atoi(user)
+	if !ok || err != nil {
+		return remoteDir{}, false
+	}
+
+	return remoteDir{user: id, prefix: strings.Trim(prefix, "/")}, true
+}
+
+// listLocal lists the files under dir by their slash separated path relative to it
+func listLocal(dir string) (map[string]localFile, error) {
+	files := map[string]localFile{}
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && name == dir {
+			// the destination is created by the sync
+			return filepath.SkipDir
+		}
+
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relative)] = localFile{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+
+	return files, err
+}
+
+// relativeRemote keys files by their path relative to prefix, skipping files that only share the
+// beginning of their name with it
+func relativeRemote(files []*client.File, prefix string) map[string]*client.File {
+	result := map[string]*client.File{}
+	for _, f := range files {
+		full := f.FullPath()
+		if prefix == "" {
+			result[full] = f
+		} else if strings.HasPrefix(full, prefix+"/") {
+			result[strings.TrimPrefix(full, prefix+"/")] = f
+		}
+	}
+
+	return result
+}
+
+// planSync lists the steps that make the destination match the source, sorted by path
+func planSync(dir string, locals map[string]localFile, remotes map[string]*client.File, remoteIsSource bool, opts syncOptions) ([]syncStep, error) {
+	var steps []syncStep
+	if remoteIsSource {
+		for name, remote := range remotes {
+			local, ok := locals[name]
+			if ok {
+				differ, err := differs(dir, name, local, remote, opts.checksum, true)
+				if err != nil {
+					return nil, err
+				}
+
+				if !differ {
+					continue
+				}
+			}
+
+			steps = append(steps, syncStep{Action: actionDownload, Path: name, remote: remote})
+		}
+
+		for name := range locals {
+			if _, ok := remotes[name]; !ok && opts.delete {
+				steps = append(steps, syncStep{Action: actionDelete, Path: name})
+			}
+		}
+	} else {
+		for name, local := range locals {
+			if remote, ok := remotes[name]; ok {
+				differ, err := differs(dir, name, local, remote, opts.checksum, false)
+				if err != nil {
+					return nil, err
+				}
+
+				if !differ {
+					continue
+				}
+			}
+
+			steps = append(steps, syncStep{Action: actionUpload, Path: name})
+		}
+
+		for name, remote := range remotes {
+			if _, ok := locals[name]; !ok && opts.delete {
+				steps = append(steps, syncStep{Action: actionDelete, Path: name, remote: remote})
+			}
+		}
+	}
+
+	sort.Slice(steps, func(i, j int) bool {
+		if steps[i].Path == steps[j].Path {
+			return steps[i].Action < steps[j].Action
+		}
+
+		return steps[i].Path < steps[j].Path
+	})
+
+	return steps, nil
+}
+
+// differs compares a local and a remote file with the same path, the source is newer when its
+// modification time is after the one of the destination
+func differs(dir, name string, local localFile, remote *client.File, checksum, remoteIsSource bool) (bool, error) {
+	if local.size != remote.Size {
+		return true, nil
+	}
+
+	if checksum && remote.Checksum != "" {
+		sum, err := md5File(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return false, err
+		}
+
+		return sum != remote.Checksum, nil
+	}
+
+	// the api keeps times with second precision
+	localTime, remoteTime := local.modTime.Truncate(time.Second), remote.UpdatedAt.Truncate(time.Second)
+	if remoteIsSource {
+		return remoteTime.After(localTime), nil
+	}
+
+	return localTime.After(remoteTime), nil
+}
+
+func md5File(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// runSync runs the steps with up to concurrency at the same time, recording the error of each step
+func (c *cli) runSync(cmd *cobra.Command, steps []syncStep, dir string, remote remoteDir, concurrency int) {
+	var wg sync.WaitGroup
+	queue := make(chan *syncStep)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for step := range queue {
+				if err := c.runStep(cmd, step, dir, remote); err != nil {
+					step.Error = err.Error()
+				}
+			}
+		}()
+	}
+
+	for i := range steps {
+		queue <- &steps[i]
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (c *cli) runStep(cmd *cobra.Command, step *syncStep, dir string, remote remoteDir) error {
+	ctx := cmd.Context()
+	local := filepath.Join(dir, filepath.FromSlash(step.Path))
+	switch step.Action {
+	case actionUpload:
+		content, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		remotePath, name := path.Split(step.Path)
+		_, err = c.api.Upload(ctx, client.UploadInput{
+			User:      remote.user,
+			Path:      joinPath(remote.prefix, remotePath),
+			Name:      name,
+			Overwrite: true,
+		}, content)
+		return err
+	case actionDownload:
+		content, err := c.api.Content(ctx, step.remote)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+			return err
+		}
+
+		out, err := os.Create(local)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(out, content); err != nil {
+			out.Close()
+			return err
+		}
+
+		if err := out.Close(); err != nil {
+			return err
+		}
+
+		// so the next sync sees the file is up to date
+		return os.Chtimes(local, step.remote.UpdatedAt, step.remote.UpdatedAt)
+	default:
+		if step.remote != nil {
+			return c.api.Delete(ctx, step.remote.ID)
+		}
+
+		return os.Remove(local)
+	}
+}
+
+func (c *cli) printSync(cmd *cobra.Command, steps []syncStep, dryRun bool) error {
+	failed := 0
+	for _, step := range steps {
+		if step.Error != "" {
+			failed++
+		}
+	}
+
+	if c.json {
+		if steps == nil {
+			steps = []syncStep{}
+		}
+
+		if err := c.printJSON(cmd, steps); err != nil {
+			return err
+		}
+	} else {
+		prefix := ""
+		if dryRun {
+			prefix = "(dry run) "
+		}
+
+		for _, step := range steps {
+			if step.Error != "" {
+				c.printf(cmd, "%s%s %s: %s\n", prefix, step.Action, step.Path, step.Error)
+			} else {
+				c.printf(cmd, "%s%s %s\n", prefix, step.Action, step.Path)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to sync", failed, len(steps))
+	}
+
+	return nil
+}
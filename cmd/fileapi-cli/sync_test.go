@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSyncArgs(t *testing.T) {
+	remote, remoteIsSource, local, err := parseSyncArgs("./build", "1:artifacts/latest/")
+	require.NoError(t, err)
+	require.Equal(t, remoteDir{user: 1, prefix: "artifacts/latest"}, remote)
+	require.False(t, remoteIsSource)
+	require.Equal(t, "./build", local)
+
+	_, remoteIsSource, _, err = parseSyncArgs("2:", "./build")
+	require.NoError(t, err)
+	require.True(t, remoteIsSource)
+
+	_, _, _, err = parseSyncArgs("./a", "./b")
+	require.Error(t, err)
+
+	_, _, _, err = parseSyncArgs("1:a", "2:b")
+	require.Error(t, err)
+}
+
+func TestPlanSync(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "same.txt"), []byte("hello"), 0o600))
+
+	now := time.Now()
+	hello := "5d41402abc4b2a76b9719d911017c592"
+	locals := map[string]localFile{
+		"new.txt":     {size: 3, modTime: now},
+		"same.txt":    {size: 5, modTime: now.Add(-time.Hour)},
+		"resized.txt": {size: 4, modTime: now.Add(-time.Hour)},
+		"newer.txt":   {size: 5, modTime: now},
+	}
+	remotes := map[string]*client.File{
+		"same.txt":    {Size: 5, UpdatedAt: now, Checksum: hello},
+		"resized.txt": {Size: 5, UpdatedAt: now},
+		"newer.txt":   {Size: 5, UpdatedAt: now.Add(-time.Hour)},
+		"extra.txt":   {Size: 1, UpdatedAt: now},
+	}
+
+	paths := func(steps []syncStep) []string {
+		var result []string
+		for _, step := range steps {
+			result = append(result, string(step.Action)+" "+step.Path)
+		}
+		return result
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		steps, err := planSync(dir, locals, remotes, false, syncOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"upload new.txt", "upload newer.txt", "upload resized.txt"}, paths(steps))
+	})
+
+	t.Run("upload with delete", func(t *testing.T) {
+		steps, err := planSync(dir, locals, remotes, false, syncOptions{delete: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"delete extra.txt", "upload new.txt", "upload newer.txt", "upload resized.txt"}, paths(steps))
+	})
+
+	t.Run("download", func(t *testing.T) {
+		steps, err := planSync(dir, locals, remotes, true, syncOptions{delete: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"download extra.txt", "delete new.txt", "download resized.txt", "download same.txt"}, paths(steps))
+	})
+
+	t.Run("checksum skips files with the same content", func(t *testing.T) {
+		steps, err := planSync(dir, locals, remotes, true, syncOptions{checksum: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{"download extra.txt", "download resized.txt"}, paths(steps))
+	})
+}
+
+func TestRelativeRemote(t *testing.T) {
+	files := []*client.File{
+		{Path: "build", Name: "a.txt"},
+		{Path: "build/img", Name: "b.png"},
+		{Path: "build2", Name: "c.txt"},
+	}
+
+	result := relativeRemote(files, "build")
+	require.Len(t, result, 2)
+	require.Contains(t, result, "a.txt")
+	require.Contains(t, result, "img/b.png")
+}
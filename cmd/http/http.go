@@ -4,29 +4,134 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	gohttp "net/http"
 	"os"
 	"os/signal"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
 	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/contenttype"
+	"github.com/rafaelrubbioli/fileapi/pkg/database"
+	"github.com/rafaelrubbioli/fileapi/pkg/grpc"
+	"github.com/rafaelrubbioli/fileapi/pkg/health"
 	"github.com/rafaelrubbioli/fileapi/pkg/http"
+	"github.com/rafaelrubbioli/fileapi/pkg/idempotency"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/scanner"
 	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	"github.com/rafaelrubbioli/fileapi/pkg/storage"
+	"github.com/rafaelrubbioli/fileapi/pkg/tracing"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
 
 	s3config "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 func main() {
+	slog.SetDefault(logging.New(os.Stdout, logging.ParseLevel(config.LogLevel())))
+
 	ctx := context.Background()
 	cfg, err := s3config.LoadDefaultConfig(ctx, s3config.WithRegion(config.AwsRegion))
 	if err != nil {
 		log.Fatalf("failed to load SDK configuration, %v", err)
 	}
 
-	client := s3.NewFromConfig(cfg)
-	services := service.NewS3Service(client)
+	shutdownTracing, err := tracing.Setup(ctx, config.TracingExporter())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(ctx)
+
+	client := storage.Instrument(s3.NewFromConfig(cfg))
+
+	rules, err := contenttype.ParseRules(config.ContentTypeRules())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		webhooks   webhook.Store
+		dispatcher *webhook.Dispatcher
+		keys       idempotency.Store
+		checks     []health.Check
+	)
+	opts := []service.Option{service.WithContentTypeRules(rules)}
+	if path := config.DatabasePath(); path != "" {
+		db, err := database.Open(path)
+		if err != nil {
+			log.Fatalf("failed to open database, %v", err)
+		}
+		defer db.Close()
+		checks = append(checks, health.Check{Name: "database", Check: db.PingContext})
+
+		idx, err := index.NewSQLite(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		webhooks, err = webhook.NewSQLite(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		dispatcher = webhook.NewDispatcher(webhooks, nil)
+		opts = append(opts, service.WithIndex(idx), service.WithWebhooks(webhooks, dispatcher))
+
+		keys, err = idempotency.NewSQLite(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if config.AuditSink() == config.AuditSQLite {
+			auditLog, err := audit.NewSQLite(db)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			opts = append(opts, service.WithAudit(auditLog))
+		}
+	}
+
+	if config.AuditSink() == config.AuditJSONLines {
+		auditLog, err := audit.NewJSONLines(config.AuditFile())
+		if err != nil {
+			log.Fatalf("failed to open audit log, %v", err)
+		}
+		defer auditLog.Close()
+
+		opts = append(opts, service.WithAudit(auditLog))
+	}
+
+	if ttl := config.IdempotencyTTL(); ttl > 0 {
+		// without the database results are only replayed by the instance that kept them
+		if keys == nil {
+			keys = idempotency.NewMemory()
+		}
+
+		opts = append(opts, service.WithIdempotency(keys, ttl))
+	}
+
+	if address := config.ClamdAddress(); address != "" {
+		opts = append(opts, service.WithScanner(scanner.NewClamd(address)))
+	}
+
+	if sizes := config.ThumbnailSizes(); len(sizes) > 0 {
+		opts = append(opts, service.WithThumbnails(sizes))
+	}
+
+	services := service.NewS3Service(client, opts...)
 
-	handler, err := http.NewServer(services)
+	dispatchCtx, stopDispatch := context.WithCancel(ctx)
+	defer stopDispatch()
+	if dispatcher != nil {
+		go dispatcher.Run(dispatchCtx)
+	}
+
+	handler, err := http.NewServer(services, checks...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -36,21 +141,44 @@ func main() {
 		Handler: handler,
 	}
 
+	adminTokens, err := auth.ParseTokens(config.AdminTokens())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	grpcServer := grpc.NewServer(services, adminTokens)
+	if port := config.GRPCPort(); port > 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			log.Fatalf("failed to listen for grpc, %v", err)
+		}
+
+		go func() {
+			slog.Info("listening for grpc", "port", port)
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatal("Serve: ", err)
+			}
+		}()
+	}
+
 	idleConnsClosed := make(chan struct{})
 	go func() {
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt)
 		<-sigint
 
-		log.Println("Shutting down")
+		slog.Info("shutting down")
+		grpcServer.GracefulStop()
 		if err := server.Shutdown(ctx); err != nil {
 			log.Fatal("Shutdown: ", err)
 		}
+		if err := services.Close(ctx); err != nil {
+			log.Fatal("Close: ", err)
+		}
 		close(idleConnsClosed)
 	}()
 
-	log.Println("Listening on port:", config.Port())
-	log.Println(fmt.Sprintf("Explorer available at: %s/explorer", config.BaseURL()))
+	slog.Info("listening", "port", config.Port(), "explorer", config.BaseURL()+"/explorer")
 	if err := server.ListenAndServe(); err != gohttp.ErrServerClosed {
 		log.Fatal("ListenAndServe: ", err)
 	}
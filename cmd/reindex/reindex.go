@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/database"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+
+	s3config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func main() {
+	slog.SetDefault(logging.New(os.Stdout, logging.ParseLevel(config.LogLevel())))
+
+	ctx := context.Background()
+	cfg, err := s3config.LoadDefaultConfig(ctx, s3config.WithRegion(config.AwsRegion))
+	if err != nil {
+		log.Fatalf("failed to load SDK configuration, %v", err)
+	}
+
+	if config.DatabasePath() == "" {
+		log.Fatal("database_path is not set, there is no index to rebuild")
+	}
+
+	db, err := database.Open(config.DatabasePath())
+	if err != nil {
+		log.Fatalf("failed to open database, %v", err)
+	}
+	defer db.Close()
+
+	idx, err := index.NewSQLite(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	services := service.NewS3Service(s3.NewFromConfig(cfg), service.WithIndex(idx))
+
+	slog.Info("rebuilding index", "bucket", config.BucketName)
+	count, err := services.Reindex(ctx)
+	if err != nil {
+		log.Fatalf("reindex failed after %d files: %v", count, err)
+	}
+
+	slog.Info("index rebuilt", "files", count)
+}
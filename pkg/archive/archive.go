@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Format string
+
+const (
+	Zip   Format = "zip"
+	TarGz Format = "tar.gz"
+)
+
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+// ParseFormat accepts the formats by their extension, zip when it is empty
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", Zip:
+		return Zip, nil
+	case TarGz, "tgz":
+		return TarGz, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// ContentType is the media type of archives in the format
+func (f Format) ContentType() string {
+	if f == TarGz {
+		return "application/gzip"
+	}
+
+	return "application/zip"
+}
+
+// Writer streams entries into an archive, entries are never buffered whole
+type Writer interface {
+	// Add writes size bytes of content as the entry name, failing when content has a different size
+	Add(name string, size int64, modTime time.Time, content io.Reader) error
+	// Close finishes the archive, it does not close the underlying writer
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) Writer {
+	if format == TarGz {
+		gz := gzip.NewWriter(w)
+		return &tarWriter{gzip: gz, tar: tar.NewWriter(gz)}
+	}
+
+	return &zipWriter{zip: zip.NewWriter(w)}
+}
+
+type zipWriter struct {
+	zip *zip.Writer
+}
+
+func (w *zipWriter) Add(name string, size int64, modTime time.Time, content io.Reader) error {
+	entry, err := w.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return err
+	}
+
+	return copyExactly(entry, content, size)
+}
+
+func (w *zipWriter) Close() error {
+	return w.zip.Close()
+}
+
+type tarWriter struct {
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func (w *tarWriter) Add(name string, size int64, modTime time.Time, content io.Reader) error {
+	err := w.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	return copyExactly(w.tar, content, size)
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+
+	return w.gzip.Close()
+}
+
+// copyExactly copies size bytes, as tar headers have the size before the content
+func copyExactly(w io.Writer, content io.Reader, size int64) error {
+	n, err := io.Copy(w, io.LimitReader(content, size+1))
+	if err != nil {
+		return err
+	}
+
+	if n != size {
+		return fmt.Errorf("entry has %d bytes, expected %d", n, size)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	for value, expected := range map[string]Format{"": Zip, "zip": Zip, "tar.gz": TarGz, "tgz": TarGz} {
+		format, err := ParseFormat(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, format)
+	}
+
+	_, err := ParseFormat("rar")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestWriter(t *testing.T) {
+	modTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("zip", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := NewWriter(&buffer, Zip)
+		require.NoError(t, writer.Add("a.txt", 5, modTime, strings.NewReader("hello")))
+		require.NoError(t, writer.Add("img/b.txt", 5, modTime, strings.NewReader("world")))
+		require.NoError(t, writer.Close())
+
+		reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		require.NoError(t, err)
+		require.Len(t, reader.File, 2)
+		require.Equal(t, "img/b.txt", reader.File[1].Name)
+
+		content, err := reader.File[1].Open()
+		require.NoError(t, err)
+		read, _ := io.ReadAll(content)
+		require.Equal(t, "world", string(read))
+	})
+
+	t.Run("tar.gz", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := NewWriter(&buffer, TarGz)
+		require.NoError(t, writer.Add("a.txt", 5, modTime, strings.NewReader("hello")))
+		require.NoError(t, writer.Close())
+
+		gz, err := gzip.NewReader(&buffer)
+		require.NoError(t, err)
+		reader := tar.NewReader(gz)
+
+		header, err := reader.Next()
+		require.NoError(t, err)
+		require.Equal(t, "a.txt", header.Name)
+		require.True(t, modTime.Equal(header.ModTime))
+
+		read, _ := io.ReadAll(reader)
+		require.Equal(t, "hello", string(read))
+
+		_, err = reader.Next()
+		require.Equal(t, io.EOF, err)
+	})
+
+	t.Run("content with a different size", func(t *testing.T) {
+		for _, format := range []Format{Zip, TarGz} {
+			writer := NewWriter(io.Discard, format)
+			require.Error(t, writer.Add("a.txt", 4, modTime, strings.NewReader("hello")))
+			require.Error(t, NewWriter(io.Discard, format).Add("a.txt", 6, modTime, strings.NewReader("hello")))
+		}
+	})
+}
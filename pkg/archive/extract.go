@@ -0,0 +1,188 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrTooManyEntries = errors.New("archive has too many entries")
+	ErrTooBig         = errors.New("archive is too big once extracted")
+)
+
+// Limits bound what is extracted, against archives that expand to much more than their size
+type Limits struct {
+	MaxEntries int
+	// MaxSize is the sum of the sizes of every entry, in bytes
+	MaxSize int64
+}
+
+// Entry is a file inside an archive
+type Entry struct {
+	// Name is the slash separated path inside the archive, as written by whoever made it
+	Name string
+	Size int64
+	// Regular is false for links and devices, which have no content to extract
+	Regular bool
+}
+
+// FormatOf is the format of an archive by the extension of its name
+func FormatOf(name string) (Format, error) {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+}
+
+// Extract calls fn with each entry of the archive in r, except directories, and the content of
+// regular files. The whole archive is checked against limits before fn is first called, so
+// archives over them are never partially extracted.
+func Extract(r io.ReadSeeker, format Format, limits Limits, fn func(Entry, io.Reader) error) error {
+	if format == Zip {
+		return extractZip(r, limits, fn)
+	}
+
+	// tar has no index, so it is read once to check the limits and again to extract it
+	if err := walkTar(r, func(Entry, io.Reader) error { return nil }, limits); err != nil {
+		return err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return walkTar(r, fn, limits)
+}
+
+func extractZip(r io.ReadSeeker, limits Limits, fn func(Entry, io.Reader) error) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	readerAt, ok := r.(io.ReaderAt)
+	if !ok {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		readerAt = bytes.NewReader(content)
+	}
+
+	archive, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return err
+	}
+
+	// sizes on the central directory are checked by the zip reader against what is extracted
+	var entries []*zip.File
+	var total int64
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		entries = append(entries, file)
+		total += int64(file.UncompressedSize64)
+		if err := check(limits, len(entries), total); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range entries {
+		entry := Entry{Name: file.Name, Size: int64(file.UncompressedSize64), Regular: file.Mode().IsRegular()}
+		if !entry.Regular {
+			if err := fn(entry, nil); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+
+		err = fn(entry, content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkTar(r io.Reader, fn func(Entry, io.Reader) error, limits Limits) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	var (
+		count int
+		total int64
+	)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeDir || header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		entry := Entry{Name: header.Name, Size: header.Size, Regular: header.Typeflag == tar.TypeReg}
+		count++
+		if entry.Regular {
+			total += header.Size
+		}
+
+		if err := check(limits, count, total); err != nil {
+			return err
+		}
+
+		var content io.Reader
+		if entry.Regular {
+			content = archive
+		}
+
+		if err := fn(entry, content); err != nil {
+			return err
+		}
+	}
+}
+
+func check(limits Limits, count int, total int64) error {
+	if limits.MaxEntries > 0 && count > limits.MaxEntries {
+		return ErrTooManyEntries
+	}
+
+	if limits.MaxSize > 0 && total > limits.MaxSize {
+		return ErrTooBig
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func zipOf(t *testing.T, files map[string]string) *bytes.Reader {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	_, err := writer.Create("docs/")
+	require.NoError(t, err)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = entry.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, writer.Close())
+	return bytes.NewReader(buffer.Bytes())
+}
+
+func TestExtract(t *testing.T) {
+	collect := func(r io.ReadSeeker, format Format, limits Limits) (map[string]string, []Entry, error) {
+		contents := map[string]string{}
+		var entries []Entry
+		err := Extract(r, format, limits, func(entry Entry, content io.Reader) error {
+			entries = append(entries, entry)
+			if content != nil {
+				read, err := io.ReadAll(content)
+				require.NoError(t, err)
+				contents[entry.Name] = string(read)
+			}
+			return nil
+		})
+		return contents, entries, err
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		contents, entries, err := collect(zipOf(t, map[string]string{"a.txt": "hello", "docs/b.txt": "world"}), Zip, Limits{})
+		require.NoError(t, err)
+		require.Len(t, entries, 2, "directories are skipped")
+		require.Equal(t, map[string]string{"a.txt": "hello", "docs/b.txt": "world"}, contents)
+	})
+
+	t.Run("tar.gz", func(t *testing.T) {
+		var buffer bytes.Buffer
+		gz := gzip.NewWriter(&buffer)
+		writer := tar.NewWriter(gz)
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0755}))
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: "docs/a.txt", Typeflag: tar.TypeReg, Size: 5, Mode: 0644}))
+		_, err := writer.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: "docs/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}))
+		require.NoError(t, writer.Close())
+		require.NoError(t, gz.Close())
+
+		contents, entries, err := collect(bytes.NewReader(buffer.Bytes()), TarGz, Limits{})
+		require.NoError(t, err)
+		require.Equal(t, []Entry{{Name: "docs/a.txt", Size: 5, Regular: true}, {Name: "docs/link"}}, entries)
+		require.Equal(t, map[string]string{"docs/a.txt": "hello"}, contents)
+
+		_, _, err = collect(bytes.NewReader(buffer.Bytes()), TarGz, Limits{MaxSize: 4})
+		require.ErrorIs(t, err, ErrTooBig)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		files := map[string]string{"a.txt": "hello", "b.txt": "world"}
+		_, entries, err := collect(zipOf(t, files), Zip, Limits{MaxEntries: 1})
+		require.ErrorIs(t, err, ErrTooManyEntries)
+		require.Empty(t, entries, "nothing is extracted from archives over the limits")
+
+		_, entries, err = collect(zipOf(t, files), Zip, Limits{MaxSize: 9})
+		require.ErrorIs(t, err, ErrTooBig)
+		require.Empty(t, entries)
+
+		_, _, err = collect(zipOf(t, files), Zip, Limits{MaxEntries: 2, MaxSize: 10})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid archive", func(t *testing.T) {
+		_, _, err := collect(bytes.NewReader([]byte("not an archive")), Zip, Limits{})
+		require.Error(t, err)
+
+		_, _, err = collect(bytes.NewReader([]byte("not an archive")), TarGz, Limits{})
+		require.Error(t, err)
+	})
+}
+
+func TestFormatOf(t *testing.T) {
+	for name, expected := range map[string]Format{"a.zip": Zip, "A.ZIP": Zip, "a.tar.gz": TarGz, "a.tgz": TarGz} {
+		format, err := FormatOf(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, format)
+	}
+
+	_, err := FormatOf("a.tar")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -0,0 +1,69 @@
+//go:generate go run -mod=mod github.com/golang/mock/mockgen -package=mocks -source=$GOFILE -destination=../../test/mock/audit.go
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+type Action string
+
+const (
+	Upload Action = "UPLOAD"
+	Move   Action = "MOVE"
+	Copy   Action = "COPY"
+	Delete Action = "DELETE"
+)
+
+type Result string
+
+const (
+	Success Result = "SUCCESS"
+	Failure Result = "FAILURE"
+)
+
+// DefaultPageSize is how many entries are listed when no limit is given
+const DefaultPageSize = 50
+
+type Entry struct {
+	// ID is only set by sinks that can be read back
+	ID     int64     `json:"id,omitempty"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action Action    `json:"action"`
+	// User owns the file after the operation, or before it for deletes
+	User int `json:"user"`
+	// OldKey is where the file was before the operation, empty for uploads
+	OldKey string `json:"old_key,omitempty"`
+	// NewKey is where the file is after the operation, empty for deletes
+	NewKey    string `json:"new_key,omitempty"`
+	ClientIP  string `json:"client_ip"`
+	RequestID string `json:"request_id"`
+	Result    Result `json:"result"`
+	Error     string `json:"error,omitempty"`
+}
+
+type Filter struct {
+	Actor  string
+	Action Action
+	User   *int
+	// Key matches entries where the file was before or after the operation
+	Key    string
+	Result Result
+	After  *time.Time
+	Before *time.Time
+	Limit  int
+	Offset int
+}
+
+// Sink appends entries to the audit log, entries are never changed once written
+type Sink interface {
+	Write(ctx context.Context, entry *Entry) error
+}
+
+// Reader is a sink that can also be searched
+type Reader interface {
+	Sink
+	// List returns entries matching filter, latest first
+	List(ctx context.Context, filter Filter) ([]*Entry, error)
+}
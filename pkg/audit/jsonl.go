@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// JSONLines appends every entry as a line of json to a file
+type JSONLines struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewJSONLines(path string) (*JSONLines, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONLines{file: file}, nil
+}
+
+func (j *JSONLines) Write(_ context.Context, entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+func (j *JSONLines) Close() error {
+	return j.file.Close()
+}
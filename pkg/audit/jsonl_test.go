@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sink, err := NewJSONLines(path)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(context.Background(), &Entry{Time: time.Now(), Action: Upload, NewKey: "1/a.txt", Result: Success}))
+	require.NoError(t, sink.Close())
+
+	// reopening appends instead of truncating
+	sink, err = NewJSONLines(path)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(context.Background(), &Entry{Time: time.Now(), Action: Delete, OldKey: "1/a.txt", Result: Failure, Error: "not found"}))
+	require.NoError(t, sink.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	require.Len(t, lines, 2)
+	require.Equal(t, "UPLOAD", lines[0]["action"])
+	require.Equal(t, "1/a.txt", lines[0]["new_key"])
+	require.NotContains(t, lines[0], "old_key")
+	require.Equal(t, "DELETE", lines[1]["action"])
+	require.Equal(t, "not found", lines[1]["error"])
+}
@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the triggers keep the log append only
+const schema = `
+CREATE TABLE IF NOT EXISTS audit_log (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	time       INTEGER NOT NULL,
+	actor      TEXT NOT NULL,
+	action     TEXT NOT NULL,
+	user       INTEGER NOT NULL,
+	old_key    TEXT NOT NULL,
+	new_key    TEXT NOT NULL,
+	client_ip  TEXT NOT NULL,
+	request_id TEXT NOT NULL,
+	result     TEXT NOT NULL,
+	error      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_log_time ON audit_log (time);
+CREATE INDEX IF NOT EXISTS audit_log_user ON audit_log (user);
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append only');
+END;
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit log is append only');
+END;
+`
+
+const entryColumns = "id, time, actor, action, user, old_key, new_key, client_ip, request_id, result, error"
+
+func NewSQLite(db *sql.DB) (Reader, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to migrate audit log: %w", err)
+	}
+
+	return sqlite{db: db}, nil
+}
+
+type sqlite struct {
+	db *sql.DB
+}
+
+func (s sqlite) Write(ctx context.Context, entry *Entry) error {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (time, actor, action, user, old_key, new_key, client_ip, request_id, result, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Time.UnixNano(), entry.Actor, entry.Action, entry.User, entry.OldKey, entry.NewKey,
+		entry.ClientIP, entry.RequestID, entry.Result, entry.Error,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+func (s sqlite) List(ctx context.Context, filter Filter) ([]*Entry, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if filter.Actor != "" {
+		add("actor = ?", filter.Actor)
+	}
+
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+
+	if filter.User != nil {
+		add("user = ?", *filter.User)
+	}
+
+	if filter.Key != "" {
+		add("(old_key = ? OR new_key = ?)", filter.Key, filter.Key)
+	}
+
+	if filter.Result != "" {
+		add("result = ?", filter.Result)
+	}
+
+	if filter.After != nil {
+		add("time >= ?", filter.After.UnixNano())
+	}
+
+	if filter.Before != nil {
+		add("time <= ?", filter.Before.UnixNano())
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+entryColumns+" FROM audit_log"+where+" ORDER BY id DESC LIMIT ? OFFSET ?",
+		append(args, filter.Limit, filter.Offset)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*Entry, 0, filter.Limit)
+	for rows.Next() {
+		var (
+			entry Entry
+			at    int64
+		)
+
+		err := rows.Scan(
+			&entry.ID, &at, &entry.Actor, &entry.Action, &entry.User, &entry.OldKey, &entry.NewKey,
+			&entry.ClientIP, &entry.RequestID, &entry.Result, &entry.Error,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Time = time.Unix(0, at)
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(database.Memory)
+	require.NoError(t, err)
+	defer db.Close()
+
+	log, err := NewSQLite(db)
+	require.NoError(t, err)
+
+	now := time.Now()
+	entries := []*Entry{
+		{Time: now.Add(-time.Hour), Actor: "anonymous", Action: Upload, User: 1, NewKey: "1/a.txt", ClientIP: "10.0.0.1", RequestID: "a", Result: Success},
+		{Time: now.Add(-time.Minute), Actor: "admin", Action: Move, User: 2, OldKey: "1/a.txt", NewKey: "2/b.txt", ClientIP: "10.0.0.2", RequestID: "b", Result: Success},
+		{Time: now, Actor: "anonymous", Action: Delete, User: 2, OldKey: "2/b.txt", ClientIP: "10.0.0.1", RequestID: "c", Result: Failure, Error: "not found"},
+	}
+	for _, entry := range entries {
+		require.NoError(t, log.Write(ctx, entry))
+		require.NotZero(t, entry.ID)
+	}
+
+	requestIDs := func(entries []*Entry) []string {
+		result := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			result = append(result, entry.RequestID)
+		}
+		return result
+	}
+
+	user := 2
+	after := now.Add(-2 * time.Minute)
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{name: "latest first", filter: Filter{}, expected: []string{"c", "b", "a"}},
+		{name: "actor", filter: Filter{Actor: "anonymous"}, expected: []string{"c", "a"}},
+		{name: "action", filter: Filter{Action: Move}, expected: []string{"b"}},
+		{name: "user", filter: Filter{User: &user}, expected: []string{"c", "b"}},
+		{name: "key before or after", filter: Filter{Key: "1/a.txt"}, expected: []string{"b", "a"}},
+		{name: "result", filter: Filter{Result: Failure}, expected: []string{"c"}},
+		{name: "after", filter: Filter{After: &after}, expected: []string{"c", "b"}},
+		{name: "page", filter: Filter{Limit: 1, Offset: 1}, expected: []string{"b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := log.List(ctx, test.filter)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, requestIDs(result))
+		})
+	}
+
+	t.Run("fields", func(t *testing.T) {
+		result, err := log.List(ctx, Filter{Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, "2/b.txt", result[0].OldKey)
+		require.Empty(t, result[0].NewKey)
+		require.Equal(t, "10.0.0.1", result[0].ClientIP)
+		require.Equal(t, "not found", result[0].Error)
+		require.True(t, now.Equal(result[0].Time))
+	})
+
+	t.Run("append only", func(t *testing.T) {
+		_, err := db.Exec("UPDATE audit_log SET actor = 'someone else'")
+		require.Error(t, err)
+
+		_, err = db.Exec("DELETE FROM audit_log")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Anonymous is the name of requests sent without a token
+const Anonymous = "anonymous"
+
+// ErrInvalidToken is returned for authorization headers that are not a known bearer token
+var ErrInvalidToken = errors.New("invalid token")
+
+type Identity struct {
+	Name  string
+	Admin bool
+}
+
+type contextKey struct{}
+
+// FromContext returns who made the request, anonymous if it was not identified
+func FromContext(ctx context.Context) Identity {
+	if identity, ok := ctx.Value(contextKey{}).(Identity); ok {
+		return identity
+	}
+
+	return Identity{Name: Anonymous}
+}
+
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// ParseTokens parses tokens in the format "name:token,name:token" into a map of token names by token
+func ParseTokens(value string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid token %q, expected name:token", parts[0])
+		}
+
+		tokens[strings.TrimSpace(parts[1])] = strings.TrimSpace(parts[0])
+	}
+
+	return tokens, nil
+}
+
+// Authenticate identifies an "Authorization" header value in the format "Bearer <token>" matching one
+// of the admin tokens, an empty header is anonymous and any other value is an ErrInvalidToken
+func Authenticate(adminTokens map[string]string, header string) (Identity, error) {
+	if header == "" {
+		return Identity{Name: Anonymous}, nil
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	name, ok := lookup(adminTokens, token)
+	if !ok || token == header {
+		return Identity{}, ErrInvalidToken
+	}
+
+	return Identity{Name: name, Admin: true}, nil
+}
+
+// Middleware identifies requests with an "Authorization: Bearer <token>" header matching one of the
+// admin tokens, requests with any other token are rejected and requests without one are anonymous
+func Middleware(adminTokens map[string]string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			identity, err := Authenticate(adminTokens, header)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// lookup compares token to every known token in constant time
+func lookup(tokens map[string]string, token string) (string, bool) {
+	var (
+		name  string
+		found bool
+	)
+
+	for known, knownName := range tokens {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(token)) == 1 {
+			name, found = knownName, true
+		}
+	}
+
+	return name, found
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTokens(t *testing.T) {
+	tokens, err := ParseTokens("alice:abc, bob:d:ef,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"abc": "alice", "d:ef": "bob"}, tokens)
+
+	tokens, err = ParseTokens("")
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+
+	_, err = ParseTokens("alice")
+	require.Error(t, err)
+
+	_, err = ParseTokens("alice:")
+	require.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	var identity Identity
+	handler := Middleware(map[string]string{"secret": "alice"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = FromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		status   int
+		identity Identity
+	}{
+		{name: "anonymous", status: http.StatusOK, identity: Identity{Name: Anonymous}},
+		{name: "admin", header: "Bearer secret", status: http.StatusOK, identity: Identity{Name: "alice", Admin: true}},
+		{name: "invalid token", header: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "secret", status: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			identity = Identity{}
+			request := httptest.NewRequest(http.MethodPost, "/graphql/", nil)
+			if test.header != "" {
+				request.Header.Set("Authorization", test.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			require.Equal(t, test.status, recorder.Code)
+			require.Equal(t, test.identity, identity)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package client calls the graphql api of fileapi
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 500 * time.Millisecond
+)
+
+type Option func(*Client)
+
+// WithToken sends token as the bearer token of every request
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sends requests with httpClient instead of http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// WithRetries retries requests answered with SERVICE_UNAVAILABLE or RATE_LIMITED up to retries times,
+// waiting backoff before the first retry and doubling it on each one. Rate limited requests wait
+// at least as long as the api asks for.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// Client sends operations to the graphql endpoint on url, such as "https://rubbioli.com/fileapi/graphql/"
+type Client struct {
+	url     string
+	token   string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+func New(url string, opts ...Option) *Client {
+	c := &Client{
+		url:     url,
+		http:    http.DefaultClient,
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Do sends query with its variables and decodes the data of the answer into result, the first
+// error answered is returned as an *Error
+func (c *Client) Do(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+
+	return c.retry(ctx, func() error {
+		return c.send(ctx, "application/json", bytes.NewReader(body), result)
+	})
+}
+
+// retry calls fn until it does not fail with a retryable error or there are no retries left
+func (c *Client) retry(ctx context.Context, fn func() error) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= c.retries || !retryable(err) {
+			return err
+		}
+
+		wait := backoff
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, contentType string, body io.Reader, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, body)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", contentType)
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var answer struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string                 `json:"message"`
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&answer); err != nil {
+		return statusError(response)
+	}
+
+	if len(answer.Errors) > 0 {
+		apiErr := newError(answer.Errors[0].Message, answer.Errors[0].Extensions)
+		apiErr.StatusCode = response.StatusCode
+		return apiErr
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return statusError(response)
+	}
+
+	return json.Unmarshal(answer.Data, result)
+}
+
+// statusError is the error of responses that are not graphql
+func statusError(response *http.Response) *Error {
+	err := &Error{
+		Message:    fmt.Sprintf("unexpected response with status %s", response.Status),
+		StatusCode: response.StatusCode,
+	}
+
+	switch response.StatusCode {
+	case http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+		err.Code = CodeServiceUnavailable
+	case http.StatusUnauthorized:
+		err.Code = CodeUnauthorized
+	}
+
+	return err
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	api "github.com/rafaelrubbioli/fileapi/pkg/http"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	handler, err := api.NewServer(serviceMock)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// answers downloads from the bucket, everything else goes to the server
+	httpClient := &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Host, "amazonaws.com") {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("hello")), Request: r}, nil
+		}
+
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	c := New(server.URL+"/graphql/", WithHTTPClient(httpClient), WithRetries(2, time.Millisecond))
+	ctx := context.Background()
+	file := &entity.File{ID: "1/docs/a.txt", User: 1, Path: "docs", Name: "a.txt", ContentType: "text/plain", Size: 5, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	id := "MS9kb2NzL2EudHh0"
+
+	t.Run("upload", func(t *testing.T) {
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 5, "a.txt", "docs", "text/plain; charset=utf-8", gomock.Any(), true).
+			DoAndReturn(func(_, _, _, _, _, _ interface{}, content io.Reader, _ bool) (*entity.File, error) {
+				read, _ := io.ReadAll(content)
+				require.Equal(t, "hello", string(read))
+				return file, nil
+			})
+
+		result, err := c.Upload(ctx, UploadInput{User: 1, Path: "docs", Name: "a.txt", Overwrite: true}, strings.NewReader("hello"))
+		require.NoError(t, err)
+		require.Equal(t, id, result.ID)
+		require.Equal(t, "docs/a.txt", result.FullPath())
+	})
+
+	t.Run("upload retries seekable content from the start", func(t *testing.T) {
+		gomock.InOrder(
+			serviceMock.EXPECT().Create(gomock.Any(), 1, 5, "a.txt", "docs", "text/plain", gomock.Any(), false).Return(nil, errors.New("timeout")),
+			serviceMock.EXPECT().Create(gomock.Any(), 1, 5, "a.txt", "docs", "text/plain", gomock.Any(), false).
+				DoAndReturn(func(_, _, _, _, _, _ interface{}, content io.Reader, _ bool) (*entity.File, error) {
+					read, _ := io.ReadAll(content)
+					require.Equal(t, "hello", string(read))
+					return file, nil
+				}),
+		)
+
+		_, err := c.Upload(ctx, UploadInput{User: 1, Path: "docs", Name: "a.txt", ContentType: "text/plain"}, bytes.NewReader([]byte("hello")))
+		require.NoError(t, err)
+	})
+
+	t.Run("upload does not retry content it can not read again", func(t *testing.T) {
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 5, "a.txt", "docs", "text/plain", gomock.Any(), false).Return(nil, errors.New("timeout"))
+
+		_, err := c.Upload(ctx, UploadInput{User: 1, Path: "docs", Name: "a.txt", ContentType: "text/plain"}, io.LimitReader(strings.NewReader("hello"), 5))
+		require.ErrorIs(t, err, ErrServiceUnavailable)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(file, nil)
+
+		result, err := c.Get(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), result.Size)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(nil, service.ErrNotFound)
+
+		_, err := c.Get(ctx, id)
+		require.ErrorIs(t, err, ErrNotFound)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, CodeNotFound, apiErr.Code)
+	})
+
+	t.Run("retries service unavailable", func(t *testing.T) {
+		gomock.InOrder(
+			serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(nil, errors.New("timeout")),
+			serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(file, nil),
+		)
+
+		_, err := c.Get(ctx, id)
+		require.NoError(t, err)
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(nil, errors.New("timeout")).Times(3)
+
+		_, err := c.Get(ctx, id)
+		require.ErrorIs(t, err, ErrServiceUnavailable)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return([]*entity.File{file}, nil)
+
+		files, err := c.List(ctx, 1, "docs")
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		serviceMock.EXPECT().Move(gomock.Any(), 2, file.ID, "other/a.txt", true).Return(file, nil)
+
+		_, err := c.Move(ctx, MoveInput{ID: id, User: 2, NewPath: "other/a.txt", Overwrite: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		serviceMock.EXPECT().Delete(gomock.Any(), file.ID).Return(service.ErrDuplicateFile)
+
+		err := c.Delete(ctx, id)
+		require.ErrorIs(t, err, ErrBadRequest)
+	})
+
+	t.Run("download", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(file, nil)
+
+		result, content, err := c.Download(ctx, id)
+		require.NoError(t, err)
+		defer content.Close()
+
+		read, err := io.ReadAll(content)
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(read))
+		require.Equal(t, "a.txt", result.Name)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := New(server.URL+"/graphql/", WithToken("wrong")).Get(ctx, id)
+		require.ErrorIs(t, err, ErrUnauthorized)
+	})
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Code is the code on the extensions of the errors answered by the api, the same as the ones in gqlerror
+type Code string
+
+const (
+	CodeNotFound                Code = "NOT_FOUND"
+	CodeDuplicated              Code = "DUPLICATED"
+	CodeBadRequest              Code = "BAD_REQUEST"
+	CodeUnauthorized            Code = "UNAUTHORIZED"
+	CodeUnsupportedMediaType    Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRateLimited             Code = "RATE_LIMITED"
+	CodePreconditionFailed      Code = "PRECONDITION_FAILED"
+	CodeServiceUnavailable      Code = "SERVICE_UNAVAILABLE"
+	CodeQueryNotAllowed         Code = "QUERY_NOT_ALLOWED"
+	CodeDepthLimitExceeded      Code = "DEPTH_LIMIT_EXCEEDED"
+	CodeComplexityLimitExceeded Code = "COMPLEXITY_LIMIT_EXCEEDED"
+)
+
+// Errors with only a code, to compare errors from the api with errors.Is
+var (
+	ErrNotFound             = &Error{Code: CodeNotFound}
+	ErrDuplicated           = &Error{Code: CodeDuplicated}
+	ErrBadRequest           = &Error{Code: CodeBadRequest}
+	ErrUnauthorized         = &Error{Code: CodeUnauthorized}
+	ErrUnsupportedMediaType = &Error{Code: CodeUnsupportedMediaType}
+	ErrRateLimited          = &Error{Code: CodeRateLimited}
+	ErrPreconditionFailed   = &Error{Code: CodePreconditionFailed}
+	ErrServiceUnavailable   = &Error{Code: CodeServiceUnavailable}
+	ErrQueryNotAllowed      = &Error{Code: CodeQueryNotAllowed}
+)
+
+// Error is an error answered by the api
+type Error struct {
+	Code    Code
+	Message string
+	// StatusCode is the http status of responses that are not graphql, such as invalid tokens
+	StatusCode int
+	// RetryAfter is how long to wait before retrying rate limited requests
+	RetryAfter time.Duration
+	Extensions map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+}
+
+// Is matches errors with the same code, so errors.Is(err, ErrNotFound) tells if a file was not found
+func (e *Error) Is(target error) bool {
+	var other *Error
+	return errors.As(target, &other) && other.Code != "" && other.Code == e.Code
+}
+
+// retryable errors are the ones that may go away by trying again later
+func retryable(err error) bool {
+	return errors.Is(err, ErrServiceUnavailable) || errors.Is(err, ErrRateLimited)
+}
+
+func newError(message string, extensions map[string]interface{}) *Error {
+	err := &Error{Message: message, Extensions: extensions}
+	if code, ok := extensions["code"].(string); ok {
+		err.Code = Code(code)
+	}
+
+	if seconds, ok := extensions["retryAfter"].(float64); ok {
+		err.RetryAfter = time.Duration(seconds * float64(time.Second))
+	}
+
+	return err
+}
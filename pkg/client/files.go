@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"time"
+)
+
+const fileFields = "id name path user fileType size createdAt updatedAt downloadURL scanStatus checksum etag"
+
+type File struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Path        string    `json:"path"`
+	User        int       `json:"user"`
+	FileType    string    `json:"fileType"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	DownloadURL string    `json:"downloadURL"`
+	// ScanStatus is PENDING, CLEAN, INFECTED or FAILED, empty when uploads are not scanned
+	ScanStatus string `json:"scanStatus,omitempty"`
+	// Checksum is the hex md5 of the content, empty when it is not known
+	Checksum string `json:"checksum,omitempty"`
+	// ETag is the version of the content, sent as IfMatch to change the file only while it has it
+	ETag string `json:"etag,omitempty"`
+}
+
+// FullPath is where the file is for its user, "{path}/{name}"
+func (f File) FullPath() string {
+	if f.Path == "" {
+		return f.Name
+	}
+
+	return f.Path + "/" + f.Name
+}
+
+type UploadInput struct {
+	// User owns the file
+	User int
+	// Path is the destination path, without the name
+	Path string
+	Name string
+	// ContentType is guessed from the extension of the name when empty
+	ContentType string
+	// Overwrite replaces a file with the same name on the path instead of failing with ErrBadRequest
+	Overwrite bool
+	// IfMatch only replaces the file on the path while it has this etag, failing with ErrPreconditionFailed
+	IfMatch string
+	// IdempotencyKey makes uploads sent again with it return the first uploaded file instead of uploading again
+	IdempotencyKey string
+}
+
+type MoveInput struct {
+	ID string
+	// User owns the file after the move
+	User int
+	// NewPath is the new path of the file, with its name
+	NewPath   string
+	Overwrite bool
+	// IfMatch only moves the file while it has this etag, failing with ErrPreconditionFailed
+	IfMatch string
+	// IdempotencyKey makes moves sent again with it return the first moved file instead of moving again
+	IdempotencyKey string
+}
+
+// Upload sends content as a new file, following the graphql multipart request spec. Uploads are
+// only retried when content is an io.Seeker, such as an *os.File, so it can be sent again.
+func (c *Client) Upload(ctx context.Context, input UploadInput, content io.Reader) (*File, error) {
+	variables := map[string]interface{}{"file": nil, "user": input.User, "path": input.Path, "overwrite": input.Overwrite}
+	if input.IfMatch != "" {
+		variables["ifMatch"] = input.IfMatch
+	}
+	if input.IdempotencyKey != "" {
+		variables["idempotencyKey"] = input.IdempotencyKey
+	}
+
+	operations, err := json.Marshal(map[string]interface{}{
+		"query":     "mutation ($input: UploadInput!) { upload(input: $input) { " + fileFields + " } }",
+		"variables": map[string]interface{}{"input": variables},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := input.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(input.Name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	seeker, canRetry := content.(io.Seeker)
+	var start int64
+	if canRetry {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			canRetry = false
+		}
+	}
+
+	var result struct {
+		Upload *File `json:"upload"`
+	}
+	attempt := 0
+	send := func() error {
+		if attempt++; attempt > 1 {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+
+		reader, writer := io.Pipe()
+		form := multipart.NewWriter(writer)
+		go func() {
+			writer.CloseWithError(writeUpload(form, operations, input.Name, contentType, content))
+		}()
+
+		err := c.send(ctx, form.FormDataContentType(), reader, &result)
+		// unblocks the writer when the request failed before sending the whole content
+		reader.CloseWithError(io.ErrClosedPipe)
+		return err
+	}
+
+	if canRetry {
+		err = c.retry(ctx, send)
+	} else {
+		err = send()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Upload, nil
+}
+
+func writeUpload(form *multipart.Writer, operations []byte, name, contentType string, content io.Reader) error {
+	if err := form.WriteField("operations", string(operations)); err != nil {
+		return err
+	}
+
+	if err := form.WriteField("map", `{"0": ["variables.input.file"]}`); err != nil {
+		return err
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="0"; filename=%q`, name))
+	header.Set("Content-Type", contentType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(part, content); err != nil {
+		return err
+	}
+
+	return form.Close()
+}
+
+func (c *Client) Get(ctx context.Context, id string) (*File, error) {
+	var result struct {
+		File *File `json:"file"`
+	}
+	if err := c.Do(ctx, "query ($id: String!) { file(id: $id) { "+fileFields+" } }", map[string]interface{}{"id": id}, &result); err != nil {
+		return nil, err
+	}
+
+	return result.File, nil
+}
+
+// List returns the files of user under prefix, every file of the user when it is empty
+func (c *Client) List(ctx context.Context, user int, prefix string) ([]*File, error) {
+	var result struct {
+		Files []*File `json:"listUserFiles"`
+	}
+	err := c.Do(ctx, "query ($user: Int!, $prefix: String) { listUserFiles(user: $user, pathPrefix: $prefix) { "+fileFields+" } }",
+		map[string]interface{}{"user": user, "prefix": prefix}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Files, nil
+}
+
+func (c *Client) Move(ctx context.Context, input MoveInput) (*File, error) {
+	variables := map[string]interface{}{"id": input.ID, "user": input.User, "newPath": input.NewPath, "overwrite": input.Overwrite}
+	if input.IfMatch != "" {
+		variables["ifMatch"] = input.IfMatch
+	}
+	if input.IdempotencyKey != "" {
+		variables["idempotencyKey"] = input.IdempotencyKey
+	}
+
+	var result struct {
+		Move *File `json:"move"`
+	}
+	err := c.Do(ctx, "mutation ($input: MoveInput!) { move(input: $input) { "+fileFields+" } }", map[string]interface{}{"input": variables}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Move, nil
+}
+
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.DeleteIfMatch(ctx, id, "")
+}
+
+// DeleteIfMatch deletes the file only while it has etag, failing with ErrPreconditionFailed
+// otherwise. An empty etag deletes it whatever it has.
+func (c *Client) DeleteIfMatch(ctx context.Context, id, etag string) error {
+	variables := map[string]interface{}{"id": id}
+	if etag != "" {
+		variables["ifMatch"] = etag
+	}
+
+	var result struct {
+		Delete bool `json:"delete"`
+	}
+	return c.Do(ctx, "mutation ($id: String!, $ifMatch: String) { delete(id: $id, ifMatch: $ifMatch) }", variables, &result)
+}
+
+// Download returns the file with id and its content from the download url, which must be closed
+func (c *Client) Download(ctx context.Context, id string) (*File, io.ReadCloser, error) {
+	file, err := c.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := c.Content(ctx, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, content, nil
+}
+
+// Content opens the content of file from its download url, it must be closed
+func (c *Client) Content(ctx context.Context, file *File) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, file.DownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, statusError(response)
+	}
+
+	return response.Body, nil
+}
@@ -1,6 +1,12 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 const (
 	Production  = "production"
@@ -8,6 +14,12 @@ const (
 	Test        = "test"
 )
 
+const (
+	AuditSQLite    = "sqlite"
+	AuditJSONLines = "jsonl"
+	AuditNone      = "none"
+)
+
 const (
 	BucketName = "fileapi"
 	AwsRegion  = "sa-east-1"
@@ -30,6 +42,18 @@ func init() {
 	viper.SetDefault("http_port", 5555)
 	viper.SetDefault("base_url", "https://rubbioli.com/fileapi/graphql")
 	viper.SetDefault("file_max_size", 500)
+	viper.SetDefault("database_path", "fileapi.db")
+	viper.SetDefault("quarantine_prefix", "quarantine")
+	viper.SetDefault("audit_sink", AuditSQLite)
+	viper.SetDefault("audit_file", "audit.jsonl")
+	viper.SetDefault("max_query_complexity", 1000)
+	viper.SetDefault("max_query_depth", 10)
+	viper.SetDefault("apq_cache_size", 1000)
+	viper.SetDefault("archive_max_size", 100<<20)
+	viper.SetDefault("archive_max_entries", 1000)
+	viper.SetDefault("batch_max_size", 1000)
+	viper.SetDefault("batch_concurrency", 8)
+	viper.SetDefault("idempotency_ttl", "24h")
 }
 
 func Environment() string {
@@ -47,6 +71,11 @@ func Port() int {
 	return viper.GetInt("http_port")
 }
 
+// GRPCPort is where the grpc api listens, 0 disables it
+func GRPCPort() int {
+	return viper.GetInt("grpc_port")
+}
+
 func BaseURL() string {
 	return viper.GetString("base_url")
 }
@@ -54,3 +83,151 @@ func BaseURL() string {
 func MaxUploadFileSize() int {
 	return viper.GetInt("file_max_size")
 }
+
+// DatabasePath is where the embedded database is kept, empty disables it
+func DatabasePath() string {
+	return viper.GetString("database_path")
+}
+
+// ContentTypeRules limits which file types can be uploaded to each path,
+// in the format "prefix=allow:type,type;prefix=deny:type"
+func ContentTypeRules() string {
+	return viper.GetString("content_type_rules")
+}
+
+// ClamdAddress is the host:port or unix:/path of the clamd used to scan uploads, empty disables scanning
+func ClamdAddress() string {
+	return viper.GetString("clamd_address")
+}
+
+// QuarantinePrefix is where uploads are kept until they are scanned clean
+func QuarantinePrefix() string {
+	return viper.GetString("quarantine_prefix")
+}
+
+// ThumbnailSizes are the sizes in pixels generated for uploaded images, thumbnails are disabled by default
+func ThumbnailSizes() []int {
+	var sizes []int
+	for _, value := range strings.Split(viper.GetString("thumbnail_sizes"), ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil && size > 0 {
+			sizes = append(sizes, size)
+		}
+	}
+
+	return sizes
+}
+
+// AuditSink is where uploads, moves and deletes are recorded, AuditSQLite keeps them on the
+// embedded database where they can be searched and AuditJSONLines appends them to AuditFile
+func AuditSink() string {
+	switch sink := viper.GetString("audit_sink"); sink {
+	case AuditJSONLines, AuditNone:
+		return sink
+	default:
+		return AuditSQLite
+	}
+}
+
+// AuditFile is the json lines file used by the AuditJSONLines sink
+func AuditFile() string {
+	return viper.GetString("audit_file")
+}
+
+// AllowedOrigins are the origins browsers can call the api from, empty allows every origin
+func AllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(viper.GetString("allowed_origins"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	return origins
+}
+
+// AdminTokens are the bearer tokens that identify admins, in the format "name:token,name:token"
+func AdminTokens() string {
+	return viper.GetString("admin_tokens")
+}
+
+// TracingExporter is where traces are sent, "otlp" or "stdout", empty disables tracing.
+// The otlp exporter is configured with the standard OTEL_EXPORTER_OTLP_* variables.
+func TracingExporter() string {
+	return viper.GetString("tracing_exporter")
+}
+
+// LogLevel is the minimum level logged, set with LOG_LEVEL or else chosen by the environment
+func LogLevel() string {
+	if level := viper.GetString("log_level"); level != "" {
+		return level
+	}
+
+	switch Environment() {
+	case Development:
+		return "debug"
+	case Test:
+		return "warn"
+	default:
+		return "info"
+	}
+}
+
+// RateLimits are the token bucket limits of each client, in the format "name:rate/burst,name:rate/burst"
+// with rate in requests per second. The default limit applies to every request and the others to the
+// graphql field they are named after, like upload. Empty disables rate limiting.
+func RateLimits() string {
+	return viper.GetString("rate_limits")
+}
+
+// UploadBandwidth is how many bytes per second each client can upload, 0 disables throttling
+func UploadBandwidth() int {
+	return viper.GetInt("upload_bandwidth")
+}
+
+// MaxQueryComplexity is the highest estimated cost of a graphql operation, 0 disables the limit
+func MaxQueryComplexity() int {
+	return viper.GetInt("max_query_complexity")
+}
+
+// MaxQueryDepth is how deep fields of a graphql operation can be nested, 0 disables the limit
+func MaxQueryDepth() int {
+	return viper.GetInt("max_query_depth")
+}
+
+// APQCacheSize is how many automatic persisted queries are kept, 0 disables them
+func APQCacheSize() int {
+	return viper.GetInt("apq_cache_size")
+}
+
+// QueryAllowlist is a json file mapping the sha256 hash of every registered query to it,
+// in production only registered queries can be run. Empty allows any query.
+func QueryAllowlist() string {
+	return viper.GetString("query_allowlist")
+}
+
+// ArchiveMaxSize is how many bytes of files can be downloaded or extracted as one archive
+func ArchiveMaxSize() int64 {
+	return viper.GetInt64("archive_max_size")
+}
+
+// ArchiveMaxEntries is how many files can be downloaded or extracted as one archive
+func ArchiveMaxEntries() int {
+	return viper.GetInt("archive_max_entries")
+}
+
+// BatchMaxSize is how many files can be moved, copied or deleted by one batch mutation
+func BatchMaxSize() int {
+	return viper.GetInt("batch_max_size")
+}
+
+// BatchConcurrency is how many files of a batch mutation are moved or copied at the same time
+func BatchConcurrency() int {
+	return viper.GetInt("batch_concurrency")
+}
+
+// IdempotencyTTL is how long the result of uploads and moves made with an idempotency key is
+// replayed to retries, 0 disables idempotency keys
+func IdempotencyTTL() time.Duration {
+	return viper.GetDuration("idempotency_ttl")
+}
@@ -0,0 +1,143 @@
+package contenttype
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// SniffLength is how many bytes from the start of a file Detect looks at
+const SniffLength = 512
+
+const (
+	OctetStream = "application/octet-stream"
+	plainText   = "text/plain"
+	zip         = "application/zip"
+)
+
+type signature struct {
+	offset      int
+	magic       []byte
+	contentType string
+}
+
+// signatures covers common formats http.DetectContentType doesn't know about
+var signatures = []signature{
+	{0, []byte("\x7FELF"), "application/x-executable"},
+	{0, []byte("MZ"), "application/vnd.microsoft.portable-executable"},
+	{0, []byte("\xFE\xED\xFA\xCE"), "application/x-mach-binary"},
+	{0, []byte("\xFE\xED\xFA\xCF"), "application/x-mach-binary"},
+	{0, []byte("\xCE\xFA\xED\xFE"), "application/x-mach-binary"},
+	{0, []byte("\xCF\xFA\xED\xFE"), "application/x-mach-binary"},
+	{0, []byte("7z\xBC\xAF\x27\x1C"), "application/x-7z-compressed"},
+	{0, []byte("BZh"), "application/x-bzip2"},
+	{0, []byte("\xFD7zXZ\x00"), "application/x-xz"},
+	{0, []byte("\x28\xB5\x2F\xFD"), "application/zstd"},
+	{257, []byte("ustar"), "application/x-tar"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{4, []byte("ftypheic"), "image/heic"},
+	{4, []byte("ftypheix"), "image/heic"},
+	{4, []byte("ftypavif"), "image/avif"},
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("SQLite format 3\x00"), "application/vnd.sqlite3"},
+	{0, []byte("PAR1"), "application/vnd.apache.parquet"},
+}
+
+// refinements are the more specific types a generic sniffed type may be
+// narrowed to based on the file extension or the type the client sent
+var refinements = map[string]map[string]bool{
+	plainText: {
+		"text/plain":                true,
+		"text/markdown":             true,
+		"text/x-markdown":           true,
+		"text/csv":                  true,
+		"application/csv":           true,
+		"text/tab-separated-values": true,
+		"application/json":          true,
+		"application/x-ndjson":      true,
+		"application/yaml":          true,
+		"text/yaml":                 true,
+	},
+	zip: {
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true,
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
+		"application/vnd.oasis.opendocument.text":                                   true,
+		"application/vnd.oasis.opendocument.spreadsheet":                            true,
+		"application/epub+zip":     true,
+		"application/java-archive": true,
+	},
+}
+
+var extensions = map[string]string{
+	".txt":      "text/plain",
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".csv":      "text/csv",
+	".tsv":      "text/tab-separated-values",
+	".json":     "application/json",
+	".ndjson":   "application/x-ndjson",
+	".yaml":     "application/yaml",
+	".yml":      "application/yaml",
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx":     "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":      "application/vnd.oasis.opendocument.text",
+	".ods":      "application/vnd.oasis.opendocument.spreadsheet",
+	".epub":     "application/epub+zip",
+	".jar":      "application/java-archive",
+}
+
+// Detect finds the content type of a file from its first bytes. The declared
+// type and the file name are only used to narrow down generic types, such as
+// plain text into csv, never to replace what the content says it is.
+func Detect(head []byte, name, declared string) string {
+	if len(head) > SniffLength {
+		head = head[:SniffLength]
+	}
+
+	detected := sniff(head)
+	base := MediaType(detected)
+	allowed, ok := refinements[base]
+	if !ok {
+		return detected
+	}
+
+	_, params, _ := mime.ParseMediaType(detected)
+	for _, candidate := range []string{MediaType(declared), extensions[strings.ToLower(filepath.Ext(name))]} {
+		if candidate != "" && candidate != base && allowed[candidate] {
+			return mime.FormatMediaType(candidate, params)
+		}
+	}
+
+	return detected
+}
+
+// MediaType strips parameters such as charset from a content type
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
+}
+
+func sniff(head []byte) string {
+	detected := http.DetectContentType(head)
+	if detected != OctetStream {
+		return detected
+	}
+
+	// only binary content is checked, some signatures are short enough to start a text file
+	for _, s := range signatures {
+		if len(head) >= s.offset+len(s.magic) && bytes.Equal(head[s.offset:s.offset+len(s.magic)], s.magic) {
+			return s.contentType
+		}
+	}
+
+	return detected
+}
@@ -0,0 +1,38 @@
+package contenttype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tar := make([]byte, 300)
+	copy(tar[257:], "ustar")
+
+	for _, tc := range []struct {
+		name     string
+		head     []byte
+		filename string
+		declared string
+		expected string
+	}{
+		{"png ignores declared type", []byte("\x89PNG\r\n\x1a\n\x00\x00"), "image.png", "text/plain", "image/png"},
+		{"html declared as text", []byte("<html><body>hi</body></html>"), "page.txt", "text/plain", "text/html; charset=utf-8"},
+		{"plain text", []byte("just some text"), "notes", "", "text/plain; charset=utf-8"},
+		{"markdown from declared type", []byte("# title"), "notes", "text/markdown", "text/markdown; charset=utf-8"},
+		{"csv from extension", []byte("a,b\n1,2"), "data.CSV", "application/octet-stream", "text/csv; charset=utf-8"},
+		{"text cannot become html", []byte("hello"), "page.html", "text/html", "text/plain; charset=utf-8"},
+		{"docx from zip", []byte("PK\x03\x04\x14\x00"), "report.docx", "", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"zip stays zip", []byte("PK\x03\x04\x14\x00"), "archive.zip", "image/png", "application/zip"},
+		{"elf executable", []byte("\x7FELF\x02\x01\x01\x00"), "file.txt", "text/plain", "application/x-executable"},
+		{"windows executable", []byte("MZ\x90\x00\x03\x00"), "setup.exe", "", "application/vnd.microsoft.portable-executable"},
+		{"text starting like a signature", []byte("MZ is not an executable"), "mz.txt", "", "text/plain; charset=utf-8"},
+		{"tar", tar, "backup.tar", "", "application/x-tar"},
+		{"unknown binary", []byte{0x00, 0x01, 0x02}, "blob.json", "application/json", OctetStream},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Detect(tc.head, tc.filename, tc.declared))
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package contenttype
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Rule struct {
+	// Prefix of the upload path the rule applies to, empty applies everywhere
+	Prefix string
+	Allow  []string
+	Deny   []string
+}
+
+// Rules decides which content types can be uploaded to each path. Deny lists
+// apply to every path under their prefix, while only the allow list of the
+// longest matching prefix is used. Paths without an allow list accept anything
+// that is not denied.
+type Rules []Rule
+
+// ParseRules reads rules in the format "prefix=allow:type,type;prefix=deny:type".
+// Types may use wildcards such as image/*.
+func ParseRules(value string) (Rules, error) {
+	byPrefix := map[string]*Rule{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		prefix, list, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid content type rule %q: missing '='", entry)
+		}
+
+		kind, types, ok := strings.Cut(list, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid content type rule %q: missing ':'", entry)
+		}
+
+		prefix = cleanPrefix(prefix)
+		rule, ok := byPrefix[prefix]
+		if !ok {
+			rule = &Rule{Prefix: prefix}
+			byPrefix[prefix] = rule
+		}
+
+		patterns := splitTypes(types)
+		switch strings.TrimSpace(kind) {
+		case "allow":
+			rule.Allow = append(rule.Allow, patterns...)
+		case "deny":
+			rule.Deny = append(rule.Deny, patterns...)
+		default:
+			return nil, fmt.Errorf("invalid content type rule %q: expected allow or deny", entry)
+		}
+	}
+
+	rules := make(Rules, 0, len(byPrefix))
+	for _, rule := range byPrefix {
+		rules = append(rules, *rule)
+	}
+
+	// longest prefixes first, so the first allow list found is the most specific
+	sort.Slice(rules, func(i, j int) bool {
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+
+	return rules, nil
+}
+
+func (r Rules) Allowed(path, contentType string) bool {
+	path = cleanPrefix(path) + "/"
+	mediaType := MediaType(contentType)
+
+	checkedAllow := false
+	for _, rule := range r {
+		if rule.Prefix != "" && !strings.HasPrefix(path, rule.Prefix+"/") {
+			continue
+		}
+
+		if matchAny(rule.Deny, mediaType) {
+			return false
+		}
+
+		if !checkedAllow && len(rule.Allow) > 0 {
+			if !matchAny(rule.Allow, mediaType) {
+				return false
+			}
+
+			checkedAllow = true
+		}
+	}
+
+	return true
+}
+
+func matchAny(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*/*" || pattern == mediaType {
+			return true
+		}
+
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitTypes(value string) []string {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	return types
+}
+
+func cleanPrefix(prefix string) string {
+	return strings.Trim(strings.TrimSpace(prefix), "/")
+}
@@ -0,0 +1,47 @@
+package contenttype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		rules, err := ParseRules(" /=deny:application/x-executable ; images/=allow:image/*,Video/MP4;images/=deny:image/svg+xml")
+		require.NoError(t, err)
+		require.Equal(t, Rules{
+			{Prefix: "images", Allow: []string{"image/*", "video/mp4"}, Deny: []string{"image/svg+xml"}},
+			{Prefix: "", Deny: []string{"application/x-executable"}},
+		}, rules)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		rules, err := ParseRules("")
+		require.NoError(t, err)
+		require.Empty(t, rules)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, value := range []string{"images", "images=image/png", "images=only:image/png"} {
+			_, err := ParseRules(value)
+			require.Error(t, err, value)
+		}
+	})
+}
+
+func TestRules_Allowed(t *testing.T) {
+	rules, err := ParseRules("=deny:application/x-executable;images=allow:image/*;images/raw=allow:image/tiff,application/octet-stream;images=deny:image/svg+xml")
+	require.NoError(t, err)
+
+	require.True(t, rules.Allowed("docs", "application/pdf"))
+	require.False(t, rules.Allowed("docs", "application/x-executable"))
+	require.True(t, rules.Allowed("images/cats", "image/png"))
+	require.False(t, rules.Allowed("images/cats", "text/plain; charset=utf-8"))
+	require.False(t, rules.Allowed("images/cats", "image/svg+xml"))
+	require.True(t, rules.Allowed("/images/raw/2021/", "application/octet-stream"))
+	require.False(t, rules.Allowed("images/raw", "image/png"))
+	require.False(t, rules.Allowed("images/raw", "image/svg+xml"))
+	require.True(t, rules.Allowed("imagesbackup", "text/plain"))
+	require.True(t, Rules(nil).Allowed("anything", "application/x-executable"))
+}
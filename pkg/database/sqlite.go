@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	// registers the pure go "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+const Memory = ":memory:"
+
+// Open opens the embedded SQLite database at path. Passing Memory returns a
+// private in memory database, mostly useful for tests.
+func Open(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	if path == Memory {
+		dsn = "file::memory:?_pragma=foreign_keys(1)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if path == Memory {
+		// every new connection to :memory: would get its own empty database
+		db.SetMaxOpenConns(1)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
@@ -2,6 +2,21 @@ package entity
 
 import "time"
 
+type ScanStatus string
+
+const (
+	ScanPending  ScanStatus = "PENDING"
+	ScanClean    ScanStatus = "CLEAN"
+	ScanInfected ScanStatus = "INFECTED"
+	// ScanFailed files could not be scanned, they stay quarantined until uploaded again
+	ScanFailed ScanStatus = "FAILED"
+)
+
+// Quarantined reports if files with the status are kept private, where they can't be read
+func (s ScanStatus) Quarantined() bool {
+	return s == ScanPending || s == ScanInfected || s == ScanFailed
+}
+
 type File struct {
 	ID          string
 	Name        string
@@ -11,6 +26,12 @@ type File struct {
 	ContentType string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	// ScanStatus is empty for files uploaded without a scanner
+	ScanStatus ScanStatus
+	// Checksum is the hex md5 of the content, empty when it is not known
+	Checksum string
+	// ETag identifies the version of the content, it changes along with it
+	ETag string
 }
 
 func (e *File) IsEmpty() bool {
@@ -0,0 +1,106 @@
+package event
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+)
+
+type Type string
+
+const (
+	Created Type = "CREATED"
+	Moved   Type = "MOVED"
+	Deleted Type = "DELETED"
+	Updated Type = "UPDATED"
+)
+
+// bufferSize is how many events a subscriber can fall behind before missing events
+const bufferSize = 64
+
+type Event struct {
+	Type Type
+	File entity.File
+	// OldID is where moved files were before
+	OldID string
+	Time  time.Time
+}
+
+// In reports if the event concerns files of user under prefix, moves count on both ends.
+// A nil user matches files of every user.
+func (e Event) In(user *int, prefix string) bool {
+	return inPath(e.File.ID, user, prefix) || inPath(e.OldID, user, prefix)
+}
+
+func inPath(key string, user *int, prefix string) bool {
+	owner := strings.SplitN(key, "/", 2)
+	if len(owner) != 2 || (user != nil && owner[0] != strconv.Itoa(*user)) {
+		return false
+	}
+
+	return strings.HasPrefix(owner[1], strings.TrimPrefix(path.Clean("/"+prefix), "/"))
+}
+
+// Bus fans out events to every subscriber without ever blocking publishers.
+// A nil Bus drops everything published to it.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: map[chan Event]struct{}{},
+	}
+}
+
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			slog.Warn("dropped event, subscriber is too slow", "type", event.Type, "key", event.File.ID)
+		}
+	}
+}
+
+// Subscribe receives every event published until ctx is done, when the channel is closed
+func (b *Bus) Subscribe(ctx context.Context) <-chan Event {
+	events := make(chan Event, bufferSize)
+	if b == nil {
+		close(events)
+		return events
+	}
+
+	b.mu.Lock()
+	b.subscribers[events] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.subscribers, events)
+		b.mu.Unlock()
+		close(events)
+	}()
+
+	return events
+}
@@ -0,0 +1,90 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("every subscriber receives events", func(t *testing.T) {
+		bus := NewBus()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		first, second := bus.Subscribe(ctx), bus.Subscribe(ctx)
+		bus.Publish(Event{Type: Created, File: entity.File{ID: "1/test.txt"}})
+
+		for _, events := range []<-chan Event{first, second} {
+			event := <-events
+			require.Equal(t, Created, event.Type)
+			require.Equal(t, "1/test.txt", event.File.ID)
+			require.False(t, event.Time.IsZero())
+		}
+
+		cancel()
+		_, open := <-first
+		require.False(t, open)
+	})
+
+	t.Run("slow subscribers do not block", func(t *testing.T) {
+		bus := NewBus()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events := bus.Subscribe(ctx)
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < bufferSize*2; i++ {
+				bus.Publish(Event{Type: Updated})
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("publish blocked")
+		}
+
+		require.Len(t, events, bufferSize)
+	})
+
+	t.Run("nil bus", func(t *testing.T) {
+		var bus *Bus
+		bus.Publish(Event{Type: Deleted})
+
+		_, open := <-bus.Subscribe(context.Background())
+		require.False(t, open)
+	})
+}
+
+func TestEvent_In(t *testing.T) {
+	user, other := 1, 2
+	move := Event{Type: Moved, File: entity.File{ID: "1/images/new.png"}, OldID: "1/docs/old.png"}
+
+	tests := []struct {
+		name   string
+		event  Event
+		user   *int
+		prefix string
+		in     bool
+	}{
+		{name: "user root", event: move, user: &user, in: true},
+		{name: "new path", event: move, user: &user, prefix: "images", in: true},
+		{name: "old path", event: move, user: &user, prefix: "/docs/", in: true},
+		{name: "other path", event: move, user: &user, prefix: "videos", in: false},
+		{name: "other user", event: move, user: &other, in: false},
+		{name: "any user", event: move, prefix: "images", in: true},
+		{name: "prefix of the user id", event: Event{File: entity.File{ID: "10/a.txt"}}, user: &user, in: false},
+		{name: "invalid key", event: Event{File: entity.File{ID: "a.txt"}}, in: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.in, test.event.In(test.user, test.prefix))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package complexity
+
+import (
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlgen"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+)
+
+// unboundedListSize is how many items are expected on lists that are not paginated
+const unboundedListSize = 20
+
+// Root estimates the cost of list fields as the cost of one item times how many items they
+// can return, every other field costs 1 plus the cost of its children
+func Root() gqlgen.ComplexityRoot {
+	root := gqlgen.ComplexityRoot{}
+
+	root.Query.ListUserFiles = func(childComplexity int, _ int, _ *string) int {
+		return list(childComplexity, unboundedListSize)
+	}
+	root.Query.FileTree = func(childComplexity int) int {
+		return list(childComplexity, unboundedListSize)
+	}
+	root.Query.SearchFiles = func(childComplexity int, _ *model.FileFilter, _ *model.FileSort, page *model.PageInput) int {
+		return list(childComplexity, pageSize(page))
+	}
+	root.Query.SearchContent = func(childComplexity int, _ string, _ *int) int {
+		return list(childComplexity, index.DefaultPageSize)
+	}
+	root.Query.Webhooks = func(childComplexity int) int {
+		return list(childComplexity, unboundedListSize)
+	}
+	root.Query.WebhookDeliveries = func(childComplexity int, _ *int, _ *model.WebhookDeliveryStatus, limit int) int {
+		return list(childComplexity, limit)
+	}
+	root.Query.AuditLog = func(childComplexity int, _ *model.AuditFilter, page *model.PageInput) int {
+		return list(childComplexity, pageSize(page))
+	}
+	root.Dir.Files = func(childComplexity int) int {
+		return list(childComplexity, unboundedListSize)
+	}
+	root.Dir.Dirs = func(childComplexity int) int {
+		return list(childComplexity, unboundedListSize)
+	}
+
+	return root
+}
+
+func list(childComplexity, size int) int {
+	if size <= 0 {
+		size = 1
+	}
+
+	return 1 + childComplexity*size
+}
+
+func pageSize(page *model.PageInput) int {
+	if page == nil || page.Limit <= 0 {
+		return index.DefaultPageSize
+	}
+
+	return page.Limit
+}
@@ -0,0 +1,84 @@
+package complexity_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func TestLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	handler := graphql.NewHandler(serviceMock, nil, nil)
+
+	send := func(query string) response {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		request := httptest.NewRequest(http.MethodPost, "/graphql/", strings.NewReader(string(body)))
+		request.Header.Set("Content-Type", "application/json")
+		// admins can use introspection in production
+		request = request.WithContext(auth.WithIdentity(request.Context(), auth.Identity{Name: "admin", Admin: true}))
+		written := httptest.NewRecorder()
+		handler.Handle(written, request)
+
+		var result response
+		require.NoError(t, json.Unmarshal(written.Body.Bytes(), &result))
+		return result
+	}
+
+	t.Run("within limits", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "").Return([]*entity.File{{ID: "1/a.txt"}}, nil)
+		result := send(`{ listUserFiles(user: 1) { id name } }`)
+		require.Empty(t, result.Errors)
+	})
+
+	t.Run("too complex", func(t *testing.T) {
+		result := send(`{ fileTree { dirs { dirs { files { id } } } } }`)
+		require.Len(t, result.Errors, 1)
+		require.Equal(t, "operation has complexity 168421, which exceeds the limit of 1000", result.Errors[0].Message)
+		require.Equal(t, string(gqlerror.ComplexityLimitExceededType), result.Errors[0].Extensions["code"])
+		require.Equal(t, float64(168421), result.Errors[0].Extensions["complexity"])
+		require.Equal(t, float64(1000), result.Errors[0].Extensions["limit"])
+	})
+
+	t.Run("page size is the cost of search", func(t *testing.T) {
+		result := send(`{ searchFiles(page: {limit: 500}) { total files { id name size } } }`)
+		require.Len(t, result.Errors, 1)
+		require.Equal(t, string(gqlerror.ComplexityLimitExceededType), result.Errors[0].Extensions["code"])
+
+		serviceMock.EXPECT().Search(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]*entity.File{}, 0, nil)
+		result = send(`{ searchFiles { total files { id name size } } }`)
+		require.Empty(t, result.Errors, "default page size")
+	})
+
+	t.Run("too deep", func(t *testing.T) {
+		result := send(`{ fileTree { ...dirs } } fragment dirs on Dir { dirs { dirs { dirs { dirs { dirs { dirs { dirs { dirs { dirs { path } } } } } } } } } }`)
+		require.Len(t, result.Errors, 1)
+		require.Equal(t, "operation has depth 11, which exceeds the limit of 10", result.Errors[0].Message)
+		require.Equal(t, string(gqlerror.DepthLimitExceededType), result.Errors[0].Extensions["code"])
+	})
+
+	t.Run("introspection is not limited", func(t *testing.T) {
+		result := send(`{ __schema { types { fields { type { ofType { ofType { ofType { ofType { ofType { ofType { ofType { name } } } } } } } } } } } }`)
+		require.Empty(t, result.Errors)
+	})
+}
@@ -0,0 +1,64 @@
+package complexity
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/vektah/gqlparser/v2/ast"
+	parsererror "github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// DepthLimit rejects operations that nest fields deeper than Limit. Introspection is not
+// limited, the query used by tools to read the schema nests types deeper than any operation should.
+type DepthLimit struct {
+	Limit int
+}
+
+func (DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (DepthLimit) Validate(_ graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d DepthLimit) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *parsererror.Error {
+	if rc.Operation == nil {
+		return nil
+	}
+
+	if depth := Depth(rc.Operation.SelectionSet); depth > d.Limit {
+		return gqlerror.DepthLimitExceeded(depth, d.Limit)
+	}
+
+	return nil
+}
+
+// Depth is how many fields deep selections go, fragments do not count as a level
+func Depth(selections ast.SelectionSet) int {
+	deepest := 0
+	for _, selection := range selections {
+		depth := 0
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if selection.Name == "__schema" || selection.Name == "__type" {
+				continue
+			}
+
+			depth = 1 + Depth(selection.SelectionSet)
+		case *ast.InlineFragment:
+			depth = Depth(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			if selection.Definition != nil {
+				depth = Depth(selection.Definition.SelectionSet)
+			}
+		}
+
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+
+	return deepest
+}
@@ -0,0 +1,37 @@
+package complexity
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	parsererror "github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Limit rejects operations that cost more than Limit, using the estimates of Root
+type Limit struct {
+	Limit  int
+	schema graphql.ExecutableSchema
+}
+
+func (Limit) ExtensionName() string {
+	return "ComplexityLimit"
+}
+
+func (l *Limit) Validate(schema graphql.ExecutableSchema) error {
+	l.schema = schema
+	return nil
+}
+
+func (l *Limit) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *parsererror.Error {
+	if rc.Operation == nil {
+		return nil
+	}
+
+	if cost := complexity.Calculate(l.schema, rc.Operation, rc.Variables); cost > l.Limit {
+		return gqlerror.ComplexityLimitExceeded(cost, l.Limit)
+	}
+
+	return nil
+}
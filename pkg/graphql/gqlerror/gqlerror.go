@@ -1,9 +1,12 @@
 package gqlerror
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
 	"github.com/rafaelrubbioli/fileapi/pkg/service"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
@@ -16,6 +19,28 @@ var (
 	ErrNotYetSupported    = newTyped("not yet supported", ServiceUnavailableType)
 	ErrNotFound           = newTyped("not found", NotFoundType)
 	ErrDuplicateFile      = newTyped("file already exists on path", BadRequestType)
+	ErrInvalidPage        = newTyped("limit and offset cannot be negative", BadRequestType)
+	ErrSearchUnavailable  = newTyped("search index is not enabled", ServiceUnavailableType)
+	ErrContentType        = newTyped("file type is not allowed on this path", UnsupportedMediaTypeType)
+	ErrNotScanned         = newTyped("file is quarantined until it is scanned clean", BadRequestType)
+	ErrReservedPath       = newTyped("path cannot contain '.thumbnails'", BadRequestType)
+	ErrWebhooksDisabled   = newTyped("webhooks are not enabled", ServiceUnavailableType)
+	ErrInvalidWebhook     = newTyped("webhook url must be an absolute http or https url to a public address", BadRequestType)
+	ErrAdminOnly          = newTyped("only admins can do this", UnauthorizedType)
+	ErrAuditUnavailable   = newTyped("audit log is not searchable", ServiceUnavailableType)
+	ErrQueryNotAllowed    = newTyped("only registered queries are allowed", QueryNotAllowedType)
+	ErrInvalidBody        = newTyped("invalid request body", BadRequestType)
+	ErrInvalidUser        = newTyped("user must be a number", BadRequestType)
+	ErrInvalidArchive     = newTyped("either ids or user is required", BadRequestType)
+	ErrArchiveFormat      = newTyped("archive format must be zip or tar.gz", BadRequestType)
+	ErrArchiveTooBig      = newTyped("files are too big for one archive", BadRequestType)
+	ErrArchiveEntries     = newTyped("too many files for one archive", BadRequestType)
+	ErrInvalidArchiveFile = newTyped("file is not a valid zip or tar.gz archive", BadRequestType)
+	ErrNotRegularFile     = newTyped("only regular files can be extracted", BadRequestType)
+	ErrBatchTooBig        = newTyped("too many files for one batch", BadRequestType)
+	ErrPreconditionFailed = newTyped("file has changed since it was read", PreconditionFailedType)
+	ErrIdempotencyReused  = newTyped("idempotency key was already used for another request", BadRequestType)
+	ErrIdempotencyKey     = newTyped("idempotency key is too long", BadRequestType)
 )
 
 type ErrorType string
@@ -23,28 +48,76 @@ type ErrorType string
 const ErrCodeLabel = "code"
 
 var (
-	DuplicatedType         ErrorType = "DUPLICATED"
-	NotFoundType           ErrorType = "NOT_FOUND"
-	ServiceUnavailableType ErrorType = "SERVICE_UNAVAILABLE"
-	UnauthorizedType       ErrorType = "UNAUTHORIZED"
-	BadRequestType         ErrorType = "BAD_REQUEST"
+	DuplicatedType              ErrorType = "DUPLICATED"
+	NotFoundType                ErrorType = "NOT_FOUND"
+	ServiceUnavailableType      ErrorType = "SERVICE_UNAVAILABLE"
+	UnauthorizedType            ErrorType = "UNAUTHORIZED"
+	BadRequestType              ErrorType = "BAD_REQUEST"
+	UnsupportedMediaTypeType    ErrorType = "UNSUPPORTED_MEDIA_TYPE"
+	RateLimitedType             ErrorType = "RATE_LIMITED"
+	QueryNotAllowedType         ErrorType = "QUERY_NOT_ALLOWED"
+	PreconditionFailedType      ErrorType = "PRECONDITION_FAILED"
+	ComplexityLimitExceededType ErrorType = "COMPLEXITY_LIMIT_EXCEEDED"
+	DepthLimitExceededType      ErrorType = "DEPTH_LIMIT_EXCEEDED"
 )
 
 var errorMap = map[error]error{
-	service.ErrInvalidKey:    ErrInvalidID,
-	service.ErrNotFound:      ErrNotFound,
-	service.ErrDuplicateFile: ErrDuplicateFile,
+	service.ErrInvalidKey:            ErrInvalidID,
+	service.ErrInvalidPath:           ErrInvalidPath,
+	service.ErrNotFound:              ErrNotFound,
+	service.ErrDuplicateFile:         ErrDuplicateFile,
+	service.ErrNoIndex:               ErrSearchUnavailable,
+	service.ErrContentTypeNotAllowed: ErrContentType,
+	service.ErrNotScanned:            ErrNotScanned,
+	service.ErrReservedPath:          ErrReservedPath,
+	service.ErrNoWebhooks:            ErrWebhooksDisabled,
+	service.ErrInvalidWebhook:        ErrInvalidWebhook,
+	service.ErrNoAuditLog:            ErrAuditUnavailable,
+	service.ErrPreconditionFailed:    ErrPreconditionFailed,
+	service.ErrIdempotencyKeyReused:  ErrIdempotencyReused,
+	service.ErrInvalidIdempotencyKey: ErrIdempotencyKey,
 }
 
-func Error(err error) error {
+func Error(ctx context.Context, err error) error {
 	if newErr, ok := errorMap[err]; ok {
 		return newErr
 	}
 
-	log.Println(err)
+	var limited middleware.RateLimitedError
+	if errors.As(err, &limited) {
+		return RateLimited(limited)
+	}
+
+	logging.Error(ctx, "unexpected service error", "error", err)
+
 	return ErrServiceUnavailable
 }
 
+// RateLimited tells the client how many seconds to wait before retrying on the retryAfter extension
+func RateLimited(err middleware.RateLimitedError) *gqlerror.Error {
+	result := newTyped("rate limited, retry in %d seconds", RateLimitedType, err.RetryAfterSeconds())
+	result.Extensions["retryAfter"] = err.RetryAfterSeconds()
+	return result
+}
+
+// ComplexityLimitExceeded tells the client the cost of the operation and the most it can cost
+// on the complexity and limit extensions
+func ComplexityLimitExceeded(complexity, limit int) *gqlerror.Error {
+	result := newTyped("operation has complexity %d, which exceeds the limit of %d", ComplexityLimitExceededType, complexity, limit)
+	result.Extensions["complexity"] = complexity
+	result.Extensions["limit"] = limit
+	return result
+}
+
+// DepthLimitExceeded tells the client how deep the operation goes and the deepest it can go
+// on the depth and limit extensions
+func DepthLimitExceeded(depth, limit int) *gqlerror.Error {
+	result := newTyped("operation has depth %d, which exceeds the limit of %d", DepthLimitExceededType, depth, limit)
+	result.Extensions["depth"] = depth
+	result.Extensions["limit"] = limit
+	return result
+}
+
 func new(message string, params ...interface{}) *gqlerror.Error {
 	err := &gqlerror.Error{
 		Message: message,
@@ -4,16 +4,27 @@ package graphql
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/complexity"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlgen"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/introspection"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/persisted"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/ratelimit"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/resolver"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/metrics"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
 	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	"github.com/rafaelrubbioli/fileapi/pkg/tracing"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
 	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
 )
 
 type Handler struct {
@@ -21,19 +32,49 @@ type Handler struct {
 	handle http.HandlerFunc
 }
 
-func NewHandler(service service.Service) Handler {
+// NewHandler serves the graphql api, limiters has the rate limits by field and allowlist the registered
+// queries by hash, nil when any query can be run
+func NewHandler(service service.Service, limiters *middleware.Limiters, allowlist map[string]string) Handler {
 	schema := gqlgen.NewExecutableSchema(gqlgen.Config{
-		Resolvers:  resolver.New(service),
+		Resolvers:  resolver.New(service, limiters.Bandwidth()),
 		Directives: gqlgen.DirectiveRoot{},
+		Complexity: complexity.Root(),
 	})
 
 	server := handler.New(schema)
+	server.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			// browsers don't run cors preflights for websockets, so the origin is checked on the upgrade
+			CheckOrigin: func(r *http.Request) bool {
+				return middleware.AllowedOrigin(r.Header.Get("Origin"))
+			},
+		},
+	})
 	server.AddTransport(transport.Options{})
 	server.AddTransport(transport.GET{})
 	server.AddTransport(transport.POST{})
 	server.AddTransport(transport.MultipartForm{})
 	server.SetQueryCache(lru.New(1000))
-	server.Use(introspection.Introspection{})
+	if allowlist != nil {
+		server.Use(persisted.Allowlist{Queries: allowlist, Enforce: config.Environment() == config.Production})
+	}
+	if size := config.APQCacheSize(); size > 0 {
+		server.Use(extension.AutomaticPersistedQuery{Cache: lru.New(size)})
+	}
+	server.Use(introspection.Introspection{Environment: config.Environment()})
+	if limit := config.MaxQueryDepth(); limit > 0 {
+		server.Use(complexity.DepthLimit{Limit: limit})
+	}
+	if limit := config.MaxQueryComplexity(); limit > 0 {
+		server.Use(&complexity.Limit{Limit: limit})
+	}
+	server.Use(&metrics.Extension{Operations: persisted.OperationNames(allowlist)})
+	server.Use(tracing.Extension{})
+	server.Use(logging.Extension{})
+	if limiters != nil {
+		server.Use(ratelimit.New(limiters))
+	}
 
 	return Handler{
 		Schema: schema,
@@ -44,7 +85,3 @@ func NewHandler(service service.Service) Handler {
 func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	h.handle(w, r)
 }
-
-func (h *Handler) Health(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-}
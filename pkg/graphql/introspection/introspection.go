@@ -4,10 +4,15 @@ import (
 	"context"
 
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
-type Introspection struct{}
+// Introspection allows reading the schema outside of production, where only admins can
+type Introspection struct {
+	Environment string
+}
 
 func (c Introspection) ExtensionName() string {
 	return "Introspection"
@@ -17,7 +22,7 @@ func (c Introspection) Validate(_ graphql.ExecutableSchema) error {
 	return nil
 }
 
-func (c Introspection) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *gqlerror.Error {
-	rc.DisableIntrospection = false
+func (c Introspection) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	rc.DisableIntrospection = c.Environment == config.Production && !auth.FromContext(ctx).Admin
 	return nil
 }
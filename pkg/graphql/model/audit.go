@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/base64"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
+)
+
+func NewAuditEntries(entries []*audit.Entry) []*AuditEntry {
+	result := make([]*AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		item := &AuditEntry{
+			ID:        int(entry.ID),
+			Time:      entry.Time,
+			Actor:     entry.Actor,
+			Action:    AuditAction(entry.Action),
+			User:      entry.User,
+			ClientIP:  entry.ClientIP,
+			RequestID: entry.RequestID,
+			Result:    AuditResult(entry.Result),
+		}
+
+		if entry.OldKey != "" {
+			oldID := base64.StdEncoding.EncodeToString([]byte(entry.OldKey))
+			item.OldID = &oldID
+		}
+
+		if entry.NewKey != "" {
+			newID := base64.StdEncoding.EncodeToString([]byte(entry.NewKey))
+			item.NewID = &newID
+		}
+
+		if entry.Error != "" {
+			err := entry.Error
+			item.Error = &err
+		}
+
+		result = append(result, item)
+	}
+
+	return result
+}
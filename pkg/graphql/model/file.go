@@ -3,9 +3,13 @@ package model
 import (
 	"encoding/base64"
 	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/rafaelrubbioli/fileapi/pkg/config"
 	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
 )
 
 func NewFile(file *entity.File) *File {
@@ -22,10 +26,41 @@ func NewFile(file *entity.File) *File {
 		Size:        file.Size,
 		CreatedAt:   file.CreatedAt,
 		UpdatedAt:   file.UpdatedAt,
-		DownloadURL: fmt.Sprintf("https://%s.s3.sa-east-1.amazonaws.com/%s", config.BucketName, file.ID),
+		DownloadURL: ObjectURL(file.ID),
+		ScanStatus:  newScanStatus(file.ScanStatus),
+		Checksum:    optional(file.Checksum),
+		Etag:        optional(file.ETag),
 	}
 }
 
+// optional is nil for empty values
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+// ObjectURL is the public url of key on the bucket
+func ObjectURL(key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", config.BucketName, config.AwsRegion, key)
+}
+
+// ArchiveURL is the url of the archive endpoint with query
+func ArchiveURL(query url.Values) string {
+	return strings.TrimSuffix(strings.TrimSuffix(config.BaseURL(), "/"), "/graphql") + "/archive?" + query.Encode()
+}
+
+func newScanStatus(status entity.ScanStatus) *ScanStatus {
+	if status == "" {
+		return nil
+	}
+
+	result := ScanStatus(status)
+	return &result
+}
+
 func NewFiles(files []*entity.File) []*File {
 	result := make([]*File, 0, len(files))
 	for _, file := range files {
@@ -36,3 +71,32 @@ func NewFiles(files []*entity.File) []*File {
 
 	return result
 }
+
+func NewContentMatches(matches []index.Match) []*ContentMatch {
+	result := make([]*ContentMatch, 0, len(matches))
+	for _, match := range matches {
+		if !match.File.IsEmpty() {
+			result = append(result, &ContentMatch{
+				File:    NewFile(match.File),
+				Snippet: match.Snippet,
+			})
+		}
+	}
+
+	return result
+}
+
+func NewFileEvent(e event.Event) *FileEvent {
+	result := &FileEvent{
+		Type: FileEventType(e.Type),
+		File: NewFile(&e.File),
+		Time: e.Time,
+	}
+
+	if e.OldID != "" {
+		oldID := base64.StdEncoding.EncodeToString([]byte(e.OldID))
+		result.OldID = &oldID
+	}
+
+	return result
+}
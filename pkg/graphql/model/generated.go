@@ -3,11 +3,101 @@
 package model
 
 import (
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql"
 )
 
+type ArchiveEntryResult struct {
+	// Path of the entry inside the archive
+	Name string `json:"name"`
+	// Uploaded file, null when the entry failed
+	File *File `json:"file"`
+	// Why the entry failed
+	Error *string `json:"error"`
+	// Code of the error, the same as on errors of operations
+	Code *string `json:"code"`
+}
+
+type AuditEntry struct {
+	// Unique identifier to the entry
+	ID int `json:"id"`
+	// When the operation happened
+	Time time.Time `json:"time"`
+	// Name of the admin token used, or anonymous
+	Actor  string      `json:"actor"`
+	Action AuditAction `json:"action"`
+	// File owner after the operation, or before it for deletes
+	User int `json:"user"`
+	// Identifier of the file before the operation, null for uploads
+	OldID *string `json:"oldID"`
+	// Identifier of the file after the operation, null for deletes
+	NewID *string `json:"newID"`
+	// Address the request came from
+	ClientIP string `json:"clientIP"`
+	// Same as the X-Request-ID response header
+	RequestID string      `json:"requestID"`
+	Result    AuditResult `json:"result"`
+	// Why the operation failed
+	Error *string `json:"error"`
+}
+
+type AuditFilter struct {
+	// Name of the admin token used, or anonymous
+	Actor  *string      `json:"actor"`
+	Action *AuditAction `json:"action"`
+	// File owner
+	User *int `json:"user"`
+	// Operations on this file, before or after them
+	ID     *string      `json:"id"`
+	Result *AuditResult `json:"result"`
+	// At or after
+	After *time.Time `json:"after"`
+	// At or before
+	Before *time.Time `json:"before"`
+}
+
+type BatchItemResult struct {
+	// Identifier of the file the item was about
+	ID string `json:"id"`
+	// Moved or copied file, null on deletes and when the item failed
+	File *File `json:"file"`
+	// Why the item failed
+	Error *string `json:"error"`
+	// Code of the error, the same as on errors of operations
+	Code *string `json:"code"`
+}
+
+type BatchResult struct {
+	// Result of every item, in the order they were sent
+	Results []*BatchItemResult `json:"results"`
+	// Number of items that succeeded
+	Succeeded int `json:"succeeded"`
+	// Number of items that failed
+	Failed int `json:"failed"`
+}
+
+type ContentMatch struct {
+	// File whose content matched
+	File *File `json:"file"`
+	// Matching part of the content as escaped html, matched words are wrapped in <mark> tags
+	Snippet string `json:"snippet"`
+}
+
+type CopyInput struct {
+	// Identifier of the file to copy
+	ID string `json:"id"`
+	// Destination user
+	User int `json:"user"`
+	// Destination path, including the file name
+	NewPath string `json:"newPath"`
+	// If set will replace duplicate files without error
+	Overwrite bool `json:"overwrite"`
+}
+
 type Dir struct {
 	// Current dir
 	Path string `json:"path"`
@@ -36,6 +126,62 @@ type File struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 	// URL to download the file
 	DownloadURL string `json:"downloadURL"`
+	// Malware scan result, null when uploads are not scanned
+	ScanStatus *ScanStatus `json:"scanStatus"`
+	// MD5 of the content in hex, null when it is not known
+	Checksum *string `json:"checksum"`
+	// Version of the content, sent as ifMatch to change the file only while it has this version
+	Etag *string `json:"etag"`
+	// URL to the image thumbnail closest to size in pixels, null for files without thumbnails. Thumbnails are generated after the upload, so the URL may not be available right away
+	ThumbnailURL *string `json:"thumbnailURL"`
+}
+
+type FileEvent struct {
+	// What happened to the file
+	Type FileEventType `json:"type"`
+	// File after the change, deleted files only have their location
+	File *File `json:"file"`
+	// Identifier the file had before it was moved
+	OldID *string `json:"oldID"`
+	// When it happened
+	Time time.Time `json:"time"`
+}
+
+type FileFilter struct {
+	// Only files from this user
+	User *int `json:"user"`
+	// Only files under this path
+	PathPrefix *string `json:"pathPrefix"`
+	// Part of the file name
+	Name *string `json:"name"`
+	// Content type or its prefix, such as image/
+	FileType *string `json:"fileType"`
+	// Minimum size in bytes
+	MinSize *int `json:"minSize"`
+	// Maximum size in bytes
+	MaxSize *int `json:"maxSize"`
+	// Created at or after
+	CreatedAfter *time.Time `json:"createdAfter"`
+	// Created at or before
+	CreatedBefore *time.Time `json:"createdBefore"`
+	// Updated at or after
+	UpdatedAfter *time.Time `json:"updatedAfter"`
+	// Updated at or before
+	UpdatedBefore *time.Time `json:"updatedBefore"`
+}
+
+type FilePage struct {
+	// Files in the requested page
+	Files []*File `json:"files"`
+	// Total number of files matching the filter
+	Total int `json:"total"`
+}
+
+type FileSort struct {
+	// Field to sort by
+	Field FileSortField `json:"field"`
+	// If set sorts in descending order
+	Desc bool `json:"desc"`
 }
 
 type MoveInput struct {
@@ -47,6 +193,38 @@ type MoveInput struct {
 	NewPath string `json:"newPath"`
 	// If set will replace duplicate files without error
 	Overwrite bool `json:"overwrite"`
+	// Only move the file while it has this etag, failing with PRECONDITION_FAILED otherwise
+	IfMatch *string `json:"ifMatch"`
+	// Moves retried with the same key get the first moved file back instead of moving again, same as the Idempotency-Key header outside of moveFiles
+	IdempotencyKey *string `json:"idempotencyKey"`
+}
+
+type PageInput struct {
+	// Max number of results
+	Limit int `json:"limit"`
+	// Number of results to skip
+	Offset int `json:"offset"`
+}
+
+type UploadArchiveInput struct {
+	File graphql.Upload `json:"file"`
+	// File owner
+	User int `json:"user"`
+	// Destination path, entries keep their directories under it
+	Path string `json:"path"`
+	// If set will replace duplicate files without error
+	Overwrite bool `json:"overwrite"`
+	// Format of the archive, from the extension of its name when not set
+	Format *ArchiveFormat `json:"format"`
+}
+
+type UploadArchiveResult struct {
+	// Result of every entry, in the order they are in the archive
+	Entries []*ArchiveEntryResult `json:"entries"`
+	// Number of uploaded files
+	Uploaded int `json:"uploaded"`
+	// Number of entries that failed
+	Failed int `json:"failed"`
 }
 
 type UploadInput struct {
@@ -57,4 +235,423 @@ type UploadInput struct {
 	Path string `json:"path"`
 	// If set will replace duplicate files without error
 	Overwrite bool `json:"overwrite"`
+	// Only replace the file on path while it has this etag, failing with PRECONDITION_FAILED otherwise
+	IfMatch *string `json:"ifMatch"`
+	// Uploads retried with the same key get the first uploaded file back instead of uploading again, same as the Idempotency-Key header
+	IdempotencyKey *string `json:"idempotencyKey"`
+}
+
+type Webhook struct {
+	// Unique identifier to the webhook
+	ID int `json:"id"`
+	// Where events are sent to
+	URL string `json:"url"`
+	// Events sent to the webhook, empty means every event
+	Events []FileEventType `json:"events"`
+	// Only files from this user, null means every user
+	User *int `json:"user"`
+	// Only files under this path
+	PathPrefix string `json:"pathPrefix"`
+	// Payload format
+	Format WebhookFormat `json:"format"`
+	// If payloads are signed with a secret
+	Signed bool `json:"signed"`
+	// Creation date
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	// Unique identifier to the delivery, also sent in the X-Fileapi-Delivery header
+	ID int `json:"id"`
+	// Webhook the delivery is sent to
+	WebhookID int `json:"webhookID"`
+	// Event being delivered
+	Event FileEventType `json:"event"`
+	// File the event is about
+	FileID string `json:"fileID"`
+	// Request body
+	Payload string                `json:"payload"`
+	Status  WebhookDeliveryStatus `json:"status"`
+	// Number of attempts so far
+	Attempts int `json:"attempts"`
+	// HTTP status of the last attempt, null if it got no response
+	ResponseStatus *int `json:"responseStatus"`
+	// Why the last attempt failed
+	LastError *string `json:"lastError"`
+	// When the delivery will be tried again, null if it won't
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+	// Creation date
+	CreatedAt time.Time `json:"createdAt"`
+	// Last attempt date
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type WebhookInput struct {
+	// Absolute http or https url events are sent to
+	URL string `json:"url"`
+	// Events to send, empty or null means every event
+	Events []FileEventType `json:"events"`
+	// Only files from this user
+	User *int `json:"user"`
+	// Only files under this path
+	PathPrefix *string `json:"pathPrefix"`
+	// Signs the payloads with HMAC-SHA256 when set
+	Secret *string `json:"secret"`
+	// Payload format
+	Format WebhookFormat `json:"format"`
+}
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatZip   ArchiveFormat = "ZIP"
+	ArchiveFormatTarGz ArchiveFormat = "TAR_GZ"
+)
+
+var AllArchiveFormat = []ArchiveFormat{
+	ArchiveFormatZip,
+	ArchiveFormatTarGz,
+}
+
+func (e ArchiveFormat) IsValid() bool {
+	switch e {
+	case ArchiveFormatZip, ArchiveFormatTarGz:
+		return true
+	}
+	return false
+}
+
+func (e ArchiveFormat) String() string {
+	return string(e)
+}
+
+func (e *ArchiveFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ArchiveFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ArchiveFormat", str)
+	}
+	return nil
+}
+
+func (e ArchiveFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type AuditAction string
+
+const (
+	AuditActionUpload AuditAction = "UPLOAD"
+	AuditActionMove   AuditAction = "MOVE"
+	AuditActionCopy   AuditAction = "COPY"
+	AuditActionDelete AuditAction = "DELETE"
+)
+
+var AllAuditAction = []AuditAction{
+	AuditActionUpload,
+	AuditActionMove,
+	AuditActionCopy,
+	AuditActionDelete,
+}
+
+func (e AuditAction) IsValid() bool {
+	switch e {
+	case AuditActionUpload, AuditActionMove, AuditActionCopy, AuditActionDelete:
+		return true
+	}
+	return false
+}
+
+func (e AuditAction) String() string {
+	return string(e)
+}
+
+func (e *AuditAction) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuditAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AuditAction", str)
+	}
+	return nil
+}
+
+func (e AuditAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "SUCCESS"
+	AuditResultFailure AuditResult = "FAILURE"
+)
+
+var AllAuditResult = []AuditResult{
+	AuditResultSuccess,
+	AuditResultFailure,
+}
+
+func (e AuditResult) IsValid() bool {
+	switch e {
+	case AuditResultSuccess, AuditResultFailure:
+		return true
+	}
+	return false
+}
+
+func (e AuditResult) String() string {
+	return string(e)
+}
+
+func (e *AuditResult) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuditResult(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AuditResult", str)
+	}
+	return nil
+}
+
+func (e AuditResult) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type FileEventType string
+
+const (
+	FileEventTypeCreated FileEventType = "CREATED"
+	FileEventTypeMoved   FileEventType = "MOVED"
+	FileEventTypeDeleted FileEventType = "DELETED"
+	// Changes after the upload, such as a finished malware scan or new thumbnails
+	FileEventTypeUpdated FileEventType = "UPDATED"
+)
+
+var AllFileEventType = []FileEventType{
+	FileEventTypeCreated,
+	FileEventTypeMoved,
+	FileEventTypeDeleted,
+	FileEventTypeUpdated,
+}
+
+func (e FileEventType) IsValid() bool {
+	switch e {
+	case FileEventTypeCreated, FileEventTypeMoved, FileEventTypeDeleted, FileEventTypeUpdated:
+		return true
+	}
+	return false
+}
+
+func (e FileEventType) String() string {
+	return string(e)
+}
+
+func (e *FileEventType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FileEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FileEventType", str)
+	}
+	return nil
+}
+
+func (e FileEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type FileSortField string
+
+const (
+	FileSortFieldName      FileSortField = "NAME"
+	FileSortFieldSize      FileSortField = "SIZE"
+	FileSortFieldCreatedAt FileSortField = "CREATED_AT"
+	FileSortFieldUpdatedAt FileSortField = "UPDATED_AT"
+)
+
+var AllFileSortField = []FileSortField{
+	FileSortFieldName,
+	FileSortFieldSize,
+	FileSortFieldCreatedAt,
+	FileSortFieldUpdatedAt,
+}
+
+func (e FileSortField) IsValid() bool {
+	switch e {
+	case FileSortFieldName, FileSortFieldSize, FileSortFieldCreatedAt, FileSortFieldUpdatedAt:
+		return true
+	}
+	return false
+}
+
+func (e FileSortField) String() string {
+	return string(e)
+}
+
+func (e *FileSortField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FileSortField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FileSortField", str)
+	}
+	return nil
+}
+
+func (e FileSortField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ScanStatus string
+
+const (
+	// Waiting to be scanned, the file is quarantined and cannot be downloaded
+	ScanStatusPending ScanStatus = "PENDING"
+	// Scanned and found clean
+	ScanStatusClean ScanStatus = "CLEAN"
+	// Scanned and found infected, the file stays quarantined
+	ScanStatusInfected ScanStatus = "INFECTED"
+	// Could not be scanned, the file stays quarantined until it is uploaded again
+	ScanStatusFailed ScanStatus = "FAILED"
+)
+
+var AllScanStatus = []ScanStatus{
+	ScanStatusPending,
+	ScanStatusClean,
+	ScanStatusInfected,
+	ScanStatusFailed,
+}
+
+func (e ScanStatus) IsValid() bool {
+	switch e {
+	case ScanStatusPending, ScanStatusClean, ScanStatusInfected, ScanStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ScanStatus) String() string {
+	return string(e)
+}
+
+func (e *ScanStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScanStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScanStatus", str)
+	}
+	return nil
+}
+
+func (e ScanStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	// Waiting for its next attempt
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	// Gave up after too many failed attempts
+	WebhookDeliveryStatusFailed WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookFormat string
+
+const (
+	// JSON signed with HMAC-SHA256 in the X-Fileapi-Signature header when there is a secret
+	WebhookFormatJSON WebhookFormat = "JSON"
+	// CloudEvents 1.0 structured mode, also signed when there is a secret
+	WebhookFormatCloudevents WebhookFormat = "CLOUDEVENTS"
+)
+
+var AllWebhookFormat = []WebhookFormat{
+	WebhookFormatJSON,
+	WebhookFormatCloudevents,
+}
+
+func (e WebhookFormat) IsValid() bool {
+	switch e {
+	case WebhookFormatJSON, WebhookFormatCloudevents:
+		return true
+	}
+	return false
+}
+
+func (e WebhookFormat) String() string {
+	return string(e)
+}
+
+func (e *WebhookFormat) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookFormat(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookFormat", str)
+	}
+	return nil
+}
+
+func (e WebhookFormat) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/base64"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
+)
+
+func NewWebhook(hook *webhook.Webhook) *Webhook {
+	events := make([]FileEventType, 0, len(hook.Events))
+	for _, eventType := range hook.Events {
+		events = append(events, FileEventType(eventType))
+	}
+
+	return &Webhook{
+		ID:         int(hook.ID),
+		URL:        hook.URL,
+		Events:     events,
+		User:       hook.User,
+		PathPrefix: hook.PathPrefix,
+		Format:     WebhookFormat(hook.Format),
+		Signed:     hook.Secret != "",
+		CreatedAt:  hook.CreatedAt,
+	}
+}
+
+func NewWebhooks(hooks []*webhook.Webhook) []*Webhook {
+	result := make([]*Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		result = append(result, NewWebhook(hook))
+	}
+
+	return result
+}
+
+func NewWebhookDeliveries(deliveries []*webhook.Delivery) []*WebhookDelivery {
+	result := make([]*WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		item := &WebhookDelivery{
+			ID:        int(delivery.ID),
+			WebhookID: int(delivery.WebhookID),
+			Event:     FileEventType(delivery.Event),
+			FileID:    base64.StdEncoding.EncodeToString([]byte(delivery.FileID)),
+			Payload:   string(delivery.Payload),
+			Status:    WebhookDeliveryStatus(delivery.Status),
+			Attempts:  delivery.Attempts,
+			CreatedAt: delivery.CreatedAt,
+			UpdatedAt: delivery.UpdatedAt,
+		}
+
+		if delivery.ResponseStatus != 0 {
+			status := delivery.ResponseStatus
+			item.ResponseStatus = &status
+		}
+
+		if delivery.LastError != "" {
+			lastError := delivery.LastError
+			item.LastError = &lastError
+		}
+
+		if delivery.Status == webhook.Pending {
+			nextAttemptAt := delivery.NextAttemptAt
+			item.NextAttemptAt = &nextAttemptAt
+		}
+
+		result = append(result, item)
+	}
+
+	return result
+}
@@ -0,0 +1,102 @@
+package persisted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/vektah/gqlparser/v2/ast"
+	parsererror "github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// Load reads the registered queries from a json file mapping the sha256 hash of each query to it
+func Load(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query allowlist: %w", err)
+	}
+
+	queries := map[string]string{}
+	if err := json.Unmarshal(content, &queries); err != nil {
+		return nil, fmt.Errorf("failed to parse query allowlist: %w", err)
+	}
+
+	for hash, query := range queries {
+		if Hash(query) != hash {
+			return nil, fmt.Errorf("query allowlist hash %s does not match its query", hash)
+		}
+	}
+
+	return queries, nil
+}
+
+// OperationNames are the names of every operation of the registered queries, queries that can't be
+// parsed are left out
+func OperationNames(queries map[string]string) map[string]bool {
+	names := map[string]bool{}
+	for _, query := range queries {
+		document, err := parser.ParseQuery(&ast.Source{Input: query})
+		if err != nil {
+			continue
+		}
+
+		for _, operation := range document.Operations {
+			if operation.Name != "" {
+				names[operation.Name] = true
+			}
+		}
+	}
+
+	return names
+}
+
+// Hash is the hex encoded sha256 of query, the same one sent on the persistedQuery extension
+func Hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// Allowlist serves registered queries sent only by their hash, the same way automatic persisted
+// queries are. When Enforce is set queries that are not registered are rejected, except for admins.
+// It has to be used before the automatic persisted query extension.
+type Allowlist struct {
+	Queries map[string]string
+	Enforce bool
+}
+
+func (Allowlist) ExtensionName() string {
+	return "QueryAllowlist"
+}
+
+func (Allowlist) Validate(_ graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (a Allowlist) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *parsererror.Error {
+	var hash string
+	if extension, ok := params.Extensions["persistedQuery"].(map[string]interface{}); ok {
+		hash, _ = extension["sha256Hash"].(string)
+	}
+
+	if params.Query == "" {
+		if query, ok := a.Queries[hash]; ok {
+			params.Query = query
+			return nil
+		}
+	} else if _, ok := a.Queries[Hash(params.Query)]; ok {
+		return nil
+	}
+
+	if a.Enforce && !auth.FromContext(ctx).Admin {
+		return gqlerror.ErrQueryNotAllowed
+	}
+
+	return nil
+}
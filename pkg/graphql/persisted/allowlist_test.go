@@ -0,0 +1,132 @@
+package persisted_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/persisted"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const files = `{ listUserFiles(user: 1) { id } }`
+
+type response struct {
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func send(t *testing.T, handler graphql.Handler, ctx context.Context, query, hash string) response {
+	params := map[string]interface{}{"query": query}
+	if hash != "" {
+		params["extensions"] = map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash},
+		}
+	}
+
+	body, _ := json.Marshal(params)
+	request := httptest.NewRequest(http.MethodPost, "/graphql/", strings.NewReader(string(body))).WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	written := httptest.NewRecorder()
+	handler.Handle(written, request)
+
+	var result response
+	require.NoError(t, json.Unmarshal(written.Body.Bytes(), &result))
+	return result
+}
+
+func TestAllowlist(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	serviceMock.EXPECT().GetByUser(gomock.Any(), gomock.Any(), gomock.Any()).Return([]*entity.File{}, nil).AnyTimes()
+
+	// tests run as production, where the allowlist is enforced
+	handler := graphql.NewHandler(serviceMock, nil, map[string]string{persisted.Hash(files): files})
+	ctx := context.Background()
+	admin := auth.WithIdentity(ctx, auth.Identity{Name: "admin", Admin: true})
+	other := `{ listUserFiles(user: 2) { id name } }`
+
+	t.Run("registered query by hash", func(t *testing.T) {
+		require.Empty(t, send(t, handler, ctx, "", persisted.Hash(files)).Errors)
+	})
+
+	t.Run("registered query", func(t *testing.T) {
+		require.Empty(t, send(t, handler, ctx, files, "").Errors)
+	})
+
+	t.Run("other queries are rejected", func(t *testing.T) {
+		for _, result := range []response{send(t, handler, ctx, other, ""), send(t, handler, ctx, other, persisted.Hash(other))} {
+			require.Len(t, result.Errors, 1)
+			require.Equal(t, "QUERY_NOT_ALLOWED", result.Errors[0].Extensions["code"])
+		}
+
+		result := send(t, handler, ctx, "", persisted.Hash(other))
+		require.Equal(t, "QUERY_NOT_ALLOWED", result.Errors[0].Extensions["code"], "not served from the apq cache")
+	})
+
+	t.Run("admins run any query", func(t *testing.T) {
+		require.Empty(t, send(t, handler, admin, other, "").Errors)
+	})
+
+	t.Run("introspection is for admins", func(t *testing.T) {
+		introspection := `{ __schema { queryType { name } } }`
+		handler := graphql.NewHandler(serviceMock, nil, nil)
+
+		result := send(t, handler, ctx, introspection, "")
+		require.Len(t, result.Errors, 1)
+		require.Equal(t, "introspection disabled", result.Errors[0].Message)
+
+		require.Empty(t, send(t, handler, admin, introspection, "").Errors)
+	})
+}
+
+func TestAutomaticPersistedQueries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "").Return([]*entity.File{}, nil).Times(2)
+	handler := graphql.NewHandler(serviceMock, nil, nil)
+	ctx := context.Background()
+
+	result := send(t, handler, ctx, "", persisted.Hash(files))
+	require.Len(t, result.Errors, 1)
+	require.Equal(t, "PERSISTED_QUERY_NOT_FOUND", result.Errors[0].Extensions["code"])
+
+	require.Empty(t, send(t, handler, ctx, files, persisted.Hash(files)).Errors)
+	require.Empty(t, send(t, handler, ctx, "", persisted.Hash(files)).Errors)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.json")
+	content, _ := json.Marshal(map[string]string{persisted.Hash(files): files})
+	require.NoError(t, os.WriteFile(valid, content, 0o600))
+
+	queries, err := persisted.Load(valid)
+	require.NoError(t, err)
+	require.Equal(t, files, queries[persisted.Hash(files)])
+
+	mismatched := filepath.Join(dir, "mismatched.json")
+	require.NoError(t, os.WriteFile(mismatched, []byte(`{"abc": "{ webhooks { id } }"}`), 0o600))
+	_, err = persisted.Load(mismatched)
+	require.EqualError(t, err, "query allowlist hash abc does not match its query")
+
+	_, err = persisted.Load(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+)
+
+// limitOf has the fields limited along with another one, sharing its limit
+var limitOf = map[string]string{
+	"uploadArchive": "upload",
+}
+
+// Extension limits how often each client can call the query and mutation fields that have
+// a limit of their own, like upload. Every request is already limited by the default limit.
+type Extension struct {
+	limiters *middleware.Limiters
+}
+
+// New limits fields with the limiter named after them, the default limit is ignored
+func New(limiters *middleware.Limiters) Extension {
+	return Extension{limiters: limiters}
+}
+
+func (Extension) ExtensionName() string {
+	return "RateLimit"
+}
+
+func (Extension) Validate(_ graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fieldContext := graphql.GetFieldContext(ctx)
+	if fieldContext == nil || (fieldContext.Object != "Query" && fieldContext.Object != "Mutation") {
+		return next(ctx)
+	}
+
+	name := fieldContext.Field.Name
+	if other, ok := limitOf[name]; ok {
+		name = other
+	}
+
+	limiter := e.limiters.Get(name)
+	if limiter == nil || name == middleware.DefaultLimit {
+		return next(ctx)
+	}
+
+	if err := limiter.Allow(middleware.ClientKey(ctx)); err != nil {
+		return nil, gqlerror.RateLimited(err.(middleware.RateLimitedError))
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,81 @@
+package ratelimit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gqlgen "github.com/99designs/gqlgen/graphql"
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/ratelimit"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func TestExtension(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "").Return([]*entity.File{}, nil)
+	serviceMock.EXPECT().Get(gomock.Any(), "1/a.txt").Return(&entity.File{ID: "1/a.txt"}, nil).Times(3)
+	handler := graphql.NewHandler(serviceMock, middleware.NewLimiters(map[string]middleware.Limit{
+		middleware.DefaultLimit: {Rate: 0.1, Burst: 1},
+		"listUserFiles":         {Rate: 0.1, Burst: 1},
+	}, 0), nil)
+
+	send := func(query string) []map[string]interface{} {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		request := httptest.NewRequest(http.MethodPost, "/graphql/", strings.NewReader(string(body)))
+		request.Header.Set("Content-Type", "application/json")
+		written := httptest.NewRecorder()
+		handler.Handle(written, request)
+
+		var response struct {
+			Errors []map[string]interface{} `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(written.Body.Bytes(), &response))
+		return response.Errors
+	}
+
+	require.Empty(t, send(`{ listUserFiles(user: 1) { id } }`))
+
+	errors := send(`{ listUserFiles(user: 1) { id } }`)
+	require.Len(t, errors, 1)
+	extensions := errors[0]["extensions"].(map[string]interface{})
+	require.Equal(t, "RATE_LIMITED", extensions["code"])
+	require.Equal(t, float64(10), extensions["retryAfter"])
+
+	id := "MS9hLnR4dA==" // 1/a.txt
+	require.Empty(t, send(`{ a: file(id: "`+id+`") { id } b: file(id: "`+id+`") { id } c: file(id: "`+id+`") { id } }`),
+		"fields without a limit of their own and the default limit are not limited by the extension")
+}
+
+func TestExtension_SharedLimits(t *testing.T) {
+	limiters := middleware.NewLimiters(map[string]middleware.Limit{"upload": {Rate: 0.1, Burst: 1}}, 0)
+	extension := ratelimit.New(limiters)
+	field := func(name string) context.Context {
+		return gqlgen.WithFieldContext(context.Background(), &gqlgen.FieldContext{
+			Object: "Mutation",
+			Field:  gqlgen.CollectedField{Field: &ast.Field{Name: name}},
+		})
+	}
+	next := func(context.Context) (interface{}, error) { return true, nil }
+
+	// the upload limit is spent by another api sharing the limiters
+	require.NoError(t, limiters.Get("upload").Allow(middleware.ClientKey(context.Background())))
+
+	_, err := extension.InterceptField(field("uploadArchive"), next)
+	require.Error(t, err, "archives count as uploads")
+	_, err = extension.InterceptField(field("upload"), next)
+	require.Error(t, err)
+	_, err = extension.InterceptField(field("move"), next)
+	require.NoError(t, err)
+}
@@ -1,12 +1,17 @@
 package resolver
 
 import (
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlgen"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
 	"github.com/rafaelrubbioli/fileapi/pkg/service"
 )
 
 type app struct {
-	service service.Service
+	service        service.Service
+	thumbnailSizes []int
+	// bandwidth throttles uploads of each client, nil when they are not throttled
+	bandwidth *middleware.Limiter
 }
 
 func (a app) Query() gqlgen.QueryResolver {
@@ -17,8 +22,19 @@ func (a app) Mutation() gqlgen.MutationResolver {
 	return mutation{app: &a}
 }
 
-func New(service service.Service) gqlgen.ResolverRoot {
+func (a app) Subscription() gqlgen.SubscriptionResolver {
+	return subscription{app: &a}
+}
+
+func (a app) File() gqlgen.FileResolver {
+	return file{app: &a}
+}
+
+// New resolves the api with service, bandwidth throttles uploads of each client when it is not nil
+func New(service service.Service, bandwidth *middleware.Limiter) gqlgen.ResolverRoot {
 	return &app{
-		service: service,
+		service:        service,
+		thumbnailSizes: config.ThumbnailSizes(),
+		bandwidth:      bandwidth,
 	}
 }
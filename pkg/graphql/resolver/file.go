@@ -0,0 +1,33 @@
+package resolver
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/thumbnail"
+)
+
+type file struct {
+	*app
+}
+
+// ThumbnailURL points to where the thumbnail is stored, which may not exist yet while it is generated
+func (f file) ThumbnailURL(_ context.Context, obj *model.File, size int) (*string, error) {
+	if len(f.thumbnailSizes) == 0 || !thumbnail.Supported(obj.FileType) {
+		return nil, nil
+	}
+
+	if obj.ScanStatus != nil && *obj.ScanStatus != model.ScanStatusClean {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(obj.ID)
+	if err != nil {
+		return nil, gqlerror.ErrInvalidID
+	}
+
+	url := model.ObjectURL(thumbnail.Key(string(key), thumbnail.Closest(f.thumbnailSizes, size)))
+	return &url, nil
+}
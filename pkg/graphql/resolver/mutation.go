@@ -1,15 +1,33 @@
 package resolver
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
+	"io"
+	"path"
 	"strings"
+	"sync"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/archive"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
 	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
+	parsererror "github.com/vektah/gqlparser/v2/gqlerror"
 )
 
+var archiveFormats = map[model.ArchiveFormat]archive.Format{
+	model.ArchiveFormatZip:   archive.Zip,
+	model.ArchiveFormatTarGz: archive.TarGz,
+}
+
 type mutation struct {
 	*app
 }
@@ -19,42 +37,355 @@ func (m mutation) Upload(ctx context.Context, input model.UploadInput) (*model.F
 		return nil, gqlerror.ErrFileTooBig
 	}
 
-	if strings.Contains(input.Path, "..") {
+	if !validPath(input.Path) {
 		return nil, gqlerror.ErrInvalidPath
 	}
 
-	file, err := m.service.Create(ctx, input.User, int(input.File.Size), input.File.Filename, input.Path, input.File.ContentType, input.File.File, input.Overwrite)
+	var content io.Reader = input.File.File
+	if m.bandwidth != nil {
+		content = middleware.Throttle(ctx, m.bandwidth, middleware.ClientKey(ctx), content)
+	}
+
+	ctx = withIfMatch(ctx, input.IfMatch)
+	ctx = withIdempotencyKey(ctx, input.IdempotencyKey, middleware.IdempotencyKey(ctx))
+	file, err := m.service.Create(ctx, input.User, int(input.File.Size), input.File.Filename, input.Path, input.File.ContentType, content, input.Overwrite)
 	if err != nil {
-		return nil, gqlerror.Error(err)
+		return nil, gqlerror.Error(ctx, err)
 	}
 
 	return model.NewFile(file), nil
 }
 
+func (m mutation) UploadArchive(ctx context.Context, input model.UploadArchiveInput) (*model.UploadArchiveResult, error) {
+	if int(input.File.Size) > config.MaxUploadFileSize() {
+		return nil, gqlerror.ErrFileTooBig
+	}
+
+	if !validPath(input.Path) {
+		return nil, gqlerror.ErrInvalidPath
+	}
+
+	format, err := archive.FormatOf(input.File.Filename)
+	if input.Format != nil {
+		format, err = archiveFormats[*input.Format], nil
+	}
+	if err != nil {
+		return nil, gqlerror.ErrArchiveFormat
+	}
+
+	content, ok := input.File.File.(io.ReadSeeker)
+	if !ok {
+		read, err := io.ReadAll(input.File.File)
+		if err != nil {
+			return nil, gqlerror.Error(ctx, err)
+		}
+
+		content = bytes.NewReader(read)
+	}
+
+	result := &model.UploadArchiveResult{Entries: []*model.ArchiveEntryResult{}}
+	limits := archive.Limits{MaxEntries: config.ArchiveMaxEntries(), MaxSize: config.ArchiveMaxSize()}
+	err = archive.Extract(content, format, limits, func(entry archive.Entry, content io.Reader) error {
+		report := &model.ArchiveEntryResult{Name: entry.Name}
+		result.Entries = append(result.Entries, report)
+
+		file, err := m.extract(ctx, input, entry, content)
+		if err != nil {
+			result.Failed++
+			message, code := errorReport(ctx, err)
+			report.Error, report.Code = &message, &code
+			return nil
+		}
+
+		result.Uploaded++
+		report.File = model.NewFile(file)
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, archive.ErrTooManyEntries):
+		return nil, gqlerror.ErrArchiveEntries
+	case errors.Is(err, archive.ErrTooBig):
+		return nil, gqlerror.ErrArchiveTooBig
+	case err != nil:
+		return nil, gqlerror.ErrInvalidArchiveFile
+	}
+
+	return result, nil
+}
+
+// extract uploads an entry of an archive to its directory under the path of input
+func (m mutation) extract(ctx context.Context, input model.UploadArchiveInput, entry archive.Entry, content io.Reader) (*entity.File, error) {
+	name := strings.TrimLeft(strings.ReplaceAll(entry.Name, "\\", "/"), "/")
+	if !validPath(name) {
+		return nil, gqlerror.ErrInvalidPath
+	}
+
+	if !entry.Regular {
+		return nil, gqlerror.ErrNotRegularFile
+	}
+
+	// the archive may be under the max file size while its entries are over it once decompressed
+	if entry.Size > int64(config.MaxUploadFileSize()) {
+		return nil, gqlerror.ErrFileTooBig
+	}
+
+	if m.bandwidth != nil {
+		content = middleware.Throttle(ctx, m.bandwidth, middleware.ClientKey(ctx), content)
+	}
+
+	dir, base := path.Split(name)
+	return m.service.Create(ctx, input.User, int(entry.Size), base, path.Join(input.Path, dir), "", content, input.Overwrite)
+}
+
 func (m mutation) Move(ctx context.Context, input model.MoveInput) (*model.File, error) {
+	return m.move(withIdempotencyKey(ctx, input.IdempotencyKey, middleware.IdempotencyKey(ctx)), input)
+}
+
+func (m mutation) move(ctx context.Context, input model.MoveInput) (*model.File, error) {
 	key, err := base64.StdEncoding.DecodeString(input.ID)
 	if err != nil {
 		return nil, gqlerror.ErrInvalidID
 	}
 
+	if !validPath(input.NewPath) {
+		return nil, gqlerror.ErrInvalidPath
+	}
+
+	ctx = withIfMatch(ctx, input.IfMatch)
 	resultFile, err := m.service.Move(ctx, input.User, string(key), input.NewPath, input.Overwrite)
 	if err != nil {
-		return nil, err
+		return nil, gqlerror.Error(ctx, err)
 	}
 
 	return model.NewFile(resultFile), nil
 }
 
-func (m mutation) Delete(ctx context.Context, id string) (bool, error) {
+func (m mutation) Delete(ctx context.Context, id string, ifMatch *string) (bool, error) {
 	key, err := base64.StdEncoding.DecodeString(id)
 	if err != nil {
 		return false, gqlerror.ErrInvalidID
 	}
 
-	err = m.service.Delete(ctx, string(key))
+	err = m.service.Delete(withIfMatch(ctx, ifMatch), string(key))
+	if err != nil {
+		return false, gqlerror.Error(ctx, err)
+	}
+
+	return true, nil
+}
+
+func (m mutation) MoveFiles(ctx context.Context, inputs []*model.MoveInput) (*model.BatchResult, error) {
+	if len(inputs) > config.BatchMaxSize() {
+		return nil, gqlerror.ErrBatchTooBig
+	}
+
+	results := make([]*model.BatchItemResult, len(inputs))
+	forEach(len(inputs), func(i int) {
+		// the header would be the same for every move of the batch, so only the key of each input is used
+		file, err := m.move(withIdempotencyKey(ctx, inputs[i].IdempotencyKey, ""), *inputs[i])
+		results[i] = batchItem(ctx, inputs[i].ID, file, err)
+	})
+
+	return newBatchResult(results), nil
+}
+
+func (m mutation) CopyFiles(ctx context.Context, inputs []*model.CopyInput) (*model.BatchResult, error) {
+	if len(inputs) > config.BatchMaxSize() {
+		return nil, gqlerror.ErrBatchTooBig
+	}
+
+	results := make([]*model.BatchItemResult, len(inputs))
+	forEach(len(inputs), func(i int) {
+		file, err := m.copy(ctx, *inputs[i])
+		results[i] = batchItem(ctx, inputs[i].ID, file, err)
+	})
+
+	return newBatchResult(results), nil
+}
+
+func (m mutation) copy(ctx context.Context, input model.CopyInput) (*model.File, error) {
+	key, err := base64.StdEncoding.DecodeString(input.ID)
+	if err != nil {
+		return nil, gqlerror.ErrInvalidID
+	}
+
+	if !validPath(input.NewPath) {
+		return nil, gqlerror.ErrInvalidPath
+	}
+
+	file, err := m.service.Copy(ctx, input.User, string(key), input.NewPath, input.Overwrite)
 	if err != nil {
-		return false, gqlerror.Error(err)
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return model.NewFile(file), nil
+}
+
+// DeleteFiles deletes the files with as few requests to the storage as it can, instead of one per file
+func (m mutation) DeleteFiles(ctx context.Context, ids []string) (*model.BatchResult, error) {
+	if len(ids) > config.BatchMaxSize() {
+		return nil, gqlerror.ErrBatchTooBig
+	}
+
+	results := make([]*model.BatchItemResult, len(ids))
+	var (
+		keys    []string
+		indexes []int
+	)
+	for i, id := range ids {
+		key, err := base64.StdEncoding.DecodeString(id)
+		if err != nil {
+			results[i] = batchItem(ctx, id, nil, gqlerror.ErrInvalidID)
+			continue
+		}
+
+		keys = append(keys, string(key))
+		indexes = append(indexes, i)
+	}
+
+	if len(keys) > 0 {
+		for i, err := range m.service.DeleteMany(ctx, keys) {
+			if err != nil {
+				err = gqlerror.Error(ctx, err)
+			}
+
+			results[indexes[i]] = batchItem(ctx, ids[indexes[i]], nil, err)
+		}
+	}
+
+	return newBatchResult(results), nil
+}
+
+func (m mutation) CreateWebhook(ctx context.Context, input model.WebhookInput) (*model.Webhook, error) {
+	if !auth.FromContext(ctx).Admin {
+		return nil, gqlerror.ErrAdminOnly
+	}
+
+	hook := &webhook.Webhook{
+		URL:    input.URL,
+		User:   input.User,
+		Format: webhook.Format(input.Format),
+	}
+
+	for _, eventType := range input.Events {
+		hook.Events = append(hook.Events, event.Type(eventType))
+	}
+
+	if input.PathPrefix != nil {
+		if strings.Contains(*input.PathPrefix, "..") {
+			return nil, gqlerror.ErrInvalidPath
+		}
+
+		hook.PathPrefix = *input.PathPrefix
+	}
+
+	if input.Secret != nil {
+		hook.Secret = *input.Secret
+	}
+
+	if err := m.service.CreateWebhook(ctx, hook); err != nil {
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return model.NewWebhook(hook), nil
+}
+
+func (m mutation) DeleteWebhook(ctx context.Context, id int) (bool, error) {
+	if !auth.FromContext(ctx).Admin {
+		return false, gqlerror.ErrAdminOnly
+	}
+
+	if err := m.service.DeleteWebhook(ctx, int64(id)); err != nil {
+		return false, gqlerror.Error(ctx, err)
 	}
 
 	return true, nil
 }
+
+// withIfMatch makes the changes made with ctx depend on the file having etag, when it is given
+func withIfMatch(ctx context.Context, etag *string) context.Context {
+	if etag == nil || *etag == "" {
+		return ctx
+	}
+
+	return service.WithIfMatch(ctx, *etag)
+}
+
+// withIdempotencyKey makes the change made with ctx run once for key, or for header when there is no key
+func withIdempotencyKey(ctx context.Context, key *string, header string) context.Context {
+	if key != nil && *key != "" {
+		return service.WithIdempotencyKey(ctx, *key)
+	}
+
+	if header != "" {
+		return service.WithIdempotencyKey(ctx, header)
+	}
+
+	return ctx
+}
+
+// validPath rejects paths that could point outside of the user directory
+func validPath(path string) bool {
+	return !strings.Contains(path, "..")
+}
+
+// errorReport is the message and code err is answered with when it fails an operation
+func errorReport(ctx context.Context, err error) (string, string) {
+	var gqlErr *parsererror.Error
+	if !errors.As(err, &gqlErr) && !errors.As(gqlerror.Error(ctx, err), &gqlErr) {
+		return err.Error(), ""
+	}
+
+	code, _ := gqlErr.Extensions[gqlerror.ErrCodeLabel].(gqlerror.ErrorType)
+	return gqlErr.Message, string(code)
+}
+
+// forEach calls fn with every index up to n, at most config.BatchConcurrency at the same time
+func forEach(n int, fn func(i int)) {
+	concurrency := config.BatchConcurrency()
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			fn(i)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// batchItem is the result of one item of a batch mutation, which failed when err is not nil
+func batchItem(ctx context.Context, id string, file *model.File, err error) *model.BatchItemResult {
+	item := &model.BatchItemResult{ID: id, File: file}
+	if err != nil {
+		message, code := errorReport(ctx, err)
+		item.Error, item.Code, item.File = &message, &code, nil
+	}
+
+	return item
+}
+
+func newBatchResult(results []*model.BatchItemResult) *model.BatchResult {
+	result := &model.BatchResult{Results: results}
+	for _, item := range results {
+		if item.Error != nil {
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+	}
+
+	return result
+}
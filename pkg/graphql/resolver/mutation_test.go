@@ -0,0 +1,274 @@
+package resolver
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadArchive(t *testing.T) {
+	t.Setenv("FILE_MAX_SIZE", "10000")
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	resolver := mutation{app: &app{service: serviceMock}}
+	ctx := context.Background()
+
+	archive := func(names ...string) graphql.Upload {
+		var buffer bytes.Buffer
+		writer := zip.NewWriter(&buffer)
+		for _, name := range names {
+			entry, err := writer.Create(name)
+			require.NoError(t, err)
+			entry.Write([]byte("hello"))
+		}
+		require.NoError(t, writer.Close())
+		return graphql.Upload{File: bytes.NewReader(buffer.Bytes()), Filename: "docs.zip", Size: int64(buffer.Len())}
+	}
+
+	t.Run("extract", func(t *testing.T) {
+		serviceMock.EXPECT().Create(ctx, 1, 5, "a.txt", "docs", "", gomock.Any(), false).
+			DoAndReturn(func(_ context.Context, user, size int, name, path, _ string, content io.Reader, _ bool) (*entity.File, error) {
+				read, _ := io.ReadAll(content)
+				require.Equal(t, "hello", string(read))
+				return &entity.File{ID: "1/docs/a.txt", User: user, Name: name, Path: path, Size: size}, nil
+			})
+		serviceMock.EXPECT().Create(ctx, 1, 5, "b.txt", "docs/img", "", gomock.Any(), false).Return(nil, service.ErrDuplicateFile)
+
+		result, err := resolver.UploadArchive(ctx, model.UploadArchiveInput{File: archive("a.txt", "img/b.txt", "../c.txt"), User: 1, Path: "docs"})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Uploaded)
+		require.Equal(t, 2, result.Failed)
+		require.Len(t, result.Entries, 3)
+		require.Equal(t, "docs", result.Entries[0].File.Path)
+		require.Nil(t, result.Entries[0].Error)
+		require.Equal(t, "file already exists on path", *result.Entries[1].Error)
+		require.Equal(t, "BAD_REQUEST", *result.Entries[1].Code)
+		require.Equal(t, "../c.txt", result.Entries[2].Name)
+		require.Equal(t, "BAD_REQUEST", *result.Entries[2].Code, "entries can not be extracted outside of the path")
+	})
+
+	t.Run("entries over the max file size", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := zip.NewWriter(&buffer)
+		entry, err := writer.Create("big.txt")
+		require.NoError(t, err)
+		entry.Write(make([]byte, 20000))
+		require.NoError(t, writer.Close())
+
+		upload := graphql.Upload{File: bytes.NewReader(buffer.Bytes()), Filename: "big.zip", Size: int64(buffer.Len())}
+		result, err := resolver.UploadArchive(ctx, model.UploadArchiveInput{File: upload, User: 1})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Failed)
+		require.Equal(t, "BAD_REQUEST", *result.Entries[0].Code)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		upload := archive("a.txt")
+		upload.Filename = "docs.rar"
+		_, err := resolver.UploadArchive(ctx, model.UploadArchiveInput{File: upload, User: 1})
+		require.Equal(t, gqlerror.ErrArchiveFormat, err)
+
+		serviceMock.EXPECT().Create(ctx, 1, 5, "a.txt", "", "", gomock.Any(), false).Return(&entity.File{ID: "1/a.txt"}, nil)
+		format := model.ArchiveFormatZip
+		_, err = resolver.UploadArchive(ctx, model.UploadArchiveInput{File: upload, User: 1, Format: &format})
+		require.NoError(t, err, "the format is not guessed when it is given")
+
+		_, err = resolver.UploadArchive(ctx, model.UploadArchiveInput{File: archive(), User: 1, Path: "../docs"})
+		require.Equal(t, gqlerror.ErrInvalidPath, err)
+
+		t.Setenv("ARCHIVE_MAX_ENTRIES", "1")
+		_, err = resolver.UploadArchive(ctx, model.UploadArchiveInput{File: archive("a.txt", "b.txt"), User: 1})
+		require.Equal(t, gqlerror.ErrArchiveEntries, err)
+
+		_, err = resolver.UploadArchive(ctx, model.UploadArchiveInput{File: graphql.Upload{File: bytes.NewReader([]byte("zip")), Filename: "a.zip"}, User: 1})
+		require.Equal(t, gqlerror.ErrInvalidArchiveFile, err)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	resolver := mutation{app: &app{service: serviceMock}}
+	ctx := context.Background()
+	id := func(key string) string {
+		return base64.StdEncoding.EncodeToString([]byte(key))
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		serviceMock.EXPECT().DeleteMany(ctx, []string{"1/a.txt", "1/c.txt"}).Return([]error{nil, service.ErrInvalidKey})
+
+		result, err := resolver.DeleteFiles(ctx, []string{id("1/a.txt"), "not base64!", id("1/c.txt")})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Succeeded)
+		require.Equal(t, 2, result.Failed)
+		require.Equal(t, id("1/a.txt"), result.Results[0].ID)
+		require.Nil(t, result.Results[0].Error)
+		require.Equal(t, "invalid id", *result.Results[1].Error)
+		require.Equal(t, "BAD_REQUEST", *result.Results[2].Code)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		serviceMock.EXPECT().Move(gomock.Any(), 1, "1/a.txt", "new/a.txt", false).Return(&entity.File{ID: "1/new/a.txt", Path: "new"}, nil)
+		serviceMock.EXPECT().Move(gomock.Any(), 1, "1/b.txt", "new/b.txt", false).Return(nil, service.ErrNotFound)
+
+		result, err := resolver.MoveFiles(ctx, []*model.MoveInput{
+			{ID: id("1/a.txt"), User: 1, NewPath: "new/a.txt"},
+			{ID: id("1/b.txt"), User: 1, NewPath: "new/b.txt"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Succeeded)
+		require.Equal(t, "new", result.Results[0].File.Path)
+		require.Nil(t, result.Results[1].File)
+		require.Equal(t, "NOT_FOUND", *result.Results[1].Code)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		t.Setenv("BATCH_CONCURRENCY", "2")
+		inputs := make([]*model.CopyInput, 10)
+		for i := range inputs {
+			inputs[i] = &model.CopyInput{ID: id("1/a.txt"), User: 2, NewPath: fmt.Sprintf("copies/%d.txt", i)}
+		}
+
+		var running, most int32
+		serviceMock.EXPECT().Copy(gomock.Any(), 2, "1/a.txt", gomock.Any(), false).Times(10).
+			DoAndReturn(func(_ context.Context, _ int, _, newPath string, _ bool) (*entity.File, error) {
+				now := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					seen := atomic.LoadInt32(&most)
+					if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+						break
+					}
+				}
+
+				time.Sleep(time.Millisecond)
+				return &entity.File{ID: "2/" + newPath}, nil
+			})
+
+		result, err := resolver.CopyFiles(ctx, inputs)
+		require.NoError(t, err)
+		require.Equal(t, 10, result.Succeeded)
+		require.LessOrEqual(t, most, int32(2))
+		require.Equal(t, base64.StdEncoding.EncodeToString([]byte("2/copies/9.txt")), result.Results[9].File.ID)
+
+		result, err = resolver.CopyFiles(ctx, []*model.CopyInput{{ID: id("1/a.txt"), User: 2, NewPath: "../a.txt"}})
+		require.NoError(t, err)
+		require.Equal(t, "path cannot contain '..'", *result.Results[0].Error)
+	})
+
+	t.Run("too many files", func(t *testing.T) {
+		t.Setenv("BATCH_MAX_SIZE", "1")
+		_, err := resolver.DeleteFiles(ctx, []string{id("1/a.txt"), id("1/b.txt")})
+		require.Equal(t, gqlerror.ErrBatchTooBig, err)
+	})
+}
+
+func TestIfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	resolver := mutation{app: &app{service: serviceMock}}
+	ctx := context.Background()
+	id := base64.StdEncoding.EncodeToString([]byte("1/a.txt"))
+	etag := "abc"
+
+	serviceMock.EXPECT().Move(gomock.Any(), 1, "1/a.txt", "b.txt", false).
+		DoAndReturn(func(ctx context.Context, _ int, _, _ string, _ bool) (*entity.File, error) {
+			require.Equal(t, "abc", service.IfMatch(ctx))
+			return nil, service.ErrPreconditionFailed
+		})
+	_, err := resolver.Move(ctx, model.MoveInput{ID: id, User: 1, NewPath: "b.txt", IfMatch: &etag})
+	require.Equal(t, gqlerror.ErrPreconditionFailed, err)
+
+	serviceMock.EXPECT().Delete(gomock.Any(), "1/a.txt").
+		DoAndReturn(func(ctx context.Context, _ string) error {
+			require.Empty(t, service.IfMatch(ctx), "not checked without ifMatch")
+			return nil
+		})
+	_, err = resolver.Delete(ctx, id, nil)
+	require.NoError(t, err)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	resolver := mutation{app: &app{service: serviceMock}}
+
+	// the context of a request sent with the Idempotency-Key header
+	var ctx context.Context
+	request := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	request.Header.Set(middleware.IdempotencyKeyHeader, "header")
+	middleware.RequestMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), request)
+
+	id := base64.StdEncoding.EncodeToString([]byte("1/a.txt"))
+	key := "input"
+	moved := func(newPath, expected string) {
+		serviceMock.EXPECT().Move(gomock.Any(), 1, "1/a.txt", newPath, false).
+			DoAndReturn(func(ctx context.Context, _ int, _, _ string, _ bool) (*entity.File, error) {
+				require.Equal(t, expected, service.IdempotencyKey(ctx))
+				return &entity.File{ID: "1/b.txt"}, nil
+			})
+	}
+
+	moved("b.txt", "header")
+	_, err := resolver.Move(ctx, model.MoveInput{ID: id, User: 1, NewPath: "b.txt"})
+	require.NoError(t, err)
+
+	moved("b.txt", "input")
+	_, err = resolver.Move(ctx, model.MoveInput{ID: id, User: 1, NewPath: "b.txt", IdempotencyKey: &key})
+	require.NoError(t, err, "the input takes precedence")
+
+	moved("b.txt", "")
+	moved("c.txt", "input")
+	_, err = resolver.MoveFiles(ctx, []*model.MoveInput{{ID: id, User: 1, NewPath: "b.txt"}, {ID: id, User: 1, NewPath: "c.txt", IdempotencyKey: &key}})
+	require.NoError(t, err, "the header is not used for every move of a batch")
+}
+
+func TestWebhooksAdminOnly(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	resolver := mutation{app: &app{service: serviceMock}}
+	input := model.WebhookInput{URL: "https://example.com/hook", Format: model.WebhookFormatJSON}
+
+	_, err := resolver.CreateWebhook(context.Background(), input)
+	require.Equal(t, gqlerror.ErrAdminOnly, err)
+	_, err = resolver.DeleteWebhook(context.Background(), 1)
+	require.Equal(t, gqlerror.ErrAdminOnly, err)
+	_, err = query{app: &app{service: serviceMock}}.Webhooks(context.Background())
+	require.Equal(t, gqlerror.ErrAdminOnly, err)
+
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Name: "ops", Admin: true})
+	serviceMock.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).Return(nil)
+	_, err = resolver.CreateWebhook(ctx, input)
+	require.NoError(t, err)
+}
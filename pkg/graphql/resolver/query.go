@@ -3,11 +3,26 @@ package resolver
 import (
 	"context"
 	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/archive"
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
 )
 
+var sortFields = map[model.FileSortField]index.SortField{
+	model.FileSortFieldName:      index.SortByName,
+	model.FileSortFieldSize:      index.SortBySize,
+	model.FileSortFieldCreatedAt: index.SortByCreatedAt,
+	model.FileSortFieldUpdatedAt: index.SortByUpdatedAt,
+}
+
 type query struct {
 	*app
 }
@@ -20,7 +35,7 @@ func (q query) ListUserFiles(ctx context.Context, user int, pathPrefix *string)
 
 	files, err := q.service.GetByUser(ctx, user, prefix)
 	if err != nil {
-		return nil, gqlerror.Error(err)
+		return nil, gqlerror.Error(ctx, err)
 	}
 
 	return model.NewFiles(files), nil
@@ -39,8 +54,197 @@ func (q query) File(ctx context.Context, id string) (*model.File, error) {
 
 	file, err := q.service.Get(ctx, string(key))
 	if err != nil {
-		return nil, gqlerror.Error(err)
+		return nil, gqlerror.Error(ctx, err)
 	}
 
 	return model.NewFile(file), nil
 }
+
+func (q query) SearchFiles(ctx context.Context, filter *model.FileFilter, sort *model.FileSort, page *model.PageInput) (*model.FilePage, error) {
+	var (
+		indexFilter index.Filter
+		indexSort   index.Sort
+		indexPage   index.Page
+	)
+
+	if filter != nil {
+		indexFilter = index.Filter{
+			User:          filter.User,
+			MinSize:       filter.MinSize,
+			MaxSize:       filter.MaxSize,
+			CreatedAfter:  filter.CreatedAfter,
+			CreatedBefore: filter.CreatedBefore,
+			UpdatedAfter:  filter.UpdatedAfter,
+			UpdatedBefore: filter.UpdatedBefore,
+		}
+
+		if filter.PathPrefix != nil {
+			if strings.Contains(*filter.PathPrefix, "..") {
+				return nil, gqlerror.ErrInvalidPath
+			}
+
+			indexFilter.PathPrefix = *filter.PathPrefix
+		}
+
+		if filter.Name != nil {
+			indexFilter.Name = *filter.Name
+		}
+
+		if filter.FileType != nil {
+			indexFilter.ContentType = *filter.FileType
+		}
+	}
+
+	if sort != nil {
+		indexSort = index.Sort{
+			Field: sortFields[sort.Field],
+			Desc:  sort.Desc,
+		}
+	}
+
+	if page != nil {
+		if page.Limit < 0 || page.Offset < 0 {
+			return nil, gqlerror.ErrInvalidPage
+		}
+
+		indexPage = index.Page{
+			Limit:  page.Limit,
+			Offset: page.Offset,
+		}
+	}
+
+	files, total, err := q.service.Search(ctx, indexFilter, indexSort, indexPage)
+	if err != nil {
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return &model.FilePage{
+		Files: model.NewFiles(files),
+		Total: total,
+	}, nil
+}
+
+func (q query) SearchContent(ctx context.Context, search string, user *int) ([]*model.ContentMatch, error) {
+	matches, err := q.service.SearchContent(ctx, search, user)
+	if err != nil {
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return model.NewContentMatches(matches), nil
+}
+
+func (q query) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	if !auth.FromContext(ctx).Admin {
+		return nil, gqlerror.ErrAdminOnly
+	}
+
+	hooks, err := q.service.Webhooks(ctx)
+	if err != nil {
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return model.NewWebhooks(hooks), nil
+}
+
+func (q query) WebhookDeliveries(ctx context.Context, webhookID *int, status *model.WebhookDeliveryStatus, limit int) ([]*model.WebhookDelivery, error) {
+	if !auth.FromContext(ctx).Admin {
+		return nil, gqlerror.ErrAdminOnly
+	}
+
+	if limit < 0 {
+		return nil, gqlerror.ErrInvalidPage
+	}
+
+	filter := webhook.DeliveryFilter{Limit: limit}
+	if webhookID != nil {
+		id := int64(*webhookID)
+		filter.WebhookID = &id
+	}
+
+	if status != nil {
+		filter.Status = webhook.Status(*status)
+	}
+
+	deliveries, err := q.service.WebhookDeliveries(ctx, filter)
+	if err != nil {
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return model.NewWebhookDeliveries(deliveries), nil
+}
+
+func (q query) AuditLog(ctx context.Context, filter *model.AuditFilter, page *model.PageInput) ([]*model.AuditEntry, error) {
+	if !auth.FromContext(ctx).Admin {
+		return nil, gqlerror.ErrAdminOnly
+	}
+
+	var auditFilter audit.Filter
+	if filter != nil {
+		auditFilter = audit.Filter{
+			User:   filter.User,
+			After:  filter.After,
+			Before: filter.Before,
+		}
+
+		if filter.Actor != nil {
+			auditFilter.Actor = *filter.Actor
+		}
+
+		if filter.Action != nil {
+			auditFilter.Action = audit.Action(*filter.Action)
+		}
+
+		if filter.Result != nil {
+			auditFilter.Result = audit.Result(*filter.Result)
+		}
+
+		if filter.ID != nil {
+			key, err := base64.StdEncoding.DecodeString(*filter.ID)
+			if err != nil {
+				return nil, gqlerror.ErrInvalidID
+			}
+
+			auditFilter.Key = string(key)
+		}
+	}
+
+	if page != nil {
+		if page.Limit < 0 || page.Offset < 0 {
+			return nil, gqlerror.ErrInvalidPage
+		}
+
+		auditFilter.Limit, auditFilter.Offset = page.Limit, page.Offset
+	}
+
+	entries, err := q.service.AuditLog(ctx, auditFilter)
+	if err != nil {
+		return nil, gqlerror.Error(ctx, err)
+	}
+
+	return model.NewAuditEntries(entries), nil
+}
+
+func (q query) ArchiveURL(_ context.Context, ids []string, user *int, pathPrefix *string, format model.ArchiveFormat) (string, error) {
+	values := url.Values{}
+	switch {
+	case len(ids) > 0:
+		values.Set("ids", strings.Join(ids, ","))
+	case user != nil:
+		values.Set("user", strconv.Itoa(*user))
+		if pathPrefix != nil {
+			if !validPath(*pathPrefix) {
+				return "", gqlerror.ErrInvalidPath
+			}
+
+			values.Set("prefix", *pathPrefix)
+		}
+	default:
+		return "", gqlerror.ErrInvalidArchive
+	}
+
+	if format == model.ArchiveFormatTarGz {
+		values.Set("format", string(archive.TarGz))
+	}
+
+	return model.ArchiveURL(values), nil
+}
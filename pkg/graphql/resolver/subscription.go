@@ -0,0 +1,43 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+)
+
+type subscription struct {
+	*app
+}
+
+func (s subscription) FileEvents(ctx context.Context, user int, pathPrefix *string) (<-chan *model.FileEvent, error) {
+	prefix := ""
+	if pathPrefix != nil {
+		if strings.Contains(*pathPrefix, "..") {
+			return nil, gqlerror.ErrInvalidPath
+		}
+
+		prefix = *pathPrefix
+	}
+
+	events := s.service.Subscribe(ctx)
+	result := make(chan *model.FileEvent)
+	go func() {
+		defer close(result)
+		for e := range events {
+			if !e.In(&user, prefix) {
+				continue
+			}
+
+			select {
+			case result <- model.NewFileEvent(e):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return result, nil
+}
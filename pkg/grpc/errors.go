@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var codeByError = map[error]codes.Code{
+	service.ErrNotFound:              codes.NotFound,
+	service.ErrDuplicateFile:         codes.AlreadyExists,
+	service.ErrInvalidKey:            codes.InvalidArgument,
+	service.ErrInvalidPath:           codes.InvalidArgument,
+	service.ErrContentTypeNotAllowed: codes.InvalidArgument,
+	service.ErrReservedPath:          codes.InvalidArgument,
+	service.ErrNotScanned:            codes.FailedPrecondition,
+}
+
+// toStatus maps service errors to the status with the closest code, unexpected errors are logged
+// and hidden from the client
+func toStatus(ctx context.Context, err error) error {
+	for known, code := range codeByError {
+		if errors.Is(err, known) {
+			return status.Error(code, known.Error())
+		}
+	}
+
+	var limited middleware.RateLimitedError
+	if errors.As(err, &limited) {
+		return status.Error(codes.ResourceExhausted, limited.Error())
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	logging.Error(ctx, "unexpected service error", "error", err)
+
+	return status.Error(codes.Unavailable, "service unavailable")
+}
@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// authenticate identifies the caller the same way as the http api, from the "authorization" metadata
+func authenticate(ctx context.Context, adminTokens map[string]string) (context.Context, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+
+	identity, err := auth.Authenticate(adminTokens, header)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return auth.WithIdentity(ctx, identity), nil
+}
+
+// withPeer keeps the address of the caller on ctx, the same way the http api does, so anonymous
+// callers are told apart by their ip
+func withPeer(ctx context.Context) context.Context {
+	caller, ok := peer.FromContext(ctx)
+	if !ok || caller.Addr == nil {
+		return ctx
+	}
+
+	ip, _, err := net.SplitHostPort(caller.Addr.String())
+	if err != nil {
+		ip = caller.Addr.String()
+	}
+
+	return middleware.WithClientIP(ctx, ip)
+}
+
+// logCall logs every call once it is answered, like the access log of the http api
+func logCall(ctx context.Context, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unavailable, codes.Unknown, codes.DataLoss:
+		level = slog.LevelError
+	}
+
+	logging.FromContext(ctx).Log(ctx, level, "call",
+		slog.String("code", code.String()),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	)
+}
+
+func unaryInterceptor(adminTokens map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = logging.With(withPeer(ctx), "rpc", info.FullMethod)
+		authenticated, err := authenticate(ctx, adminTokens)
+		if err != nil {
+			logCall(ctx, start, err)
+			return nil, err
+		}
+
+		resp, err := handler(authenticated, req)
+		logCall(authenticated, start, err)
+		return resp, err
+	}
+}
+
+func streamInterceptor(adminTokens map[string]string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := logging.With(withPeer(stream.Context()), "rpc", info.FullMethod)
+		authenticated, err := authenticate(ctx, adminTokens)
+		if err != nil {
+			logCall(ctx, start, err)
+			return err
+		}
+
+		err = handler(srv, &serverStream{ServerStream: stream, ctx: authenticated})
+		logCall(authenticated, start, err)
+		return err
+	}
+}
+
+// serverStream replaces the context of a stream with the one carrying the caller identity
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
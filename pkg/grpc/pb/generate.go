@@ -0,0 +1,4 @@
+// Package pb has the messages and service of the grpc api generated from fileapi.proto
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fileapi.proto
@@ -0,0 +1,217 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/grpc/pb"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// chunkSize is how many bytes of a file are sent on each download message
+const chunkSize = 64 * 1024
+
+var scanStatuses = map[entity.ScanStatus]pb.ScanStatus{
+	entity.ScanPending:  pb.ScanStatus_SCAN_STATUS_PENDING,
+	entity.ScanClean:    pb.ScanStatus_SCAN_STATUS_CLEAN,
+	entity.ScanInfected: pb.ScanStatus_SCAN_STATUS_INFECTED,
+	entity.ScanFailed:   pb.ScanStatus_SCAN_STATUS_FAILED,
+}
+
+// NewServer serves the same operations on files as the graphql api over grpc, identifying clients
+// by the same admin tokens sent on the "authorization" metadata
+func NewServer(service service.Service, adminTokens map[string]string) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptor(adminTokens)),
+		grpc.ChainStreamInterceptor(streamInterceptor(adminTokens)),
+	)
+	pb.RegisterFileServiceServer(server, &fileService{service: service})
+
+	return server
+}
+
+type fileService struct {
+	pb.UnimplementedFileServiceServer
+
+	service service.Service
+}
+
+// Upload expects the metadata of the file on the first message and its content on the next ones
+func (s *fileService) Upload(stream grpc.ClientStreamingServer[pb.UploadRequest, pb.File]) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	metadata := first.GetMetadata()
+	if metadata == nil {
+		return status.Error(codes.InvalidArgument, "the first message must have the metadata of the file")
+	}
+
+	if strings.Contains(metadata.GetPath(), "..") || strings.ContainsAny(metadata.GetName(), `/\`) || strings.Contains(metadata.GetName(), "..") {
+		return status.Error(codes.InvalidArgument, "invalid path")
+	}
+
+	var content bytes.Buffer
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if content.Len()+len(request.GetChunk()) > config.MaxUploadFileSize() {
+			return status.Error(codes.InvalidArgument, "file too big")
+		}
+
+		content.Write(request.GetChunk())
+	}
+
+	file, err := s.service.Create(ctx, int(metadata.GetUser()), content.Len(), metadata.GetName(), metadata.GetPath(),
+		metadata.GetContentType(), &content, metadata.GetOverwrite())
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+
+	return stream.SendAndClose(newFile(file))
+}
+
+// Download sends the file on the first message and its content on the next ones
+func (s *fileService) Download(request *pb.DownloadRequest, stream grpc.ServerStreamingServer[pb.DownloadResponse]) error {
+	ctx := stream.Context()
+	key, err := decodeID(request.GetId())
+	if err != nil {
+		return err
+	}
+
+	file, content, err := s.service.Download(ctx, key)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	defer content.Close()
+
+	if err := stream.Send(&pb.DownloadResponse{Data: &pb.DownloadResponse_File{File: newFile(file)}}); err != nil {
+		return err
+	}
+
+	buffer := make([]byte, chunkSize)
+	for {
+		n, err := content.Read(buffer)
+		if n > 0 {
+			if err := stream.Send(&pb.DownloadResponse{Data: &pb.DownloadResponse_Chunk{Chunk: buffer[:n]}}); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return toStatus(ctx, err)
+		}
+	}
+}
+
+func (s *fileService) Get(ctx context.Context, request *pb.GetRequest) (*pb.File, error) {
+	key, err := decodeID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := s.service.Get(ctx, key)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return newFile(file), nil
+}
+
+func (s *fileService) List(ctx context.Context, request *pb.ListRequest) (*pb.ListResponse, error) {
+	files, err := s.service.GetByUser(ctx, int(request.GetUser()), request.GetPathPrefix())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	response := &pb.ListResponse{Files: make([]*pb.File, 0, len(files))}
+	for _, file := range files {
+		if !file.IsEmpty() {
+			response.Files = append(response.Files, newFile(file))
+		}
+	}
+
+	return response, nil
+}
+
+func (s *fileService) Move(ctx context.Context, request *pb.MoveRequest) (*pb.File, error) {
+	key, err := decodeID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(request.GetNewPath(), "..") {
+		return nil, status.Error(codes.InvalidArgument, "invalid path")
+	}
+
+	file, err := s.service.Move(ctx, int(request.GetUser()), key, request.GetNewPath(), request.GetOverwrite())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return newFile(file), nil
+}
+
+func (s *fileService) Delete(ctx context.Context, request *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	key, err := decodeID(request.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.service.Delete(ctx, key); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &pb.DeleteResponse{}, nil
+}
+
+// decodeID decodes the same ids as the graphql api
+func decodeID(id string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(id)
+	if err != nil || len(key) == 0 {
+		return "", status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	return string(key), nil
+}
+
+func newFile(file *entity.File) *pb.File {
+	if file.IsEmpty() {
+		return nil
+	}
+
+	return &pb.File{
+		Id:          base64.StdEncoding.EncodeToString([]byte(file.ID)),
+		Name:        file.Name,
+		Path:        file.Path,
+		User:        int64(file.User),
+		FileType:    file.ContentType,
+		Size:        int64(file.Size),
+		CreatedAt:   timestamppb.New(file.CreatedAt),
+		UpdatedAt:   timestamppb.New(file.UpdatedAt),
+		DownloadUrl: model.ObjectURL(file.ID),
+		ScanStatus:  scanStatuses[file.ScanStatus],
+	}
+}
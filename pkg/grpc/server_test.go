@@ -0,0 +1,215 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/grpc/pb"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(serviceMock, map[string]string{"secret": "alice"})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewFileServiceClient(conn)
+	ctx := context.Background()
+	file := &entity.File{ID: "1/docs/a.txt", User: 1, Path: "docs", Name: "a.txt", ContentType: "text/plain", Size: 5, CreatedAt: time.Now(), ScanStatus: entity.ScanClean}
+	id := base64.StdEncoding.EncodeToString([]byte(file.ID))
+
+	t.Run("upload", func(t *testing.T) {
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 10, "a.txt", "docs", "text/plain", gomock.Any(), true).
+			DoAndReturn(func(_, _, _, _, _, _ interface{}, content io.Reader, _ bool) (*entity.File, error) {
+				read, _ := io.ReadAll(content)
+				require.Equal(t, "helloworld", string(read))
+				return file, nil
+			})
+
+		stream, err := client.Upload(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Metadata{Metadata: &pb.UploadMetadata{
+			User: 1, Path: "docs", Name: "a.txt", ContentType: "text/plain", Overwrite: true,
+		}}}))
+		require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Chunk{Chunk: []byte("hello")}}))
+		require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Chunk{Chunk: []byte("world")}}))
+
+		result, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		require.Equal(t, id, result.Id)
+		require.Equal(t, pb.ScanStatus_SCAN_STATUS_CLEAN, result.ScanStatus)
+	})
+
+	t.Run("upload without metadata", func(t *testing.T) {
+		stream, err := client.Upload(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Chunk{Chunk: []byte("hello")}}))
+
+		_, err = stream.CloseAndRecv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("upload outside of the path", func(t *testing.T) {
+		for _, name := range []string{"../../2/docs/x", "../../quarantine/1/a", `docs\a.txt`} {
+			stream, err := client.Upload(ctx)
+			require.NoError(t, err)
+			require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Metadata{Metadata: &pb.UploadMetadata{User: 1, Path: "docs", Name: name}}}))
+
+			_, err = stream.CloseAndRecv()
+			require.Equal(t, codes.InvalidArgument, status.Code(err), name)
+		}
+
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 0, "a.txt", "docs", "", gomock.Any(), false).Return(nil, service.ErrInvalidPath)
+		stream, err := client.Upload(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Metadata{Metadata: &pb.UploadMetadata{User: 1, Path: "docs", Name: "a.txt"}}}))
+
+		_, err = stream.CloseAndRecv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err), "names are checked by the service too")
+	})
+
+	t.Run("upload duplicate", func(t *testing.T) {
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 0, "a.txt", "docs", "", gomock.Any(), false).Return(nil, service.ErrDuplicateFile)
+
+		stream, err := client.Upload(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&pb.UploadRequest{Data: &pb.UploadRequest_Metadata{Metadata: &pb.UploadMetadata{User: 1, Path: "docs", Name: "a.txt"}}}))
+
+		_, err = stream.CloseAndRecv()
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("download", func(t *testing.T) {
+		content := strings.Repeat("a", chunkSize+10)
+		serviceMock.EXPECT().Download(gomock.Any(), file.ID).Return(file, io.NopCloser(strings.NewReader(content)), nil)
+
+		stream, err := client.Download(ctx, &pb.DownloadRequest{Id: id})
+		require.NoError(t, err)
+
+		first, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "a.txt", first.GetFile().GetName())
+
+		var read strings.Builder
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			read.Write(response.GetChunk())
+		}
+		require.Equal(t, content, read.String())
+	})
+
+	t.Run("download not scanned", func(t *testing.T) {
+		serviceMock.EXPECT().Download(gomock.Any(), file.ID).Return(nil, nil, service.ErrNotScanned)
+
+		stream, err := client.Download(ctx, &pb.DownloadRequest{Id: id})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("get", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(file, nil)
+
+		result, err := client.Get(ctx, &pb.GetRequest{Id: id})
+		require.NoError(t, err)
+		require.Equal(t, "docs", result.Path)
+		require.Equal(t, int64(5), result.Size)
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(nil, service.ErrNotFound)
+
+		_, err := client.Get(ctx, &pb.GetRequest{Id: id})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("get invalid id", func(t *testing.T) {
+		_, err := client.Get(ctx, &pb.GetRequest{Id: "%%%"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return([]*entity.File{file, {}}, nil)
+
+		result, err := client.List(ctx, &pb.ListRequest{User: 1, PathPrefix: "docs"})
+		require.NoError(t, err)
+		require.Len(t, result.Files, 1)
+	})
+
+	t.Run("move invalid key", func(t *testing.T) {
+		serviceMock.EXPECT().Move(gomock.Any(), 1, file.ID, "other", false).Return(nil, service.ErrInvalidKey)
+
+		_, err := client.Move(ctx, &pb.MoveRequest{Id: id, User: 1, NewPath: "other"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("move invalid path", func(t *testing.T) {
+		_, err := client.Move(ctx, &pb.MoveRequest{Id: id, User: 1, NewPath: "../2/other"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("delete as admin", func(t *testing.T) {
+		serviceMock.EXPECT().Delete(gomock.Any(), file.ID).DoAndReturn(func(ctx context.Context, _ string) error {
+			require.Equal(t, auth.Identity{Name: "alice", Admin: true}, auth.FromContext(ctx))
+			return nil
+		})
+
+		_, err := client.Delete(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret"), &pb.DeleteRequest{Id: id})
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		_, err := client.Delete(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong"), &pb.DeleteRequest{Id: id})
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("unexpected error", func(t *testing.T) {
+		serviceMock.EXPECT().Delete(gomock.Any(), file.ID).Return(io.ErrUnexpectedEOF)
+
+		_, err := client.Delete(ctx, &pb.DeleteRequest{Id: id})
+		require.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestWithPeer(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}})
+	require.Equal(t, "10.0.0.1", middleware.ClientIP(withPeer(ctx)))
+	require.Equal(t, "ip:10.0.0.1", middleware.ClientKey(withPeer(ctx)))
+	require.Empty(t, middleware.ClientIP(withPeer(context.Background())))
+}
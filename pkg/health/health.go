@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Timeout is how long every check has to answer before the dependency is considered down
+const Timeout = 2 * time.Second
+
+// Check verifies that a dependency the api needs to serve requests is reachable
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type dependency struct {
+	Status     string `json:"status"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+type response struct {
+	Status       string                `json:"status"`
+	Dependencies map[string]dependency `json:"dependencies,omitempty"`
+}
+
+// Live answers as long as the process is able to serve requests
+func Live(w http.ResponseWriter, _ *http.Request) {
+	write(w, http.StatusOK, response{Status: StatusUp})
+}
+
+// Ready runs every check concurrently, answering 503 when any of them fails. Errors are only logged,
+// the body is public and only tells which dependencies are down.
+func Ready(checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), Timeout)
+		defer cancel()
+
+		result := response{Status: StatusUp, Dependencies: make(map[string]dependency, len(checks))}
+		mu := sync.Mutex{}
+		wg := sync.WaitGroup{}
+		for _, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				start := time.Now()
+				err := check.Check(ctx)
+
+				status := dependency{Status: StatusUp, DurationMS: time.Since(start).Milliseconds()}
+				if err != nil {
+					status.Status = StatusDown
+					logging.Error(ctx, "dependency is down", "dependency", check.Name, "error", err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				result.Dependencies[check.Name] = status
+				if err != nil {
+					result.Status = StatusDown
+				}
+			}()
+		}
+		wg.Wait()
+
+		code := http.StatusOK
+		if result.Status != StatusUp {
+			code = http.StatusServiceUnavailable
+		}
+
+		write(w, code, result)
+	}
+}
+
+func write(w http.ResponseWriter, code int, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, handler http.HandlerFunc) (int, response) {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var body response
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	return recorder.Code, body
+}
+
+func TestLive(t *testing.T) {
+	code, body := get(t, Live)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, StatusUp, body.Status)
+}
+
+func TestReady(t *testing.T) {
+	up := Check{Name: "storage", Check: func(context.Context) error { return nil }}
+
+	t.Run("up", func(t *testing.T) {
+		code, body := get(t, Ready(up))
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, StatusUp, body.Status)
+		require.Equal(t, StatusUp, body.Dependencies["storage"].Status)
+	})
+
+	t.Run("down", func(t *testing.T) {
+		down := Check{Name: "database", Check: func(context.Context) error { return errors.New("connection refused") }}
+		code, body := get(t, Ready(up, down))
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, StatusDown, body.Status)
+		require.Equal(t, StatusUp, body.Dependencies["storage"].Status)
+		require.Equal(t, StatusDown, body.Dependencies["database"].Status)
+
+		recorder := httptest.NewRecorder()
+		Ready(down)(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.NotContains(t, recorder.Body.String(), "connection refused", "errors are only logged")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		slow := Check{Name: "storage", Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}}
+
+		code, body := get(t, Ready(slow))
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, StatusDown, body.Dependencies["storage"].Status)
+	})
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/archive"
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+)
+
+// archiveEntry is a file and its name inside the archive
+type archiveEntry struct {
+	name string
+	file *entity.File
+}
+
+// archiveHandler answers "GET /archive?ids=id1,id2" and "GET /archive?user=1&prefix=docs" with an
+// archive of the files, built while it is sent. Entries are named relative to the prefix, or to the
+// directory every picked file is in.
+type archiveHandler struct {
+	service service.Service
+}
+
+func (a archiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	format, err := archive.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeError(w, gqlerror.ErrArchiveFormat)
+		return
+	}
+
+	name, entries, err := a.entries(ctx, r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var total int64
+	for _, entry := range entries {
+		total += int64(entry.file.Size)
+	}
+
+	if len(entries) > config.ArchiveMaxEntries() {
+		writeError(w, gqlerror.ErrArchiveEntries)
+		return
+	}
+
+	if total > config.ArchiveMaxSize() {
+		writeError(w, gqlerror.ErrArchiveTooBig)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+string(format)))
+
+	// once the archive started there is no way to answer an error, failures cut it short instead
+	writer := archive.NewWriter(w, format)
+	var written int64
+	for _, entry := range entries {
+		file, content, err := a.service.Download(ctx, entry.file.ID)
+		if err != nil {
+			logging.Error(ctx, "could not add file to archive", "key", entry.file.ID, "error", err)
+			return
+		}
+
+		// files could have grown since they were listed
+		if written += int64(file.Size); written > config.ArchiveMaxSize() {
+			content.Close()
+			logging.Warn(ctx, "archive went over the max size while it was written", "key", file.ID)
+			return
+		}
+
+		err = writer.Add(entry.name, int64(file.Size), file.UpdatedAt, content)
+		content.Close()
+		if err != nil {
+			logging.Error(ctx, "could not add file to archive", "key", file.ID, "error", err)
+			return
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		logging.Error(ctx, "could not finish archive", "error", err)
+	}
+}
+
+// entries lists the files picked by query along with the name of the archive
+func (a archiveHandler) entries(ctx context.Context, query url.Values) (string, []archiveEntry, error) {
+	if ids := query.Get("ids"); ids != "" {
+		return a.entriesByID(ctx, strings.Split(ids, ","))
+	}
+
+	if query.Get("user") == "" {
+		return "", nil, gqlerror.ErrInvalidArchive
+	}
+
+	user, err := strconv.Atoi(query.Get("user"))
+	if err != nil {
+		return "", nil, gqlerror.ErrInvalidUser
+	}
+
+	prefix := strings.Trim(query.Get("prefix"), "/")
+	if strings.Contains(prefix, "..") {
+		return "", nil, gqlerror.ErrInvalidPath
+	}
+
+	files, err := a.service.GetByUser(ctx, user, prefix)
+	if err != nil {
+		return "", nil, gqlerror.Error(ctx, err)
+	}
+
+	// the listing also has files that only start with the prefix, such as docs2 for docs
+	base := strconv.Itoa(user)
+	if prefix != "" {
+		base += "/" + prefix
+	}
+
+	var entries []archiveEntry
+	for _, file := range files {
+		if strings.HasPrefix(file.ID, base+"/") {
+			entries = append(entries, archiveEntry{name: strings.TrimPrefix(file.ID, base+"/"), file: file})
+		}
+	}
+
+	if len(entries) == 0 {
+		return "", nil, gqlerror.ErrNotFound
+	}
+
+	return path.Base(base), entries, nil
+}
+
+func (a archiveHandler) entriesByID(ctx context.Context, ids []string) (string, []archiveEntry, error) {
+	// each file is read before the archive starts
+	if len(ids) > config.ArchiveMaxEntries() {
+		return "", nil, gqlerror.ErrArchiveEntries
+	}
+
+	seen := map[string]bool{}
+	var files []*entity.File
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+
+		key, err := decodeID(id)
+		if err != nil {
+			return "", nil, gqlerror.ErrInvalidID
+		}
+
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		file, err := a.service.Get(ctx, key)
+		if err != nil {
+			return "", nil, gqlerror.Error(ctx, err)
+		}
+
+		if file.ScanStatus.Quarantined() {
+			return "", nil, gqlerror.ErrNotScanned
+		}
+
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return "", nil, gqlerror.ErrInvalidArchive
+	}
+
+	dir := path.Dir(files[0].ID)
+	for _, file := range files[1:] {
+		for dir != "." && !strings.HasPrefix(file.ID, dir+"/") {
+			dir = path.Dir(dir)
+		}
+	}
+
+	entries := make([]archiveEntry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, archiveEntry{name: strings.TrimPrefix(file.ID, dir+"/"), file: file})
+	}
+
+	name := path.Base(dir)
+	if dir == "." {
+		name = "files"
+	}
+
+	return name, entries, nil
+}
@@ -0,0 +1,128 @@
+package http
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	handler := archiveHandler{service: serviceMock}
+	files := []*entity.File{
+		{ID: "1/docs/a.txt", User: 1, Path: "docs", Name: "a.txt", Size: 5, UpdatedAt: time.Now()},
+		{ID: "1/docs/img/b.txt", User: 1, Path: "docs/img", Name: "b.txt", Size: 5, UpdatedAt: time.Now()},
+		{ID: "1/docs2/c.txt", User: 1, Path: "docs2", Name: "c.txt", Size: 5, UpdatedAt: time.Now()},
+	}
+	contents := map[string]string{"1/docs/a.txt": "hello", "1/docs/img/b.txt": "world", "1/docs2/c.txt": "other"}
+
+	download := func(file *entity.File) {
+		serviceMock.EXPECT().Download(gomock.Any(), file.ID).Return(file, io.NopCloser(strings.NewReader(contents[file.ID])), nil)
+	}
+
+	send := func(query url.Values) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/archive?"+query.Encode(), nil))
+		return recorder
+	}
+
+	t.Run("zip of a prefix", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return(files, nil)
+		download(files[0])
+		download(files[1])
+
+		recorder := send(url.Values{"user": {"1"}, "prefix": {"docs/"}})
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+		require.Equal(t, `attachment; filename="docs.zip"`, recorder.Header().Get("Content-Disposition"))
+
+		body := recorder.Body.Bytes()
+		reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		require.NoError(t, err)
+		require.Len(t, reader.File, 2)
+		require.Equal(t, "a.txt", reader.File[0].Name)
+		require.Equal(t, "img/b.txt", reader.File[1].Name)
+	})
+
+	t.Run("tar.gz of ids", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), files[1].ID).Return(files[1], nil)
+		serviceMock.EXPECT().Get(gomock.Any(), files[2].ID).Return(files[2], nil)
+		download(files[1])
+		download(files[2])
+
+		ids := base64.StdEncoding.EncodeToString([]byte(files[1].ID)) + "," + base64.StdEncoding.EncodeToString([]byte(files[2].ID))
+		recorder := send(url.Values{"ids": {ids}, "format": {"tar.gz"}})
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, `attachment; filename="1.tar.gz"`, recorder.Header().Get("Content-Disposition"))
+
+		gz, err := gzip.NewReader(recorder.Body)
+		require.NoError(t, err)
+		reader := tar.NewReader(gz)
+
+		var names []string
+		for {
+			header, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			names = append(names, header.Name)
+		}
+		require.Equal(t, []string{"docs/img/b.txt", "docs2/c.txt"}, names)
+	})
+
+	t.Run("quarantined file", func(t *testing.T) {
+		pending := *files[0]
+		pending.ScanStatus = entity.ScanPending
+		serviceMock.EXPECT().Get(gomock.Any(), files[0].ID).Return(&pending, nil)
+
+		recorder := send(url.Values{"ids": {base64.StdEncoding.EncodeToString([]byte(files[0].ID))}})
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("too big", func(t *testing.T) {
+		t.Setenv("ARCHIVE_MAX_SIZE", "9")
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return(files, nil)
+
+		recorder := send(url.Values{"user": {"1"}, "prefix": {"docs"}})
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Contains(t, recorder.Body.String(), "too big")
+	})
+
+	t.Run("empty prefix", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 2, "").Return(nil, nil)
+
+		recorder := send(url.Values{"user": {"2"}})
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), files[0].ID).Return(nil, service.ErrNotFound)
+
+		recorder := send(url.Values{"ids": {base64.StdEncoding.EncodeToString([]byte(files[0].ID))}})
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("nothing picked", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, send(url.Values{}).Code)
+		require.Equal(t, http.StatusBadRequest, send(url.Values{"user": {"1"}, "format": {"rar"}}).Code)
+	})
+}
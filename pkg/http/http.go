@@ -3,24 +3,75 @@ package http
 import (
 	"net/http"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql"
 	"github.com/rafaelrubbioli/fileapi/pkg/graphql/explorer"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/persisted"
+	"github.com/rafaelrubbioli/fileapi/pkg/health"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/metrics"
 	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
 	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	"github.com/rafaelrubbioli/fileapi/pkg/tracing"
 
 	"github.com/go-chi/chi"
-	chimiddleware "github.com/go-chi/chi/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-func NewServer(service service.Service) (http.Handler, error) {
+// NewServer routes the api, /readyz checks the storage of service along with every check given
+func NewServer(service service.Service, checks ...health.Check) (http.Handler, error) {
+	adminTokens, err := auth.ParseTokens(config.AdminTokens())
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := middleware.ParseLimits(config.RateLimits())
+	if err != nil {
+		return nil, err
+	}
+
+	var allowlist map[string]string
+	if path := config.QueryAllowlist(); path != "" {
+		if allowlist, err = persisted.Load(path); err != nil {
+			return nil, err
+		}
+	}
+
 	r := chi.NewRouter()
-	r.Use(chimiddleware.DefaultLogger)
-	graphqlHandler := graphql.NewHandler(service)
-	r.With(middleware.CorsMiddleware).
-		Route("/graphql", func(r chi.Router) {
-			r.Handle("/", http.HandlerFunc(graphqlHandler.Handle))
-			r.Get("/explorer", explorer.Handler)
-		})
-
-	return r, nil
+	r.Use(middleware.RequestMiddleware)
+	r.Use(logging.Middleware)
+	r.Use(tracing.RouteMiddleware)
+	r.Handle("/metrics", metrics.Handler())
+	r.Get("/healthz", health.Live)
+	r.Get("/readyz", health.Ready(append([]health.Check{{Name: "storage", Check: service.Ping}}, checks...)...))
+	// shared by every api, so clients have the same budget on all of them
+	limiters := middleware.NewLimiters(limits, config.UploadBandwidth())
+	graphqlHandler := graphql.NewHandler(service, limiters, allowlist)
+	api := r.With(middleware.CorsMiddleware, auth.Middleware(adminTokens))
+	if limiter := limiters.Get(middleware.DefaultLimit); limiter != nil {
+		api = api.With(middleware.RateLimitMiddleware(limiter))
+	}
+
+	api.Route("/graphql", func(r chi.Router) {
+		r.Handle("/", http.HandlerFunc(graphqlHandler.Handle))
+		r.Get("/explorer", explorer.Handler)
+	})
+	api.Mount("/v1", newREST(service, limiters))
+	api.Method(http.MethodGet, "/archive", archiveHandler{service: service})
+
+	return otelhttp.NewHandler(r, "http",
+		// renamed after the route by tracing.RouteMiddleware, paths have ids in them
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/metrics", "/healthz", "/readyz":
+				return false
+			default:
+				return true
+			}
+		}),
+	), nil
 }
@@ -0,0 +1,280 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/gqlerror"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/model"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	parsererror "github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+const moveSuffix = ":move"
+
+// maxMoveBodySize is the largest move body read, far above what a move needs
+const maxMoveBodySize = 64 << 10
+
+// statusByCode answers errors with the status matching their graphql error code
+var statusByCode = map[gqlerror.ErrorType]int{
+	gqlerror.NotFoundType:             http.StatusNotFound,
+	gqlerror.BadRequestType:           http.StatusBadRequest,
+	gqlerror.DuplicatedType:           http.StatusConflict,
+	gqlerror.UnauthorizedType:         http.StatusForbidden,
+	gqlerror.UnsupportedMediaTypeType: http.StatusUnsupportedMediaType,
+	gqlerror.RateLimitedType:          http.StatusTooManyRequests,
+	gqlerror.PreconditionFailedType:   http.StatusPreconditionFailed,
+	gqlerror.ServiceUnavailableType:   http.StatusServiceUnavailable,
+}
+
+type moveInput struct {
+	User      int    `json:"user"`
+	NewPath   string `json:"newPath"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// rest serves the same operations on files as the graphql api for clients that can not send
+// multipart requests, answering with the same files and error codes
+type rest struct {
+	service service.Service
+	// upload limits how often each client uploads, nil when it is not limited
+	upload *middleware.Limiter
+	// bandwidth throttles uploads of each client, nil when they are not throttled
+	bandwidth *middleware.Limiter
+}
+
+// newREST serves the rest api, limiters are the same ones the graphql api uses
+func newREST(service service.Service, limiters *middleware.Limiters) http.Handler {
+	api := rest{service: service, upload: limiters.Get("upload"), bandwidth: limiters.Bandwidth()}
+
+	r := chi.NewRouter()
+	r.Get("/openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	r.Put("/files/*", api.create)
+	r.Get("/files/*", api.get)
+	r.Delete("/files/*", api.delete)
+	r.Post("/files/*", api.move)
+	r.Get("/users/{user}/files", api.list)
+
+	return r
+}
+
+// create uploads the body to the key on the path, "{user}/{path}/{name}"
+func (a rest) create(w http.ResponseWriter, r *http.Request) {
+	key, err := url.PathUnescape(chi.URLParam(r, "*"))
+	if err != nil || strings.Contains(key, "..") {
+		writeError(w, gqlerror.ErrInvalidPath)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(key, "/"), "/")
+	user, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) < 2 {
+		writeError(w, gqlerror.ErrInvalidPath)
+		return
+	}
+
+	if a.upload != nil {
+		if err := a.upload.Allow(middleware.ClientKey(r.Context())); err != nil {
+			writeError(w, gqlerror.RateLimited(err.(middleware.RateLimitedError)))
+			return
+		}
+	}
+
+	var body io.Reader = r.Body
+	if a.bandwidth != nil {
+		body = middleware.Throttle(r.Context(), a.bandwidth, middleware.ClientKey(r.Context()), body)
+	}
+
+	// read up to one byte over the limit to tell when the body is too big without reading all of it
+	content, err := io.ReadAll(io.LimitReader(body, int64(config.MaxUploadFileSize())+1))
+	if err != nil {
+		writeError(w, gqlerror.Error(r.Context(), err))
+		return
+	}
+
+	if len(content) > config.MaxUploadFileSize() {
+		writeError(w, gqlerror.ErrFileTooBig)
+		return
+	}
+
+	name, path := parts[len(parts)-1], strings.Join(parts[1:len(parts)-1], "/")
+	overwrite := r.URL.Query().Get("overwrite") == "true"
+	file, err := a.service.Create(changeContext(r), user, len(content), name, path, r.Header.Get("Content-Type"), bytes.NewReader(content), overwrite)
+	if err != nil {
+		writeError(w, gqlerror.Error(r.Context(), err))
+		return
+	}
+
+	writeFile(w, http.StatusCreated, file)
+}
+
+func (a rest) get(w http.ResponseWriter, r *http.Request) {
+	key, err := decodeID(chi.URLParam(r, "*"))
+	if err != nil {
+		writeError(w, gqlerror.ErrInvalidID)
+		return
+	}
+
+	file, err := a.service.Get(r.Context(), key)
+	if err != nil {
+		writeError(w, gqlerror.Error(r.Context(), err))
+		return
+	}
+
+	writeFile(w, http.StatusOK, file)
+}
+
+func (a rest) delete(w http.ResponseWriter, r *http.Request) {
+	key, err := decodeID(chi.URLParam(r, "*"))
+	if err != nil {
+		writeError(w, gqlerror.ErrInvalidID)
+		return
+	}
+
+	if err := a.service.Delete(changeContext(r), key); err != nil {
+		writeError(w, gqlerror.Error(r.Context(), err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// move answers "POST /files/{id}:move" with the moved file
+func (a rest) move(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "*")
+	if !strings.HasSuffix(id, moveSuffix) {
+		writeError(w, gqlerror.ErrNotFound)
+		return
+	}
+
+	key, err := decodeID(strings.TrimSuffix(id, moveSuffix))
+	if err != nil {
+		writeError(w, gqlerror.ErrInvalidID)
+		return
+	}
+
+	var input moveInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMoveBodySize)).Decode(&input); err != nil {
+		writeError(w, gqlerror.ErrInvalidBody)
+		return
+	}
+
+	if strings.Contains(input.NewPath, "..") {
+		writeError(w, gqlerror.ErrInvalidPath)
+		return
+	}
+
+	file, err := a.service.Move(changeContext(r), input.User, key, input.NewPath, input.Overwrite)
+	if err != nil {
+		writeError(w, gqlerror.Error(r.Context(), err))
+		return
+	}
+
+	writeFile(w, http.StatusOK, file)
+}
+
+func (a rest) list(w http.ResponseWriter, r *http.Request) {
+	user, err := strconv.Atoi(chi.URLParam(r, "user"))
+	if err != nil {
+		writeError(w, gqlerror.ErrInvalidUser)
+		return
+	}
+
+	files, err := a.service.GetByUser(r.Context(), user, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, gqlerror.Error(r.Context(), err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, model.NewFiles(files))
+}
+
+// decodeID decodes the same ids as the graphql api, which can also be url encoded
+func decodeID(id string) (string, error) {
+	id, err := url.PathUnescape(id)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(id)
+	if err != nil {
+		key, err = base64.URLEncoding.DecodeString(id)
+	}
+
+	return string(key), err
+}
+
+// changeContext is the context of r, making changes depend on the etag of its If-Match header
+// and run once for its Idempotency-Key header when it has them
+func changeContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if etag := r.Header.Get("If-Match"); etag != "" {
+		ctx = service.WithIfMatch(ctx, etag)
+	}
+
+	if key := r.Header.Get(middleware.IdempotencyKeyHeader); key != "" {
+		ctx = service.WithIdempotencyKey(ctx, key)
+	}
+
+	return ctx
+}
+
+// writeFile answers with file along with its etag, which clients send back as If-Match
+func writeFile(w http.ResponseWriter, status int, file *entity.File) {
+	if file.ETag != "" {
+		w.Header().Set("ETag", `"`+file.ETag+`"`)
+	}
+
+	writeJSON(w, status, model.NewFile(file))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError answers with the message and extensions of the graphql error err maps to
+func writeError(w http.ResponseWriter, err error) {
+	var gqlErr *parsererror.Error
+	if !errors.As(err, &gqlErr) {
+		gqlErr = gqlerror.ErrServiceUnavailable
+	}
+
+	code, _ := gqlErr.Extensions[gqlerror.ErrCodeLabel].(gqlerror.ErrorType)
+	status, ok := statusByCode[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	if retryAfter, ok := gqlErr.Extensions["retryAfter"]; ok {
+		w.Header().Set("Retry-After", fmt.Sprint(retryAfter))
+	}
+
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":    gqlErr.Message,
+			"extensions": gqlErr.Extensions,
+		},
+	})
+}
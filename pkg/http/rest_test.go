@@ -0,0 +1,183 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/service"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type restError struct {
+	Error struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"error"`
+}
+
+func TestREST(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	handler := newREST(serviceMock, middleware.NewLimiters(map[string]middleware.Limit{"upload": {Rate: 0.1, Burst: 2}}, 0))
+	file := &entity.File{ID: "1/docs/a.txt", User: 1, Path: "docs", Name: "a.txt", ContentType: "text/plain", Size: 5, CreatedAt: time.Now()}
+	id := base64.StdEncoding.EncodeToString([]byte(file.ID))
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	decodeError := func(recorder *httptest.ResponseRecorder) restError {
+		var result restError
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&result))
+		return result
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		serviceMock.EXPECT().Create(gomock.Any(), 1, 5, "a.txt", "docs", "", gomock.Any(), true).
+			DoAndReturn(func(_, _, _, _, _, _ interface{}, content io.Reader, _ bool) (*entity.File, error) {
+				read, _ := io.ReadAll(content)
+				require.Equal(t, "hello", string(read))
+				return file, nil
+			})
+
+		recorder := send(http.MethodPut, "/files/1/docs/a.txt?overwrite=true", "hello")
+		require.Equal(t, http.StatusCreated, recorder.Code)
+
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&result))
+		require.Equal(t, id, result["id"])
+		require.Equal(t, "text/plain", result["fileType"])
+	})
+
+	t.Run("upload errors", func(t *testing.T) {
+		recorder := send(http.MethodPut, "/files/1/docs/big.txt", strings.Repeat("a", 501))
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, "max file size is 500b", decodeError(recorder).Error.Message)
+
+		recorder = send(http.MethodPut, "/files/a.txt", "hello")
+		require.Equal(t, http.StatusBadRequest, recorder.Code, "no user")
+		require.Equal(t, "BAD_REQUEST", decodeError(recorder).Error.Extensions["code"])
+
+		recorder = send(http.MethodPut, "/files/1/docs/a.txt", "hello")
+		require.Equal(t, http.StatusTooManyRequests, recorder.Code, "upload limit")
+		require.Equal(t, "10", recorder.Header().Get("Retry-After"))
+		require.Equal(t, "RATE_LIMITED", decodeError(recorder).Error.Extensions["code"])
+	})
+
+	t.Run("get", func(t *testing.T) {
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(file, nil).Times(2)
+		require.Equal(t, http.StatusOK, send(http.MethodGet, "/files/"+id, "").Code)
+		require.Equal(t, http.StatusOK, send(http.MethodGet, "/files/"+url.PathEscape(base64.URLEncoding.EncodeToString([]byte(file.ID))), "").Code)
+
+		serviceMock.EXPECT().Get(gomock.Any(), "1/missing.txt").Return(nil, service.ErrNotFound)
+		recorder := send(http.MethodGet, "/files/"+base64.StdEncoding.EncodeToString([]byte("1/missing.txt")), "")
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+		require.Equal(t, "NOT_FOUND", decodeError(recorder).Error.Extensions["code"])
+
+		recorder = send(http.MethodGet, "/files/not-base64!", "")
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, "invalid id", decodeError(recorder).Error.Message)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		serviceMock.EXPECT().Delete(gomock.Any(), file.ID).Return(nil)
+		require.Equal(t, http.StatusNoContent, send(http.MethodDelete, "/files/"+id, "").Code)
+	})
+
+	t.Run("if match", func(t *testing.T) {
+		tagged := *file
+		tagged.ETag = "abc"
+		serviceMock.EXPECT().Get(gomock.Any(), file.ID).Return(&tagged, nil)
+		recorder := send(http.MethodGet, "/files/"+id, "")
+		require.Equal(t, `"abc"`, recorder.Header().Get("ETag"))
+
+		serviceMock.EXPECT().Delete(gomock.Any(), file.ID).
+			DoAndReturn(func(ctx context.Context, _ string) error {
+				require.Equal(t, "abc", service.IfMatch(ctx))
+				return service.ErrPreconditionFailed
+			})
+
+		request := httptest.NewRequest(http.MethodDelete, "/files/"+id, nil)
+		request.Header.Set("If-Match", `"abc"`)
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		require.Equal(t, "PRECONDITION_FAILED", decodeError(recorder).Error.Extensions["code"])
+	})
+
+	t.Run("idempotency key", func(t *testing.T) {
+		serviceMock.EXPECT().Move(gomock.Any(), 2, file.ID, "other/a.txt", false).
+			DoAndReturn(func(ctx context.Context, _ int, _, _ string, _ bool) (*entity.File, error) {
+				require.Equal(t, "retry-1", service.IdempotencyKey(ctx))
+				return nil, service.ErrIdempotencyKeyReused
+			})
+
+		request := httptest.NewRequest(http.MethodPost, "/files/"+id+":move", strings.NewReader(`{"user": 2, "newPath": "other/a.txt"}`))
+		request.Header.Set("Idempotency-Key", "retry-1")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusBadRequest, recorder.Code)
+		require.Equal(t, "idempotency key was already used for another request", decodeError(recorder).Error.Message)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		moved := *file
+		moved.ID, moved.Path = "2/other/a.txt", "other"
+		serviceMock.EXPECT().Move(gomock.Any(), 2, file.ID, "other/a.txt", false).Return(&moved, nil)
+
+		recorder := send(http.MethodPost, "/files/"+id+":move", `{"user": 2, "newPath": "other/a.txt"}`)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&result))
+		require.Equal(t, "other", result["path"])
+
+		require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/files/"+id+":move", `{`).Code)
+		require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/files/"+id+":move", `{"user": 2, "newPath": "../1/a.txt"}`).Code)
+		require.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/files/"+id+":move", `{"newPath": "`+strings.Repeat("a", maxMoveBodySize)+`"}`).Code)
+		require.Equal(t, http.StatusNotFound, send(http.MethodPost, "/files/"+id, `{}`).Code)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "docs").Return([]*entity.File{file}, nil)
+		recorder := send(http.MethodGet, "/users/1/files?prefix=docs", "")
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var result []map[string]interface{}
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&result))
+		require.Len(t, result, 1)
+
+		require.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/users/me/files", "").Code)
+	})
+
+	t.Run("openapi", func(t *testing.T) {
+		recorder := send(http.MethodGet, "/openapi.json", "")
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+		var document struct {
+			OpenAPI string                 `json:"openapi"`
+			Paths   map[string]interface{} `json:"paths"`
+		}
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&document))
+		require.Equal(t, "3.0.3", document.OpenAPI)
+		require.Contains(t, document.Paths, "/files/{file}:move")
+	})
+}
@@ -0,0 +1,64 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("idempotency key not found")
+
+// Record is the result of the first request made with a key, replayed to the requests retried with it
+type Record struct {
+	Key string
+	// Fingerprint tells apart retries from other requests that reuse the key
+	Fingerprint string
+	Result      []byte
+	ExpiresAt   time.Time
+}
+
+type Store interface {
+	// Get returns the record of key, ErrNotFound when there is none or it expired
+	Get(ctx context.Context, key string) (*Record, error)
+	// Put stores the record, replacing the one with the same key
+	Put(ctx context.Context, record *Record) error
+}
+
+// NewMemory keeps records in memory, they are lost on restarts and not shared between instances
+func NewMemory() Store {
+	return &memory{records: map[string]Record{}}
+}
+
+type memory struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func (m *memory) Get(_ context.Context, key string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.records[key]
+	if !ok || !record.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	return &record, nil
+}
+
+func (m *memory) Put(_ context.Context, record *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// expired records are only dropped here, so they don't pile up on instances that never read them
+	now := time.Now()
+	for key, stored := range m.records {
+		if !stored.ExpiresAt.After(now) {
+			delete(m.records, key)
+		}
+	}
+
+	m.records[record.Key] = *record
+	return nil
+}
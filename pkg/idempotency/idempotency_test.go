@@ -0,0 +1,46 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/database"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.Open(database.Memory)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	sqlite, err := NewSQLite(db)
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"memory": NewMemory(), "sqlite": sqlite} {
+		t.Run(name, func(t *testing.T) {
+			_, err := store.Get(ctx, "upload:admin:a")
+			require.Equal(t, ErrNotFound, err)
+
+			record := &Record{Key: "upload:admin:a", Fingerprint: "1 docs a.txt", Result: []byte(`{"ID":"1/docs/a.txt"}`), ExpiresAt: time.Now().Add(time.Hour)}
+			require.NoError(t, store.Put(ctx, record))
+
+			result, err := store.Get(ctx, "upload:admin:a")
+			require.NoError(t, err)
+			require.Equal(t, record.Fingerprint, result.Fingerprint)
+			require.Equal(t, record.Result, result.Result)
+			require.True(t, record.ExpiresAt.Equal(result.ExpiresAt))
+
+			record.Result = []byte(`{"ID":"1/docs/b.txt"}`)
+			require.NoError(t, store.Put(ctx, record))
+			result, err = store.Get(ctx, "upload:admin:a")
+			require.NoError(t, err)
+			require.Equal(t, record.Result, result.Result, "replaced")
+
+			require.NoError(t, store.Put(ctx, &Record{Key: "upload:admin:b", Result: []byte("{}"), ExpiresAt: time.Now().Add(-time.Second)}))
+			_, err = store.Get(ctx, "upload:admin:b")
+			require.Equal(t, ErrNotFound, err, "expired")
+		})
+	}
+}
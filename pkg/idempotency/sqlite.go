@@ -0,0 +1,61 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key         TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	result      BLOB NOT NULL,
+	expires_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at ON idempotency_keys (expires_at);
+`
+
+func NewSQLite(db *sql.DB) (Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to migrate idempotency keys: %w", err)
+	}
+
+	return sqlite{db: db}, nil
+}
+
+type sqlite struct {
+	db *sql.DB
+}
+
+func (s sqlite) Get(ctx context.Context, key string) (*Record, error) {
+	record := &Record{Key: key}
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT fingerprint, result, expires_at FROM idempotency_keys WHERE key = ? AND expires_at > ?",
+		key, time.Now().UnixNano(),
+	).Scan(&record.Fingerprint, &record.Result, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.ExpiresAt = time.Unix(0, expiresAt)
+	return record, nil
+}
+
+func (s sqlite) Put(ctx context.Context, record *Record) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", time.Now().UnixNano()); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO idempotency_keys (key, fingerprint, result, expires_at)
+		VALUES (?, ?, ?, ?)`,
+		record.Key, record.Fingerprint, record.Result, record.ExpiresAt.UnixNano(),
+	)
+	return err
+}
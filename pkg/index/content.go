@@ -0,0 +1,33 @@
+package index
+
+import (
+	"mime"
+	"strings"
+)
+
+var textContentTypes = map[string]bool{
+	"application/json": true,
+	"application/csv":  true,
+}
+
+// IsText reports if files of contentType should have their content indexed
+func IsText(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		textContentTypes[mediaType]
+}
+
+// matchQuery turns free text into a fts query where every word must be present
+func matchQuery(query string) string {
+	terms := strings.Fields(query)
+	for i, term := range terms {
+		terms[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+
+	return strings.Join(terms, " ")
+}
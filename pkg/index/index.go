@@ -0,0 +1,64 @@
+//go:generate go run -mod=mod github.com/golang/mock/mockgen -package=mocks -source=$GOFILE -destination=../../test/mock/index.go
+package index
+
+import (
+	"context"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+)
+
+const (
+	DefaultPageSize  = 50
+	MaxContentLength = 1 << 20
+)
+
+type SortField string
+
+const (
+	SortByName      SortField = "name"
+	SortBySize      SortField = "size"
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+)
+
+// Filter narrows a search, zero values are ignored
+type Filter struct {
+	User          *int
+	PathPrefix    string
+	Name          string
+	ContentType   string
+	MinSize       *int
+	MaxSize       *int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+}
+
+type Sort struct {
+	Field SortField
+	Desc  bool
+}
+
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Match is a file found by its content with the matching part highlighted
+type Match struct {
+	File *entity.File
+	// Snippet is html escaped content with the matched words in <mark> tags
+	Snippet string
+}
+
+type Index interface {
+	Put(ctx context.Context, file *entity.File) error
+	PutContent(ctx context.Context, id, content string) error
+	Move(ctx context.Context, oldID string, file *entity.File) error
+	Delete(ctx context.Context, id string) error
+	Search(ctx context.Context, filter Filter, sort Sort, page Page) ([]*entity.File, int, error)
+	SearchContent(ctx context.Context, query string, user *int, limit int) ([]Match, error)
+	Reset(ctx context.Context) error
+}
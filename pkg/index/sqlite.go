@@ -0,0 +1,350 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS files (
+	id           TEXT PRIMARY KEY,
+	user         INTEGER NOT NULL,
+	path         TEXT NOT NULL,
+	name         TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size         INTEGER NOT NULL,
+	created_at   INTEGER NOT NULL,
+	updated_at   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_user_path ON files (user, path);
+CREATE INDEX IF NOT EXISTS files_created_at ON files (created_at);
+CREATE INDEX IF NOT EXISTS files_updated_at ON files (updated_at);
+CREATE VIRTUAL TABLE IF NOT EXISTS contents USING fts5 (body, tokenize = 'porter unicode61');
+`
+
+const fileColumns = "files.id, files.user, files.path, files.name, files.content_type, files.size, files.created_at, files.updated_at"
+
+var sortColumns = map[SortField]string{
+	SortByName:      "name",
+	SortBySize:      "size",
+	SortByCreatedAt: "created_at",
+	SortByUpdatedAt: "updated_at",
+}
+
+func NewSQLite(db *sql.DB) (Index, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to migrate index: %w", err)
+	}
+
+	return sqlite{db: db}, nil
+}
+
+type sqlite struct {
+	db *sql.DB
+}
+
+func (s sqlite) Put(ctx context.Context, file *entity.File) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO files (id, user, path, name, content_type, size, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			user = excluded.user,
+			path = excluded.path,
+			name = excluded.name,
+			content_type = excluded.content_type,
+			size = excluded.size,
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at`,
+		file.ID, file.User, cleanPath(file.Path), file.Name, file.ContentType, file.Size,
+		file.CreatedAt.UnixNano(), file.UpdatedAt.UnixNano(),
+	)
+	return err
+}
+
+// PutContent replaces the searchable content of an indexed file, empty content removes it
+func (s sqlite) PutContent(ctx context.Context, id, content string) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM contents WHERE rowid = (SELECT rowid FROM files WHERE id = ?)", id)
+		if err != nil || content == "" {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO contents (rowid, body) SELECT rowid, ? FROM files WHERE id = ?", content, id)
+		return err
+	})
+}
+
+// Move renames an indexed file keeping its content, replacing whatever was on the new key
+func (s sqlite) Move(ctx context.Context, oldID string, file *entity.File) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		if file.ID != oldID {
+			if err := deleteFile(ctx, tx, file.ID); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE files SET id = ?, user = ?, path = ?, name = ?, content_type = ?, size = ?, created_at = ?, updated_at = ?
+			WHERE id = ?`,
+			file.ID, file.User, cleanPath(file.Path), file.Name, file.ContentType, file.Size,
+			file.CreatedAt.UnixNano(), file.UpdatedAt.UnixNano(), oldID,
+		)
+		if err != nil {
+			return err
+		}
+
+		if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+			return err
+		}
+
+		// the old file was never indexed, so there is no content to keep
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO files (id, user, path, name, content_type, size, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			file.ID, file.User, cleanPath(file.Path), file.Name, file.ContentType, file.Size,
+			file.CreatedAt.UnixNano(), file.UpdatedAt.UnixNano(),
+		)
+		return err
+	})
+}
+
+func (s sqlite) Delete(ctx context.Context, id string) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		return deleteFile(ctx, tx, id)
+	})
+}
+
+func (s sqlite) Search(ctx context.Context, filter Filter, sort Sort, page Page) ([]*entity.File, int, error) {
+	where, args := buildWhere(filter)
+
+	var total int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM files"+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := sortColumns[sort.Field]
+	if !ok {
+		column = sortColumns[SortByName]
+	}
+
+	direction := "ASC"
+	if sort.Desc {
+		direction = "DESC"
+	}
+
+	if page.Limit <= 0 {
+		page.Limit = DefaultPageSize
+	}
+
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM files%s ORDER BY %s %s, id %s LIMIT ? OFFSET ?",
+		fileColumns, where, column, direction, direction,
+	)
+
+	rows, err := s.db.QueryContext(ctx, query, append(args, page.Limit, page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := make([]*entity.File, 0, page.Limit)
+	for rows.Next() {
+		file, err := scanFile(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, total, rows.Err()
+}
+
+func (s sqlite) SearchContent(ctx context.Context, query string, user *int, limit int) ([]Match, error) {
+	match := matchQuery(query)
+	if match == "" {
+		return []Match{}, nil
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	args := []interface{}{markStart, markEnd, match}
+	where := ""
+	if user != nil {
+		where = " AND files.user = ?"
+		args = append(args, *user)
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s, snippet(contents, 0, ?, ?, '…', 16)
+		FROM contents JOIN files ON files.rowid = contents.rowid
+		WHERE contents MATCH ?%s
+		ORDER BY contents.rank
+		LIMIT ?`, fileColumns, where),
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]Match, 0, limit)
+	for rows.Next() {
+		var snippet string
+		file, err := scanFile(rows, &snippet)
+		if err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, Match{File: file, Snippet: highlight(snippet)})
+	}
+
+	return matches, rows.Err()
+}
+
+// markStart and markEnd wrap the matched words until the snippet is escaped,
+// they are private use characters so they do not clash with the content
+const (
+	markStart = "\uE000"
+	markEnd   = "\uE001"
+)
+
+var marks = strings.NewReplacer(markStart, "<mark>", markEnd, "</mark>")
+
+// highlight escapes the file content in the snippet, so the only html left
+// are the <mark> tags around the matched words
+func highlight(snippet string) string {
+	return marks.Replace(html.EscapeString(snippet))
+}
+
+func (s sqlite) Reset(ctx context.Context) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM contents"); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "DELETE FROM files")
+		return err
+	})
+}
+
+func (s sqlite) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteFile(ctx context.Context, tx *sql.Tx, id string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM contents WHERE rowid = (SELECT rowid FROM files WHERE id = ?)", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM files WHERE id = ?", id)
+	return err
+}
+
+func scanFile(rows *sql.Rows, extra ...interface{}) (*entity.File, error) {
+	var (
+		file                 entity.File
+		createdAt, updatedAt int64
+	)
+
+	dest := append([]interface{}{&file.ID, &file.User, &file.Path, &file.Name, &file.ContentType, &file.Size, &createdAt, &updatedAt}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	file.CreatedAt = time.Unix(0, createdAt)
+	file.UpdatedAt = time.Unix(0, updatedAt)
+	return &file, nil
+}
+
+func buildWhere(filter Filter) (string, []interface{}) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	add := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if filter.User != nil {
+		add("user = ?", *filter.User)
+	}
+
+	if filter.PathPrefix != "" {
+		add(`path LIKE ? ESCAPE '\'`, escapeLike(cleanPath(filter.PathPrefix))+"%")
+	}
+
+	if filter.Name != "" {
+		add(`name LIKE ? ESCAPE '\'`, "%"+escapeLike(filter.Name)+"%")
+	}
+
+	if filter.ContentType != "" {
+		add(`content_type LIKE ? ESCAPE '\'`, escapeLike(filter.ContentType)+"%")
+	}
+
+	if filter.MinSize != nil {
+		add("size >= ?", *filter.MinSize)
+	}
+
+	if filter.MaxSize != nil {
+		add("size <= ?", *filter.MaxSize)
+	}
+
+	if filter.CreatedAfter != nil {
+		add("created_at >= ?", filter.CreatedAfter.UnixNano())
+	}
+
+	if filter.CreatedBefore != nil {
+		add("created_at <= ?", filter.CreatedBefore.UnixNano())
+	}
+
+	if filter.UpdatedAfter != nil {
+		add("updated_at >= ?", filter.UpdatedAfter.UnixNano())
+	}
+
+	if filter.UpdatedBefore != nil {
+		add("updated_at <= ?", filter.UpdatedBefore.UnixNano())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// cleanPath stores paths the same way keys are parsed back, without leading or trailing slashes
+func cleanPath(value string) string {
+	return strings.Trim(path.Clean("/"+value), "/")
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
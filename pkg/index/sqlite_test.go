@@ -0,0 +1,209 @@
+package index
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/database"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIndex(t *testing.T) Index {
+	db, err := database.Open(database.Memory)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	idx, err := NewSQLite(db)
+	require.NoError(t, err)
+	return idx
+}
+
+func TestSQLite_Search(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestIndex(t)
+
+	now := time.Now()
+	files := []*entity.File{
+		{ID: "1/docs/report.pdf", User: 1, Path: "docs/", Name: "report.pdf", ContentType: "application/pdf", Size: 300, CreatedAt: now.Add(-2 * time.Hour), UpdatedAt: now},
+		{ID: "1/pics/cat.png", User: 1, Path: "pics", Name: "cat.png", ContentType: "image/png", Size: 100, CreatedAt: now.Add(-time.Hour), UpdatedAt: now},
+		{ID: "2/pics/dog_1.jpg", User: 2, Path: "pics", Name: "dog_1.jpg", ContentType: "image/jpeg", Size: 200, CreatedAt: now, UpdatedAt: now},
+	}
+	for _, file := range files {
+		require.NoError(t, idx.Put(ctx, file))
+	}
+
+	ids := func(files []*entity.File) []string {
+		result := make([]string, 0, len(files))
+		for _, file := range files {
+			result = append(result, file.ID)
+		}
+		return result
+	}
+
+	t.Run("no filter sorts by name", func(t *testing.T) {
+		result, total, err := idx.Search(ctx, Filter{}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		require.Equal(t, []string{"1/pics/cat.png", "2/pics/dog_1.jpg", "1/docs/report.pdf"}, ids(result))
+		require.Equal(t, "docs", result[2].Path)
+		require.Equal(t, files[0].CreatedAt.UnixNano(), result[2].CreatedAt.UnixNano())
+	})
+
+	t.Run("user and path", func(t *testing.T) {
+		user := 1
+		result, total, err := idx.Search(ctx, Filter{User: &user, PathPrefix: "/pics/"}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Equal(t, []string{"1/pics/cat.png"}, ids(result))
+	})
+
+	t.Run("name substring escapes wildcards", func(t *testing.T) {
+		result, _, err := idx.Search(ctx, Filter{Name: "_1"}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"2/pics/dog_1.jpg"}, ids(result))
+
+		result, _, err = idx.Search(ctx, Filter{Name: "%"}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Empty(t, result)
+	})
+
+	t.Run("content type prefix and size range", func(t *testing.T) {
+		minSize, maxSize := 150, 250
+		result, _, err := idx.Search(ctx, Filter{ContentType: "image/", MinSize: &minSize, MaxSize: &maxSize}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"2/pics/dog_1.jpg"}, ids(result))
+	})
+
+	t.Run("date range", func(t *testing.T) {
+		after, before := now.Add(-90*time.Minute), now.Add(-time.Minute)
+		result, _, err := idx.Search(ctx, Filter{CreatedAfter: &after, CreatedBefore: &before}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, []string{"1/pics/cat.png"}, ids(result))
+	})
+
+	t.Run("sort and page", func(t *testing.T) {
+		result, total, err := idx.Search(ctx, Filter{}, Sort{Field: SortBySize, Desc: true}, Page{Limit: 2, Offset: 1})
+		require.NoError(t, err)
+		require.Equal(t, 3, total)
+		require.Equal(t, []string{"2/pics/dog_1.jpg", "1/pics/cat.png"}, ids(result))
+	})
+
+	t.Run("put replaces and delete removes", func(t *testing.T) {
+		updated := *files[1]
+		updated.Size = 1000
+		require.NoError(t, idx.Put(ctx, &updated))
+		require.NoError(t, idx.Delete(ctx, files[0].ID))
+
+		result, total, err := idx.Search(ctx, Filter{}, Sort{Field: SortBySize}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, 2, total)
+		require.Equal(t, 1000, result[1].Size)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		require.NoError(t, idx.Reset(ctx))
+		_, total, err := idx.Search(ctx, Filter{}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Zero(t, total)
+	})
+}
+
+func TestSQLite_SearchContent(t *testing.T) {
+	ctx := context.Background()
+	idx := newTestIndex(t)
+
+	now := time.Now()
+	notes := &entity.File{ID: "1/notes.md", User: 1, Name: "notes.md", ContentType: "text/markdown", CreatedAt: now, UpdatedAt: now}
+	data := &entity.File{ID: "2/data.csv", User: 2, Name: "data.csv", ContentType: "text/csv", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, idx.Put(ctx, notes))
+	require.NoError(t, idx.PutContent(ctx, notes.ID, "# Meeting notes\nwe decided to migrate the storage buckets"))
+	require.NoError(t, idx.Put(ctx, data))
+	require.NoError(t, idx.PutContent(ctx, data.ID, "bucket,region\nfileapi,sa-east-1"))
+
+	t.Run("stemmed match with snippet", func(t *testing.T) {
+		matches, err := idx.SearchContent(ctx, "bucket", nil, 0)
+		require.NoError(t, err)
+		require.Len(t, matches, 2)
+		require.Contains(t, matches[0].Snippet+matches[1].Snippet, "<mark>buckets</mark>")
+	})
+
+	t.Run("escaped content", func(t *testing.T) {
+		page := &entity.File{ID: "1/page.md", User: 1, Name: "page.md", ContentType: "text/markdown", CreatedAt: now, UpdatedAt: now}
+		require.NoError(t, idx.Put(ctx, page))
+		require.NoError(t, idx.PutContent(ctx, page.ID, `<script>alert("payload")</script>`))
+
+		matches, err := idx.SearchContent(ctx, "payload", nil, 0)
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		require.Equal(t, `&lt;script&gt;alert(&#34;<mark>payload</mark>&#34;)&lt;/script&gt;`, matches[0].Snippet)
+		require.NoError(t, idx.Delete(ctx, page.ID))
+	})
+
+	t.Run("every word must match", func(t *testing.T) {
+		matches, err := idx.SearchContent(ctx, `migrate "storage`, nil, 0)
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		require.Equal(t, notes.ID, matches[0].File.ID)
+		require.Equal(t, "text/markdown", matches[0].File.ContentType)
+	})
+
+	t.Run("filter by user", func(t *testing.T) {
+		user := 2
+		matches, err := idx.SearchContent(ctx, "bucket", &user, 0)
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		require.Equal(t, data.ID, matches[0].File.ID)
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		matches, err := idx.SearchContent(ctx, "  ", nil, 0)
+		require.NoError(t, err)
+		require.Empty(t, matches)
+	})
+
+	t.Run("move keeps content", func(t *testing.T) {
+		moved := *notes
+		moved.ID, moved.User = "2/notes.md", 2
+		require.NoError(t, idx.Move(ctx, notes.ID, &moved))
+
+		matches, err := idx.SearchContent(ctx, "meeting", nil, 0)
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		require.Equal(t, moved.ID, matches[0].File.ID)
+		require.Equal(t, 2, matches[0].File.User)
+	})
+
+	t.Run("move over existing file", func(t *testing.T) {
+		moved := *data
+		moved.ID = "2/notes.md"
+		require.NoError(t, idx.Move(ctx, data.ID, &moved))
+
+		matches, err := idx.SearchContent(ctx, "meeting", nil, 0)
+		require.NoError(t, err)
+		require.Empty(t, matches)
+
+		_, total, err := idx.Search(ctx, Filter{}, Sort{}, Page{})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+	})
+
+	t.Run("delete removes content", func(t *testing.T) {
+		require.NoError(t, idx.Delete(ctx, "2/notes.md"))
+
+		matches, err := idx.SearchContent(ctx, "bucket", nil, 0)
+		require.NoError(t, err)
+		require.Empty(t, matches)
+	})
+}
+
+func TestIsText(t *testing.T) {
+	require.True(t, IsText("text/plain; charset=utf-8"))
+	require.True(t, IsText("text/markdown"))
+	require.True(t, IsText("text/csv"))
+	require.True(t, IsText("application/json"))
+	require.True(t, IsText("application/ld+json"))
+	require.False(t, IsText("image/png"))
+	require.False(t, IsText(""))
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Extension adds the graphql operation name to every line logged while it runs
+type Extension struct{}
+
+func (Extension) ExtensionName() string {
+	return "Logging"
+}
+
+func (Extension) Validate(_ graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Extension) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	operationContext := graphql.GetOperationContext(ctx)
+	name := operationContext.OperationName
+	if name == "" && operationContext.Operation != nil {
+		name = operationContext.Operation.Name
+	}
+
+	if name != "" {
+		ctx = With(ctx, "operation", name)
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var result []map[string]interface{}
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		line := map[string]interface{}{}
+		require.NoError(t, decoder.Decode(&line))
+		result = append(result, line)
+	}
+
+	return result
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("context attributes", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		ctx := NewContext(context.Background(), New(buf, slog.LevelInfo))
+		ctx = With(ctx, "user", 1, "key", "1/cat.png")
+		ctx = With(ctx, "operation", "upload")
+
+		Debug(ctx, "hidden")
+		Error(ctx, "failed", "error", "boom")
+
+		logged := lines(t, buf)
+		require.Len(t, logged, 1)
+		require.Equal(t, "ERROR", logged[0]["level"])
+		require.Equal(t, "failed", logged[0]["msg"])
+		require.Equal(t, float64(1), logged[0]["user"])
+		require.Equal(t, "1/cat.png", logged[0]["key"])
+		require.Equal(t, "upload", logged[0]["operation"])
+		require.Equal(t, "boom", logged[0]["error"])
+		require.NotContains(t, logged[0], "request_id")
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		logger := New(buf, slog.LevelInfo)
+		handler := middleware.RequestMiddleware(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Info(r.Context(), "handling")
+			w.WriteHeader(http.StatusTeapot)
+		})))
+
+		request := httptest.NewRequest(http.MethodGet, "/query", nil)
+		request.Header.Set(middleware.RequestIDHeader, "abc")
+		request = request.WithContext(NewContext(request.Context(), logger))
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		logged := lines(t, buf)
+		require.Len(t, logged, 2)
+		require.Equal(t, "abc", logged[0]["request_id"])
+		require.Equal(t, "request", logged[1]["msg"])
+		require.Equal(t, "abc", logged[1]["request_id"])
+		require.Equal(t, "/query", logged[1]["path"])
+		require.Equal(t, float64(http.StatusTeapot), logged[1]["status"])
+	})
+}
+
+func TestParseLevel(t *testing.T) {
+	require.Equal(t, slog.LevelDebug, ParseLevel("debug"))
+	require.Equal(t, slog.LevelWarn, ParseLevel("WARN"))
+	require.Equal(t, slog.LevelInfo, ParseLevel("verbose"))
+}
@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/middleware"
+)
+
+// Middleware logs every request once it is answered
+func Middleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		writer := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		h.ServeHTTP(writer, r)
+
+		status := writer.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		FromContext(r.Context()).Log(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", writer.BytesWritten()),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+		)
+	}
+
+	return http.HandlerFunc(fn)
+}
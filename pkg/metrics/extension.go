@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const (
+	// unnamed labels operations sent without a name
+	unnamed = "unnamed"
+	// other labels operations past the first maxOperations names, so clients can't add a label for every name they send
+	other = "other"
+)
+
+// maxOperations is how many operation names get their own label besides the registered ones
+const maxOperations = 100
+
+// Extension counts and times graphql queries and mutations by operation name
+type Extension struct {
+	// Operations are the registered operation names, which always get their own label
+	Operations map[string]bool
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (*Extension) ExtensionName() string {
+	return "Metrics"
+}
+
+func (*Extension) Validate(_ graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (e *Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	response := next(ctx)
+	if !graphql.HasOperationContext(ctx) {
+		return response
+	}
+
+	operationContext := graphql.GetOperationContext(ctx)
+	if operationContext.Operation == nil || operationContext.Operation.Operation == ast.Subscription {
+		// subscriptions are long lived and each of their events is a response
+		return response
+	}
+
+	name := operationContext.OperationName
+	if name == "" {
+		name = operationContext.Operation.Name
+	}
+
+	if name == "" {
+		name = unnamed
+	}
+	name = e.label(name)
+
+	operationType := string(operationContext.Operation.Operation)
+	result := "success"
+	if response == nil || len(response.Errors) > 0 {
+		result = "error"
+	}
+
+	OperationsTotal.WithLabelValues(name, operationType, result).Inc()
+	OperationDuration.WithLabelValues(name, operationType).Observe(time.Since(operationContext.Stats.OperationStart).Seconds())
+	return response
+}
+
+// label is the name operations are counted under, new names are labelled as other once there are maxOperations
+func (e *Extension) label(name string) string {
+	if name == unnamed || e.Operations[name] {
+		return name
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.seen == nil {
+		e.seen = map[string]bool{}
+	}
+
+	if !e.seen[name] {
+		if len(e.seen) >= maxOperations {
+			return other
+		}
+
+		e.seen[name] = true
+	}
+
+	return name
+}
@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql/persisted"
+	"github.com/rafaelrubbioli/fileapi/pkg/metrics"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtension(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	serviceMock.EXPECT().GetByUser(gomock.Any(), 2, "").Return(nil, nil).AnyTimes()
+
+	// admins can run queries that are not registered
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Name: "admin", Admin: true})
+	query := func(handler graphql.Handler, body string) {
+		request := httptest.NewRequest(http.MethodPost, "/graphql/", strings.NewReader(body)).WithContext(ctx)
+		request.Header.Set("Content-Type", "application/json")
+		handler.Handle(httptest.NewRecorder(), request)
+	}
+
+	t.Run("without allowlist", func(t *testing.T) {
+		handler := graphql.NewHandler(serviceMock, nil, nil)
+
+		serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "").Return(nil, nil)
+		query(handler, `{"query": "query files { listUserFiles(user: 1) { id } }"}`)
+		require.Equal(t, float64(1), testutil.ToFloat64(metrics.OperationsTotal.WithLabelValues("files", "query", "success")))
+
+		serviceMock.EXPECT().Delete(gomock.Any(), "1/a.txt").Return(errors.New("unavailable"))
+		query(handler, `{"query": "mutation { delete(id: \"MS9hLnR4dA==\") }"}`)
+		require.Equal(t, float64(1), testutil.ToFloat64(metrics.OperationsTotal.WithLabelValues("unnamed", "mutation", "error")))
+		require.Equal(t, 2, testutil.CollectAndCount(metrics.OperationDuration))
+
+		for i := 0; i < 150; i++ {
+			query(handler, fmt.Sprintf(`{"query": "query random%d { listUserFiles(user: 2) { id } }"}`, i))
+		}
+		require.Equal(t, float64(1), testutil.ToFloat64(metrics.OperationsTotal.WithLabelValues("random0", "query", "success")))
+		require.Equal(t, float64(51), testutil.ToFloat64(metrics.OperationsTotal.WithLabelValues("other", "query", "success")),
+			"names past the first 100 are counted together")
+	})
+
+	t.Run("registered operations", func(t *testing.T) {
+		registered := "query registered { listUserFiles(user: 2) { id } }"
+		handler := graphql.NewHandler(serviceMock, nil, map[string]string{persisted.Hash(registered): registered})
+		for i := 0; i < 150; i++ {
+			query(handler, fmt.Sprintf(`{"query": "query unregistered%d { listUserFiles(user: 2) { id } }"}`, i))
+		}
+
+		query(handler, `{"query": "`+registered+`"}`)
+		require.Equal(t, float64(1), testutil.ToFloat64(metrics.OperationsTotal.WithLabelValues("registered", "query", "success")),
+			"registered names always have their own label")
+	})
+}
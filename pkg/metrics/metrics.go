@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "fileapi"
+
+// Registry holds every fileapi metric along with the go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	OperationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "graphql",
+		Name:      "operations_total",
+		Help:      "GraphQL operations by operation name, type and result.",
+	}, []string{"operation", "type", "result"})
+
+	OperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "graphql",
+		Name:      "operation_duration_seconds",
+		Help:      "Time to answer GraphQL operations by operation name and type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+
+	S3RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken by S3 calls by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	S3RequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "request_errors_total",
+		Help:      "Failed S3 calls by method.",
+	}, []string{"method"})
+
+	UploadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "uploaded_bytes_total",
+		Help:      "Bytes sent to S3.",
+	})
+
+	DownloadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "s3",
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes read from S3.",
+	})
+
+	UploadsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "uploads_in_flight",
+		Help:      "Uploads currently being processed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		OperationsTotal,
+		OperationDuration,
+		S3RequestDuration,
+		S3RequestErrors,
+		UploadedBytes,
+		DownloadedBytes,
+		UploadsInFlight,
+	)
+}
+
+// Handler serves the metrics in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -2,17 +2,20 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
 )
 
 func CorsMiddleware(h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
-		if len(origin) > 0 {
+		if len(origin) > 0 && AllowedOrigin(origin) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Methods", "PUT, POST, GET, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Expose-Headers", "Date")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Authorization, X-Cluster, Referer")
+			w.Header().Set("Access-Control-Expose-Headers", "Date, X-Request-ID, Retry-After, ETag")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Authorization, X-Cluster, Referer, X-Request-ID, If-Match, Idempotency-Key")
 
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusNoContent)
@@ -25,3 +28,20 @@ func CorsMiddleware(h http.Handler) http.Handler {
 
 	return http.HandlerFunc(fn)
 }
+
+// AllowedOrigin reports if browsers on origin can call the api, requests without an origin don't
+// come from browsers and are always allowed
+func AllowedOrigin(origin string) bool {
+	allowed := config.AllowedOrigins()
+	if origin == "" || len(allowed) == 0 {
+		return true
+	}
+
+	for _, other := range allowed {
+		if strings.EqualFold(origin, other) {
+			return true
+		}
+	}
+
+	return false
+}
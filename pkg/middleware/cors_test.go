@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorsMiddleware(t *testing.T) {
+	handler := CorsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(origin string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/graphql", nil)
+		request.Header.Set("Origin", origin)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("every origin", func(t *testing.T) {
+		require.Equal(t, "https://other.com", send("https://other.com").Header().Get("Access-Control-Allow-Origin"))
+		require.True(t, AllowedOrigin("https://other.com"))
+	})
+
+	t.Run("allowed origins", func(t *testing.T) {
+		t.Setenv("ALLOWED_ORIGINS", "https://app.example.com, https://admin.example.com")
+		require.Equal(t, "https://app.example.com", send("https://app.example.com").Header().Get("Access-Control-Allow-Origin"))
+		require.Empty(t, send("https://other.com").Header().Get("Access-Control-Allow-Origin"))
+		require.False(t, AllowedOrigin("https://other.com"))
+		require.True(t, AllowedOrigin(""), "requests without an origin are not from browsers")
+	})
+}
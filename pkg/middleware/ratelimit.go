@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+)
+
+// DefaultLimit is the name of the limit applied to every request
+const DefaultLimit = "default"
+
+// sweepInterval is how often buckets that refilled completely are forgotten
+const sweepInterval = time.Minute
+
+// Limit allows Rate events per second on average, with bursts of up to Burst events
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimits parses limits in the format "name:rate/burst,name:rate/burst", rate is per second
+func ParseLimits(value string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, limit, ok := strings.Cut(pair, ":")
+		rate, burst, _ := strings.Cut(limit, "/")
+		parsedRate, rateErr := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		parsedBurst, burstErr := strconv.Atoi(strings.TrimSpace(burst))
+		if !ok || strings.TrimSpace(name) == "" || rateErr != nil || burstErr != nil || parsedRate <= 0 || parsedBurst <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q, expected name:rate/burst", pair)
+		}
+
+		limits[strings.TrimSpace(name)] = Limit{Rate: parsedRate, Burst: parsedBurst}
+	}
+
+	return limits, nil
+}
+
+// RateLimitedError is returned when a client has to wait RetryAfter before trying again
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds the wait up, so retrying after it always succeeds
+func (e RateLimitedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter is a token bucket for each key
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:     limit,
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key, returning a RateLimitedError when it is empty
+func (l *Limiter) Allow(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, time.Now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return nil
+	}
+
+	return RateLimitedError{RetryAfter: l.duration(1 - b.tokens)}
+}
+
+// reserve takes n tokens from the bucket of key even when it goes into debt, returning how long to
+// wait until the debt is paid
+func (l *Limiter) reserve(key string, n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, time.Now())
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return l.duration(-b.tokens)
+}
+
+// bucket refills and returns the bucket of key, which must be called holding mu
+func (l *Limiter) bucket(key string, now time.Time) *bucket {
+	if now.Sub(l.lastSweep) > sweepInterval {
+		for k, b := range l.buckets {
+			if l.refill(b, now); b.tokens >= float64(l.limit.Burst) {
+				delete(l.buckets, k)
+			}
+		}
+
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	l.refill(b, now)
+	return b
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// Limiters has a limiter for every limit and for the upload bandwidth. They are created once and shared
+// by every api, so clients have the same budget on all of them. A nil Limiters limits nothing.
+type Limiters struct {
+	byName    map[string]*Limiter
+	bandwidth *Limiter
+}
+
+// NewLimiters creates the limiter of every limit, and of bandwidth bytes per second when it is over 0
+func NewLimiters(limits map[string]Limit, bandwidth int) *Limiters {
+	limiters := &Limiters{byName: make(map[string]*Limiter, len(limits))}
+	for name, limit := range limits {
+		limiters.byName[name] = NewLimiter(limit)
+	}
+
+	if bandwidth > 0 {
+		limiters.bandwidth = NewLimiter(Limit{Rate: float64(bandwidth), Burst: bandwidth})
+	}
+
+	return limiters
+}
+
+// Get is the limiter of the limit called name, nil when there is no such limit
+func (l *Limiters) Get(name string) *Limiter {
+	if l == nil {
+		return nil
+	}
+
+	return l.byName[name]
+}
+
+// Bandwidth throttles uploads of each client, nil when they are not throttled
+func (l *Limiters) Bandwidth() *Limiter {
+	if l == nil {
+		return nil
+	}
+
+	return l.bandwidth
+}
+
+// ClientKey identifies who is sending the request ctx belongs to for rate limiting, the
+// authenticated identity when there is one and the client ip otherwise
+func ClientKey(ctx context.Context) string {
+	if identity := auth.FromContext(ctx); identity.Name != auth.Anonymous {
+		return "user:" + identity.Name
+	}
+
+	return "ip:" + ClientIP(ctx)
+}
+
+// RateLimitMiddleware answers 429 to clients that sent more requests than limiter allows.
+// It has to run after the request and auth middlewares, which identify the client.
+func RateLimitMiddleware(limiter *Limiter) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			err := limiter.Allow(ClientKey(r.Context()))
+			if err == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			limited := err.(RateLimitedError)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", strconv.Itoa(limited.RetryAfterSeconds()))
+			w.WriteHeader(http.StatusTooManyRequests)
+
+			// shaped like a graphql error so clients handle it the same way as the ones from operations
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"errors": []map[string]interface{}{{
+					"message": "rate limited",
+					"extensions": map[string]interface{}{
+						"code":       "RATE_LIMITED",
+						"retryAfter": limited.RetryAfterSeconds(),
+					},
+				}},
+			})
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Throttle slows down reading from r so key does not go over the bytes per second of limiter,
+// reading fails with the context error once ctx is done
+func Throttle(ctx context.Context, limiter *Limiter, key string, r io.Reader) io.Reader {
+	return &throttledReader{ctx: ctx, limiter: limiter, key: key, reader: r}
+}
+
+type throttledReader struct {
+	ctx     context.Context
+	limiter *Limiter
+	key     string
+	reader  io.Reader
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// never read more than a full bucket at once, so waits stay short
+	if len(p) > r.limiter.limit.Burst {
+		p = p[:r.limiter.limit.Burst]
+	}
+
+	n, err := r.reader.Read(p)
+	if n == 0 {
+		return n, err
+	}
+
+	wait := r.limiter.reserve(r.key, n)
+	if wait <= 0 {
+		return n, err
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return n, err
+	case <-r.ctx.Done():
+		return n, r.ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits(" default:10/20, upload:0.5/2 ")
+	require.NoError(t, err)
+	require.Equal(t, map[string]Limit{
+		DefaultLimit: {Rate: 10, Burst: 20},
+		"upload":     {Rate: 0.5, Burst: 2},
+	}, limits)
+
+	limits, err = ParseLimits("")
+	require.NoError(t, err)
+	require.Empty(t, limits)
+
+	for _, invalid := range []string{"default", "default:10", ":1/1", "upload:0/1", "upload:1/0", "upload:a/1"} {
+		_, err := ParseLimits(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(Limit{Rate: 1, Burst: 2})
+	require.NoError(t, limiter.Allow("a"))
+	require.NoError(t, limiter.Allow("a"))
+
+	err := limiter.Allow("a")
+	require.IsType(t, RateLimitedError{}, err)
+	require.InDelta(t, time.Second, err.(RateLimitedError).RetryAfter, float64(50*time.Millisecond))
+	require.Equal(t, 1, err.(RateLimitedError).RetryAfterSeconds())
+
+	require.NoError(t, limiter.Allow("b"), "every key has its own bucket")
+
+	limiter.buckets["a"].last = time.Now().Add(-time.Second)
+	require.NoError(t, limiter.Allow("a"), "refilled")
+
+	t.Run("sweep", func(t *testing.T) {
+		limiter.buckets["b"].last = time.Now().Add(-time.Hour)
+		limiter.lastSweep = time.Now().Add(-time.Hour)
+		require.NoError(t, limiter.Allow("c"))
+		require.NotContains(t, limiter.buckets, "b")
+		require.Contains(t, limiter.buckets, "a")
+	})
+}
+
+func TestClientKey(t *testing.T) {
+	ctx := context.WithValue(context.Background(), requestKey{}, request{clientIP: "10.0.0.1"})
+	require.Equal(t, "ip:10.0.0.1", ClientKey(ctx))
+	require.Equal(t, "user:ci", ClientKey(auth.WithIdentity(ctx, auth.Identity{Name: "ci", Admin: true})))
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := RequestMiddleware(RateLimitMiddleware(NewLimiter(Limit{Rate: 0.1, Burst: 1}))(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	))
+
+	send := func(remoteAddr string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/graphql/", nil)
+		request.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusOK, send("10.0.0.1:1234").Code)
+
+	limited := send("10.0.0.1:4321")
+	require.Equal(t, http.StatusTooManyRequests, limited.Code)
+	require.Equal(t, "10", limited.Header().Get("Retry-After"))
+
+	var body struct {
+		Errors []struct {
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(limited.Body).Decode(&body))
+	require.Equal(t, "RATE_LIMITED", body.Errors[0].Extensions["code"])
+	require.Equal(t, float64(10), body.Errors[0].Extensions["retryAfter"])
+
+	require.Equal(t, http.StatusOK, send("10.0.0.2:1234").Code)
+}
+
+func TestThrottle(t *testing.T) {
+	limiter := NewLimiter(Limit{Rate: 1000, Burst: 100})
+	content := bytes.Repeat([]byte("a"), 300)
+
+	start := time.Now()
+	read, err := io.ReadAll(Throttle(context.Background(), limiter, "a", bytes.NewReader(content)))
+	require.NoError(t, err)
+	require.Equal(t, content, read)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond, "the burst is free, the rest takes 200ms")
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := io.ReadAll(Throttle(ctx, limiter, "a", bytes.NewReader(content)))
+		require.Equal(t, context.Canceled, err)
+	})
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+)
+
+const (
+	RequestIDHeader      = "X-Request-ID"
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// maxRequestIDLength keeps ids sent by clients from flooding logs
+const maxRequestIDLength = 128
+
+type requestKey struct{}
+
+type request struct {
+	id             string
+	clientIP       string
+	idempotencyKey string
+}
+
+// RequestMiddleware keeps the request id, client ip and idempotency key on the request context. The id
+// is taken from the X-Request-ID header when sent and is generated otherwise, it is always sent back.
+func RequestMiddleware(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		// the remote address is used as is, forwarded headers can be set by anyone
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestKey{}, request{id: id, clientIP: clientIP, idempotencyKey: r.Header.Get(IdempotencyKeyHeader)})
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// RequestID is the id of the request that ctx belongs to, empty outside of a request
+func RequestID(ctx context.Context) string {
+	value, _ := ctx.Value(requestKey{}).(request)
+	return value.id
+}
+
+// ClientIP is the address of the client that sent the request ctx belongs to, empty outside of a request
+func ClientIP(ctx context.Context) string {
+	value, _ := ctx.Value(requestKey{}).(request)
+	return value.clientIP
+}
+
+// WithClientIP keeps the address of the client on ctx for requests that are not served over http
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	value, _ := ctx.Value(requestKey{}).(request)
+	value.clientIP = ip
+	return context.WithValue(ctx, requestKey{}, value)
+}
+
+// IdempotencyKey is the Idempotency-Key header of the request ctx belongs to, empty when it was not sent
+func IdempotencyKey(ctx context.Context) string {
+	value, _ := ctx.Value(requestKey{}).(request)
+	return value.idempotencyKey
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const chunkSize = 32 * 1024
+
+var ErrSizeLimit = errors.New("file is larger than clamd accepts")
+
+// NewClamd scans files with a clamd daemon using the INSTREAM command. The
+// address is either host:port or the path to a unix socket prefixed by unix:
+func NewClamd(address string) Scanner {
+	network := "tcp"
+	if path := strings.TrimPrefix(address, "unix:"); path != address {
+		network, address = "unix", path
+	}
+
+	return clamd{
+		network: network,
+		address: strings.TrimPrefix(address, "tcp://"),
+	}
+}
+
+type clamd struct {
+	network string
+	address string
+}
+
+func (c clamd) Scan(ctx context.Context, file io.Reader) (Result, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("could not connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := stream(conn, file); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("could not read clamd reply: %w", err)
+	}
+
+	return parseReply(reply)
+}
+
+// stream sends file as length prefixed chunks, ending with an empty one
+func stream(conn net.Conn, file io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 4+chunkSize)
+	for {
+		n, err := file.Read(chunk[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(chunk, uint32(n))
+			if _, err := conn.Write(chunk[:4+n]); err != nil {
+				// clamd closes the connection once the stream is over its limit
+				return fmt.Errorf("could not send file to clamd: %w", err)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// parseReply reads answers such as "stream: OK" and "stream: Eicar-Signature FOUND"
+func parseReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	status := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	case strings.Contains(status, "size limit exceeded"):
+		return Result{}, ErrSizeLimit
+	default:
+		return Result{}, fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+}
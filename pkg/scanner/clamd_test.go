@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM commands like clamd, flagging files containing the eicar string
+func fakeClamd(t *testing.T, maxSize int) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				command, err := reader.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+						return
+					}
+
+					if size == 0 {
+						break
+					}
+
+					if content.Len()+int(size) > maxSize {
+						conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+						return
+					}
+
+					if _, err := io.CopyN(&content, reader, int64(size)); err != nil {
+						return
+					}
+				}
+
+				if strings.Contains(content.String(), eicar) {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+
+				conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestClamd_Scan(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	scanner := NewClamd("tcp://" + fakeClamd(t, 3*chunkSize))
+
+	t.Run("clean", func(t *testing.T) {
+		result, err := scanner.Scan(ctx, strings.NewReader(strings.Repeat("clean ", chunkSize/2)))
+		require.NoError(t, err)
+		require.False(t, result.Infected)
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		result, err := scanner.Scan(ctx, strings.NewReader("some text "+eicar))
+		require.NoError(t, err)
+		require.True(t, result.Infected)
+		require.Equal(t, "Eicar-Signature", result.Signature)
+	})
+
+	t.Run("size limit", func(t *testing.T) {
+		_, err := scanner.Scan(ctx, bytes.NewReader(make([]byte, 4*chunkSize)))
+		require.Error(t, err)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		address := listener.Addr().String()
+		listener.Close()
+
+		_, err = NewClamd(address).Scan(ctx, strings.NewReader("text"))
+		require.Error(t, err)
+	})
+}
+
+func TestParseReply(t *testing.T) {
+	result, err := parseReply("stream: OK\x00")
+	require.NoError(t, err)
+	require.False(t, result.Infected)
+
+	result, err = parseReply("stream: Win.Test.EICAR_HDB-1 FOUND\x00")
+	require.NoError(t, err)
+	require.Equal(t, Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, result)
+
+	_, err = parseReply("INSTREAM size limit exceeded. ERROR\x00")
+	require.Equal(t, ErrSizeLimit, err)
+
+	_, err = parseReply("stream: Can't allocate memory ERROR\x00")
+	require.Error(t, err)
+}
@@ -0,0 +1,17 @@
+//go:generate go run -mod=mod github.com/golang/mock/mockgen -package=mocks -source=$GOFILE -destination=../../test/mock/scanner.go
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+type Result struct {
+	Infected bool
+	// Signature is the name of what was found on infected files
+	Signature string
+}
+
+type Scanner interface {
+	Scan(ctx context.Context, file io.Reader) (Result, error)
+}
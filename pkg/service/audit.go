@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+)
+
+func (s s3service) AuditLog(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	reader, ok := s.audit.(audit.Reader)
+	if !ok {
+		return nil, ErrNoAuditLog
+	}
+
+	return reader.List(ctx, filter)
+}
+
+// record adds the outcome of an operation to the audit log, failing to do so does not fail the operation
+func (s s3service) record(ctx context.Context, entry audit.Entry, err error) {
+	if s.audit == nil {
+		return
+	}
+
+	entry.Time = time.Now()
+	entry.Actor = auth.FromContext(ctx).Name
+	entry.ClientIP = middleware.ClientIP(ctx)
+	entry.RequestID = middleware.RequestID(ctx)
+	entry.Result = audit.Success
+	if err != nil {
+		entry.Result = audit.Failure
+		entry.Error = err.Error()
+	}
+
+	// still recorded when the client gives up on the request
+	if err := s.audit.Write(context.WithoutCancel(ctx), &entry); err != nil {
+		logging.Error(ctx, "could not write to audit log", "action", entry.Action, "error", err)
+	}
+}
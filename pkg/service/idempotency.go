@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/idempotency"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+)
+
+// maxIdempotencyKeyLength keeps keys sent by clients from flooding the store
+const maxIdempotencyKeyLength = 255
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the upload or move made with ctx run only once for key, requests
+// retried with it get the file of the first one back
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// IdempotencyKey is the key requests made with ctx are run once for, empty when they are not
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// idempotent runs fn unless it already succeeded with the idempotency key of ctx, then the file
+// it returned is replayed. Errors are not kept, so failed requests can be retried with the same key.
+func (s s3service) idempotent(ctx context.Context, operation, fingerprint string, fn func() (*entity.File, error)) (*entity.File, error) {
+	key := IdempotencyKey(ctx)
+	if key == "" || s.idempotency == nil {
+		return fn()
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, ErrInvalidIdempotencyKey
+	}
+
+	// keys of different clients and operations never meet, anonymous clients are told apart by their ip
+	key = operation + ":" + middleware.ClientKey(ctx) + ":" + key
+	defer s.keyLocks.lock(key)()
+
+	record, err := s.idempotency.Get(ctx, key)
+	if err == nil {
+		if record.Fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+
+		var file entity.File
+		if err := json.Unmarshal(record.Result, &file); err != nil {
+			return nil, err
+		}
+
+		logging.Debug(ctx, "replayed idempotent request", "operation", operation, "key", file.ID)
+		return &file, nil
+	}
+
+	if !errors.Is(err, idempotency.ErrNotFound) {
+		return nil, err
+	}
+
+	file, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// the file was already changed, failing to keep the result only means retries run again
+	record = &idempotency.Record{Key: key, Fingerprint: fingerprint, Result: result, ExpiresAt: time.Now().Add(s.idempotencyTTL)}
+	if err := s.idempotency.Put(context.WithoutCancel(ctx), record); err != nil {
+		logging.Error(ctx, "could not keep idempotent result", "operation", operation, "error", err)
+	}
+
+	return file, nil
+}
+
+// keyLocks lets one request at a time run with each idempotency key, so a retry sent while the
+// first request is still running waits for its result instead of running again
+type keyLocks struct {
+	mu   sync.Mutex
+	held map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	// waiting is how many requests hold or wait for the lock, it is dropped when none do
+	waiting int
+}
+
+// lock waits until key is free and returns the function that frees it
+func (l *keyLocks) lock(key string) func() {
+	l.mu.Lock()
+	held, ok := l.held[key]
+	if !ok {
+		held = &keyLock{}
+		l.held[key] = held
+	}
+	held.waiting++
+	l.mu.Unlock()
+
+	held.Lock()
+	return func() {
+		held.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if held.waiting--; held.waiting == 0 {
+			delete(l.held, key)
+		}
+	}
+}
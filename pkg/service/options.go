@@ -0,0 +1,68 @@
+package service
+
+import (
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
+	"github.com/rafaelrubbioli/fileapi/pkg/contenttype"
+	"github.com/rafaelrubbioli/fileapi/pkg/idempotency"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/scanner"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
+)
+
+type Option func(*s3service)
+
+// WithIndex keeps idx up to date on every change and uses it to answer searches
+func WithIndex(idx index.Index) Option {
+	return func(s *s3service) {
+		s.index = idx
+	}
+}
+
+// WithContentTypeRules rejects uploads whose detected content type rules don't allow
+func WithContentTypeRules(rules contenttype.Rules) Option {
+	return func(s *s3service) {
+		s.contentTypes = rules
+	}
+}
+
+// WithScanner quarantines uploads until scanner finds them clean
+func WithScanner(scanner scanner.Scanner) Option {
+	return func(s *s3service) {
+		s.scanner = scanner
+	}
+}
+
+// WithThumbnails generates thumbnails of each size in pixels for uploaded images
+func WithThumbnails(sizes []int) Option {
+	return func(s *s3service) {
+		s.thumbnails = sizes
+	}
+}
+
+// WithWebhooks manages webhook subscriptions and their deliveries on store, every event is queued
+// for delivery on dispatcher
+func WithWebhooks(store webhook.Store, dispatcher *webhook.Dispatcher) Option {
+	return func(s *s3service) {
+		s.webhooks = store
+		s.dispatcher = dispatcher
+	}
+}
+
+// WithAudit records every upload, move and delete on sink
+func WithAudit(sink audit.Sink) Option {
+	return func(s *s3service) {
+		s.audit = sink
+	}
+}
+
+// WithIdempotency keeps the result of uploads and moves made with an idempotency key on store for ttl,
+// replaying it to the requests retried with the same key
+func WithIdempotency(store idempotency.Store, ttl time.Duration) Option {
+	return func(s *s3service) {
+		s.idempotency = store
+		s.idempotencyTTL = ttl
+		s.keyLocks = &keyLocks{held: map[string]*keyLock{}}
+	}
+}
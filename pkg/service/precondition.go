@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/smithy-go"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+)
+
+type ifMatchKey struct{}
+
+// WithIfMatch makes uploads, moves and deletes made with ctx fail with ErrPreconditionFailed
+// unless the file they change still has etag, so concurrent changes are not overwritten
+func WithIfMatch(ctx context.Context, etag string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, strings.Trim(etag, `"`))
+}
+
+// IfMatch is the etag the file changed with ctx must have, empty when it is not checked
+func IfMatch(ctx context.Context) string {
+	etag, _ := ctx.Value(ifMatchKey{}).(string)
+	return etag
+}
+
+// checkIfMatch fails when the file does not have the etag ctx requires, missing files never match
+func (s s3service) checkIfMatch(ctx context.Context, file *entity.File) error {
+	etag := IfMatch(ctx)
+	if etag == "" {
+		return nil
+	}
+
+	if file.IsEmpty() || file.ETag != etag {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// isPreconditionFailed tells if S3 refused a conditional request
+func isPreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed"
+}
+
+// etagOf is an ETag from S3 without its quotes
+func etagOf(etag *string) string {
+	if etag == nil {
+		return ""
+	}
+
+	return strings.Trim(*etag, `"`)
+}
@@ -1,42 +1,143 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
-	"log"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
 	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/contenttype"
 	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/rafaelrubbioli/fileapi/pkg/idempotency"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+	"github.com/rafaelrubbioli/fileapi/pkg/metrics"
+	"github.com/rafaelrubbioli/fileapi/pkg/scanner"
 	"github.com/rafaelrubbioli/fileapi/pkg/storage"
+	"github.com/rafaelrubbioli/fileapi/pkg/thumbnail"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
 )
 
 var (
-	ErrInvalidKey    = errors.New("invalid key")
-	ErrNotFound      = errors.New("not found")
-	ErrDuplicateFile = errors.New("file already exists on path")
+	ErrInvalidKey            = errors.New("invalid key")
+	ErrInvalidPath           = errors.New("path cannot contain '..'")
+	ErrNotFound              = errors.New("not found")
+	ErrDuplicateFile         = errors.New("file already exists on path")
+	ErrNoIndex               = errors.New("index not configured")
+	ErrContentTypeNotAllowed = errors.New("content type not allowed on path")
+	ErrNotScanned            = errors.New("file has not been scanned clean")
+	ErrReservedPath          = errors.New("path is reserved")
+	ErrNoWebhooks            = errors.New("webhooks not configured")
+	ErrInvalidWebhook        = errors.New("invalid webhook")
+	ErrNoAuditLog            = errors.New("audit log not configured or not searchable")
+	ErrPreconditionFailed    = errors.New("file has changed since it was read")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for another request")
+	ErrInvalidIdempotencyKey = errors.New("idempotency key is too long")
 )
 
-func NewS3Service(client storage.S3Client) Service {
-	return s3service{
+const scanTimeout = 5 * time.Minute
+
+// maxDeleteKeys is how many keys S3 deletes on one request
+const maxDeleteKeys = 1000
+
+func NewS3Service(client storage.S3Client, opts ...Option) Service {
+	s := s3service{
 		client: client,
+		events: event.NewBus(),
+		jobs:   &sync.WaitGroup{},
+	}
+
+	for _, opt := range opts {
+		opt(&s)
 	}
+
+	return s
 }
 
 type s3service struct {
-	client storage.S3Client
+	client       storage.S3Client
+	index        index.Index
+	contentTypes contenttype.Rules
+	scanner      scanner.Scanner
+	thumbnails   []int
+	webhooks     webhook.Store
+	dispatcher   *webhook.Dispatcher
+	audit        audit.Sink
+	events       *event.Bus
+	jobs         *sync.WaitGroup
+	// idempotency keeps the first result of requests made with an idempotency key, nil disables them
+	idempotency    idempotency.Store
+	idempotencyTTL time.Duration
+	keyLocks       *keyLocks
 }
 
+// Create uploads file, replaying the first upload made with the idempotency key of ctx instead when there is one
 func (s s3service) Create(ctx context.Context, user, size int, name, path, contentType string, file io.Reader, overwrite bool) (*entity.File, error) {
+	fingerprint := fmt.Sprint(user, size, path, name, overwrite)
+	return s.idempotent(ctx, "upload", fingerprint, func() (*entity.File, error) {
+		return s.create(ctx, user, size, name, path, contentType, file, overwrite)
+	})
+}
+
+func (s s3service) create(ctx context.Context, user, size int, name, path, contentType string, file io.Reader, overwrite bool) (_ *entity.File, err error) {
+	// names are joined to the key too, so they could point outside of path
+	if strings.Contains(path, "..") || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return nil, ErrInvalidPath
+	}
+
 	createdAt := time.Now()
 	id := filepath.Join(strconv.Itoa(user), path, name)
+	ctx = logging.With(ctx, "user", user, "key", id)
+	defer func() {
+		s.record(ctx, audit.Entry{Action: audit.Upload, User: user, NewKey: id}, err)
+	}()
+
+	metrics.UploadsInFlight.Inc()
+	defer metrics.UploadsInFlight.Dec()
+
+	if thumbnail.IsThumbnail(id) {
+		return nil, ErrReservedPath
+	}
+
+	body, err := seekable(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err = detectContentType(body, name, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.contentTypes.Allowed(path, contentType) {
+		return nil, ErrContentTypeNotAllowed
+	}
+
+	if IfMatch(ctx) != "" {
+		existing, err := s.Get(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+
+		if err := s.checkIfMatch(ctx, existing); err != nil {
+			return nil, err
+		}
+
+		// the file that matched is the one replaced
+		overwrite = true
+	}
 
 	if !overwrite {
 		file, err := s.Get(ctx, id)
@@ -52,22 +153,15 @@ func (s s3service) Create(ctx context.Context, user, size int, name, path, conte
 		}
 	}
 
-	input := &s3.PutObjectInput{
-		Bucket: aws.String(config.BucketName),
-		Key:    aws.String(id),
-		Body:   file,
-		Metadata: map[string]string{
-			"created_at": createdAt.Format(time.RFC3339),
-		},
-		ACL: types.ObjectCannedACLPublicRead,
-	}
-
-	_, err := s.client.PutObject(ctx, input)
-	if err != nil {
-		return nil, parseS3Error(err)
+	content := ""
+	if s.index != nil && index.IsText(contentType) {
+		content, err = readContent(body)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &entity.File{
+	result := &entity.File{
 		ID:          id,
 		Name:        name,
 		Path:        path,
@@ -75,36 +169,135 @@ func (s s3service) Create(ctx context.Context, user, size int, name, path, conte
 		ContentType: contentType,
 		Size:        size,
 		CreatedAt:   createdAt,
-		UpdatedAt:   time.Now(),
-	}, nil
+	}
+
+	// with a scanner files wait on a private key until they are scanned clean
+	key, acl := id, types.ObjectCannedACLPublicRead
+	if s.scanner != nil {
+		key, acl = quarantineKey(id), ""
+		result.ScanStatus = entity.ScanPending
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(config.BucketName),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+		Metadata:    metadata(result),
+		ACL:         acl,
+	}
+
+	output, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		return nil, parseS3Error(err)
+	}
+
+	result.UpdatedAt = time.Now()
+	if output != nil {
+		result.Checksum = checksum(output.ETag)
+		result.ETag = etagOf(output.ETag)
+	}
+
+	s.publish(ctx, event.Event{Type: event.Created, File: *result})
+
+	if s.scanner != nil {
+		pending := *result
+		s.background(ctx, func(ctx context.Context) {
+			s.scan(ctx, pending, content)
+		})
+
+		return result, nil
+	}
+
+	s.indexFile(ctx, result, content)
+	if s.hasThumbnails(result) {
+		created := *result
+		s.background(ctx, func(ctx context.Context) {
+			s.generateThumbnails(ctx, created)
+		})
+	}
+
+	return result, nil
 }
 
 func (s s3service) Get(ctx context.Context, id string) (*entity.File, error) {
-	user, path, name, err := parseKey(id)
+	file, err := s.get(ctx, id, id)
+	if errors.Is(err, ErrNotFound) && s.scanner != nil {
+		return s.get(ctx, quarantineKey(id), id)
+	}
+
+	return file, err
+}
+
+// Download returns the file along with its content, which must be closed. Files that were
+// not scanned clean can not be downloaded.
+func (s s3service) Download(ctx context.Context, id string) (*entity.File, io.ReadCloser, error) {
+	file, content, err := s.open(ctx, id, id)
+	if errors.Is(err, ErrNotFound) && s.scanner != nil {
+		if _, err := s.get(ctx, quarantineKey(id), id); err == nil {
+			return nil, nil, ErrNotScanned
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if file.ScanStatus.Quarantined() {
+		content.Close()
+		return nil, nil, ErrNotScanned
+	}
+
+	return file, content, nil
+}
+
+// get reads the file stored on key, which is not the file id while it is quarantined
+func (s s3service) get(ctx context.Context, key, id string) (*entity.File, error) {
+	file, content, err := s.open(ctx, key, id)
 	if err != nil {
 		return nil, err
 	}
 
+	content.Close()
+	return file, nil
+}
+
+// open reads the file stored on key along with its content, which must be closed
+func (s s3service) open(ctx context.Context, key, id string) (*entity.File, io.ReadCloser, error) {
+	user, path, name, err := parseKey(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(config.BucketName),
-		Key:    aws.String(id),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, parseS3Error(err)
+		return nil, nil, parseS3Error(err)
+	}
+
+	content := result.Body
+	if content == nil {
+		content = io.NopCloser(bytes.NewReader(nil))
 	}
 
 	createdAt, err := time.Parse(time.RFC3339, result.Metadata["created_at"])
 	if err != nil {
-		return nil, err
+		content.Close()
+		return nil, nil, err
 	}
 
 	file := &entity.File{
-		ID:        id,
-		Name:      name,
-		Path:      path,
-		User:      user,
-		CreatedAt: createdAt,
-		Size:      int(result.ContentLength),
+		ID:         id,
+		Name:       name,
+		Path:       path,
+		User:       user,
+		CreatedAt:  createdAt,
+		Size:       int(result.ContentLength),
+		ScanStatus: entity.ScanStatus(result.Metadata["scan_status"]),
+		Checksum:   checksum(result.ETag),
+		ETag:       etagOf(result.ETag),
 	}
 
 	if result.ContentType != nil {
@@ -115,7 +308,7 @@ func (s s3service) Get(ctx context.Context, id string) (*entity.File, error) {
 		file.UpdatedAt = *result.LastModified
 	}
 
-	return file, nil
+	return file, content, nil
 }
 
 func (s s3service) GetByUser(ctx context.Context, user int, prefix string) ([]*entity.File, error) {
@@ -124,60 +317,297 @@ func (s s3service) GetByUser(ctx context.Context, user int, prefix string) ([]*e
 		Prefix: aws.String(filepath.Join(strconv.Itoa(user), prefix)),
 	}
 
-	results, err := s.client.ListObjectsV2(ctx, input)
-	if err != nil {
-		return nil, parseS3Error(err)
-	}
+	var files []*entity.File
+	for {
+		results, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return nil, parseS3Error(err)
+		}
 
-	files := make([]*entity.File, 0, len(results.Contents))
-	for _, result := range results.Contents {
-		if result.Key != nil {
-			user, path, name, err := parseKey(*result.Key)
-			if err != nil {
-				return nil, err
-			}
+		for _, result := range results.Contents {
+			if result.Key != nil && !thumbnail.IsThumbnail(*result.Key) {
+				user, path, name, err := parseKey(*result.Key)
+				if err != nil {
+					return nil, err
+				}
 
-			// TODO list objects doesnt return all fields (may need to get() each one here)
-			file := &entity.File{
-				ID:   *result.Key,
-				Name: name,
-				Path: path,
-				User: user,
-				Size: int(result.Size),
-			}
+				// TODO list objects doesnt return all fields (may need to get() each one here)
+				file := &entity.File{
+					ID:       *result.Key,
+					Name:     name,
+					Path:     path,
+					User:     user,
+					Size:     int(result.Size),
+					Checksum: checksum(result.ETag),
+					ETag:     etagOf(result.ETag),
+				}
+
+				if result.LastModified != nil {
+					file.UpdatedAt = *result.LastModified
+				}
 
-			if result.LastModified != nil {
-				file.UpdatedAt = *result.LastModified
+				files = append(files, file)
 			}
+		}
 
-			files = append(files, file)
+		// listings stop at 1000 keys, the rest comes on the next pages
+		if !results.IsTruncated || results.NextContinuationToken == nil {
+			break
 		}
+
+		input.ContinuationToken = results.NextContinuationToken
+	}
+
+	if files == nil {
+		files = []*entity.File{}
 	}
 
 	return files, nil
 }
 
-func (s s3service) Delete(ctx context.Context, key string) error {
-	input := &s3.DeleteObjectsInput{
-		Delete: &types.Delete{
-			Objects: []types.ObjectIdentifier{{
-				Key: aws.String(key),
-			}},
-		},
+func (s s3service) Delete(ctx context.Context, key string) (err error) {
+	ctx = logging.With(ctx, "key", key)
+	deleted := entity.File{ID: key}
+	if user, path, name, err := parseKey(key); err == nil {
+		deleted.User, deleted.Path, deleted.Name = user, path, name
+	}
+
+	defer func() {
+		s.record(ctx, audit.Entry{Action: audit.Delete, User: deleted.User, OldKey: key}, err)
+	}()
+
+	if IfMatch(ctx) != "" {
+		file, err := s.Get(ctx, key)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		if err := s.checkIfMatch(ctx, file); err != nil {
+			return err
+		}
+	}
+
+	if err := s.remove(ctx, key); err != nil {
+		return err
+	}
+
+	s.publish(ctx, event.Event{Type: event.Deleted, File: deleted})
+	return nil
+}
+
+// DeleteMany deletes every key with as few requests as it can, answering the error of each key
+// in the same order, nil for the deleted ones
+func (s s3service) DeleteMany(ctx context.Context, keys []string) []error {
+	errs := make([]error, len(keys))
+	var (
+		batch   []int
+		objects []types.ObjectIdentifier
+	)
+	for i, key := range keys {
+		if _, _, _, err := parseKey(key); err != nil {
+			errs[i] = err
+			continue
+		}
+
+		derived := s.derivedKeys(key)
+		if len(objects)+len(derived) > maxDeleteKeys {
+			s.deleteBatch(ctx, keys, batch, objects, errs)
+			batch, objects = nil, nil
+		}
+
+		batch = append(batch, i)
+		for _, key := range derived {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+	}
+
+	if len(batch) > 0 {
+		s.deleteBatch(ctx, keys, batch, objects, errs)
+	}
+
+	return errs
+}
+
+// deleteBatch deletes the objects of the keys on the batch indexes in one request, setting their errors
+func (s s3service) deleteBatch(ctx context.Context, keys []string, batch []int, objects []types.ObjectIdentifier, errs []error) {
+	output, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 		Bucket: aws.String(config.BucketName),
+		Delete: &types.Delete{Objects: objects, Quiet: true},
+	})
+	err = parseS3Error(err)
+
+	// objects that could not be deleted are the only ones answered on quiet requests
+	failed := map[string]error{}
+	if output != nil {
+		for _, result := range output.Errors {
+			if result.Key != nil {
+				failed[*result.Key] = fmt.Errorf("could not delete %s: %s", *result.Key, aws.ToString(result.Message))
+			}
+		}
 	}
 
-	_, err := s.client.DeleteObjects(ctx, input)
-	return parseS3Error(err)
+	for _, i := range batch {
+		key := keys[i]
+		errs[i] = err
+		if errs[i] == nil {
+			errs[i] = failed[key]
+		}
+
+		user, path, name, _ := parseKey(key)
+		keyCtx := logging.With(ctx, "key", key)
+		s.record(keyCtx, audit.Entry{Action: audit.Delete, User: user, OldKey: key}, errs[i])
+		if errs[i] != nil {
+			continue
+		}
+
+		if s.index != nil {
+			if err := s.index.Delete(keyCtx, key); err != nil {
+				logging.Error(keyCtx, "could not remove file from index", "removed_key", key, "error", err)
+			}
+		}
+
+		s.publish(ctx, event.Event{Type: event.Deleted, File: entity.File{ID: key, User: user, Path: path, Name: name}})
+	}
+}
+
+// remove deletes key with everything derived from it
+func (s s3service) remove(ctx context.Context, key string) error {
+	if err := s.deleteObjects(ctx, s.derivedKeys(key)...); err != nil {
+		return err
+	}
+
+	if s.index != nil {
+		if err := s.index.Delete(ctx, key); err != nil {
+			logging.Error(ctx, "could not remove file from index", "removed_key", key, "error", err)
+		}
+	}
+
+	return nil
 }
 
+// derivedKeys are key along with the keys of everything derived from it
+func (s s3service) derivedKeys(key string) []string {
+	keys := []string{key}
+	if s.scanner != nil {
+		keys = append(keys, quarantineKey(key))
+	}
+
+	for _, size := range s.thumbnails {
+		keys = append(keys, thumbnail.Key(key, size))
+	}
+
+	return keys
+}
+
+// Move moves a file, replaying the first move made with the idempotency key of ctx instead when there is one
 func (s s3service) Move(ctx context.Context, user int, id, newPath string, overwrite bool) (*entity.File, error) {
-	old, err := s.Get(ctx, id)
+	fingerprint := fmt.Sprint(user, id, newPath, overwrite)
+	return s.idempotent(ctx, "move", fingerprint, func() (*entity.File, error) {
+		return s.move(ctx, user, id, newPath, overwrite)
+	})
+}
+
+func (s s3service) move(ctx context.Context, user int, id, newPath string, overwrite bool) (_ *entity.File, err error) {
+	if strings.Contains(newPath, "..") {
+		return nil, ErrInvalidPath
+	}
+
+	newKey := filepath.Join(strconv.Itoa(user), newPath)
+	ctx = logging.With(ctx, "user", user, "key", id, "new_key", newKey)
+	defer func() {
+		s.record(ctx, audit.Entry{Action: audit.Move, User: user, OldKey: id, NewKey: newKey}, err)
+	}()
+
+	result, err := s.copyFile(ctx, user, id, newKey, overwrite, false)
 	if err != nil {
 		return nil, err
 	}
 
+	if s.index != nil {
+		if err := s.index.Move(ctx, id, result); err != nil {
+			logging.Error(ctx, "could not move file on index", "error", err)
+		}
+	}
+
+	if s.hasThumbnails(result) {
+		s.copyThumbnails(ctx, id, newKey)
+	}
+
+	err = s.remove(ctx, id)
+	if err != nil {
+		logging.Error(ctx, "could not delete moved file", "error", err)
+	}
+
+	s.publish(ctx, event.Event{Type: event.Moved, File: *result, OldID: id})
+
+	return result, nil
+}
+
+// Copy stores a copy of the file on a new path, which is created as a new file
+func (s s3service) Copy(ctx context.Context, user int, id, newPath string, overwrite bool) (_ *entity.File, err error) {
+	if strings.Contains(newPath, "..") {
+		return nil, ErrInvalidPath
+	}
+
 	newKey := filepath.Join(strconv.Itoa(user), newPath)
+	ctx = logging.With(ctx, "user", user, "key", id, "new_key", newKey)
+	defer func() {
+		s.record(ctx, audit.Entry{Action: audit.Copy, User: user, OldKey: id, NewKey: newKey}, err)
+	}()
+
+	result, err := s.copyFile(ctx, user, id, newKey, overwrite, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.index != nil {
+		content, err := s.content(ctx, result)
+		if err != nil {
+			logging.Error(ctx, "could not read copied file content", "error", err)
+		}
+
+		s.indexFile(ctx, result, content)
+	}
+
+	if s.hasThumbnails(result) {
+		s.copyThumbnails(ctx, id, newKey)
+	}
+
+	s.publish(ctx, event.Event{Type: event.Created, File: *result})
+
+	return result, nil
+}
+
+// copyFile copies the object of a file to newKey, leaving everything derived from it to the caller.
+// A new file gets its own creation date, otherwise the one from the original file is kept
+func (s s3service) copyFile(ctx context.Context, user int, id, newKey string, overwrite, newFile bool) (*entity.File, error) {
+	if thumbnail.IsThumbnail(newKey) {
+		return nil, ErrReservedPath
+	}
+
+	old, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if old.ScanStatus.Quarantined() {
+		return nil, ErrNotScanned
+	}
+
+	_, newPath, _, err := parseKey(newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// the same rules as uploads, so files can't be moved where they could not be uploaded
+	if !s.contentTypes.Allowed(newPath, old.ContentType) {
+		return nil, ErrContentTypeNotAllowed
+	}
+
+	if err := s.checkIfMatch(ctx, old); err != nil {
+		return nil, err
+	}
+
 	if !overwrite {
 		file, err := s.Get(ctx, newKey)
 		if err != nil {
@@ -192,34 +622,446 @@ func (s s3service) Move(ctx context.Context, user int, id, newPath string, overw
 		}
 	}
 
+	_, path, name, _ := parseKey(newKey)
+	file := &entity.File{
+		ID:          newKey,
+		Name:        name,
+		Path:        path,
+		User:        user,
+		ContentType: old.ContentType,
+		Size:        old.Size,
+		CreatedAt:   old.CreatedAt,
+		UpdatedAt:   time.Now(),
+		ScanStatus:  old.ScanStatus,
+		Checksum:    old.Checksum,
+		ETag:        old.ETag,
+	}
+	if newFile {
+		file.CreatedAt = file.UpdatedAt
+	}
+
 	input := &s3.CopyObjectInput{
-		Bucket:     aws.String(config.BucketName),
-		CopySource: aws.String(filepath.Join(config.BucketName, id)),
-		Key:        aws.String(newKey),
-		ACL:        types.ObjectCannedACLPublicRead,
+		Bucket:            aws.String(config.BucketName),
+		CopySource:        aws.String(filepath.Join(config.BucketName, id)),
+		Key:               aws.String(newKey),
+		ACL:               types.ObjectCannedACLPublicRead,
+		ContentType:       aws.String(file.ContentType),
+		Metadata:          metadata(file),
+		MetadataDirective: types.MetadataDirectiveReplace,
 	}
 
-	_, err = s.client.CopyObject(ctx, input)
+	// checked again by S3 in case the file changed after it was read
+	if etag := IfMatch(ctx); etag != "" {
+		input.CopySourceIfMatch = aws.String(`"` + etag + `"`)
+	}
+
+	output, err := s.client.CopyObject(ctx, input)
 	if err != nil {
 		return nil, parseS3Error(err)
 	}
 
-	err = s.Delete(ctx, id)
+	if output != nil && output.CopyObjectResult != nil && output.CopyObjectResult.ETag != nil {
+		file.ETag = etagOf(output.CopyObjectResult.ETag)
+	}
+
+	return file, nil
+}
+
+func (s s3service) Subscribe(ctx context.Context) <-chan event.Event {
+	return s.events.Subscribe(ctx)
+}
+
+func (s s3service) Search(ctx context.Context, filter index.Filter, sort index.Sort, page index.Page) ([]*entity.File, int, error) {
+	if s.index == nil {
+		return nil, 0, ErrNoIndex
+	}
+
+	return s.index.Search(ctx, filter, sort, page)
+}
+
+func (s s3service) SearchContent(ctx context.Context, query string, user *int) ([]index.Match, error) {
+	if s.index == nil {
+		return nil, ErrNoIndex
+	}
+
+	return s.index.SearchContent(ctx, query, user, index.DefaultPageSize)
+}
+
+// Reindex rebuilds the index from scratch with every file in the bucket
+func (s s3service) Reindex(ctx context.Context) (int, error) {
+	if s.index == nil {
+		return 0, ErrNoIndex
+	}
+
+	if err := s.index.Reset(ctx); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(config.BucketName),
+	}
+
+	for {
+		results, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return count, parseS3Error(err)
+		}
+
+		for _, result := range results.Contents {
+			if result.Key == nil || thumbnail.IsThumbnail(*result.Key) {
+				continue
+			}
+
+			file, err := s.head(ctx, *result.Key)
+			if err != nil {
+				if errors.Is(err, ErrInvalidKey) || errors.Is(err, ErrNotFound) {
+					continue
+				}
+
+				return count, err
+			}
+
+			content, err := s.content(ctx, file)
+			if err != nil {
+				return count, err
+			}
+
+			if err := s.index.Put(ctx, file); err != nil {
+				return count, err
+			}
+
+			if err := s.index.PutContent(ctx, file.ID, content); err != nil {
+				return count, err
+			}
+
+			count++
+		}
+
+		if !results.IsTruncated {
+			return count, nil
+		}
+
+		input.ContinuationToken = results.NextContinuationToken
+	}
+}
+
+// Ping checks that the bucket can be reached by listing at most one key
+func (s s3service) Ping(ctx context.Context) error {
+	_, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(config.BucketName),
+		MaxKeys: 1,
+	})
+	return err
+}
+
+// Close waits for the background jobs, such as scans and thumbnails, to finish
+// or for the context to be done
+func (s s3service) Close(ctx context.Context) error {
+	if s.jobs == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// publish sends e to the subscribers and adds its webhook deliveries to the outbox. The deliveries
+// are added here instead of by a subscriber, which could miss events when it falls behind.
+func (s s3service) publish(ctx context.Context, e event.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.events.Publish(e)
+	if s.dispatcher == nil {
+		return
+	}
+
+	if err := s.dispatcher.Enqueue(ctx, e); err != nil {
+		logging.Error(ctx, "could not enqueue webhooks", "type", e.Type, "error", err)
+	}
+}
+
+// head fetches file metadata without downloading its content
+func (s s3service) head(ctx context.Context, id string) (*entity.File, error) {
+	user, path, name, err := parseKey(id)
 	if err != nil {
-		log.Printf("could not delete file %s: %t", id, err)
+		return nil, err
 	}
 
-	_, path, name, _ := parseKey(id)
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(config.BucketName),
+		Key:    aws.String(id),
+	})
+	if err != nil {
+		return nil, parseS3Error(err)
+	}
 
-	return &entity.File{
-		ID:          newKey,
-		Name:        name,
-		Path:        path,
-		User:        user,
-		ContentType: old.ContentType,
-		CreatedAt:   old.CreatedAt,
-		UpdatedAt:   time.Now(),
-	}, nil
+	file := &entity.File{
+		ID:         id,
+		Name:       name,
+		Path:       path,
+		User:       user,
+		Size:       int(result.ContentLength),
+		ScanStatus: entity.ScanStatus(result.Metadata["scan_status"]),
+		Checksum:   checksum(result.ETag),
+		ETag:       etagOf(result.ETag),
+	}
+
+	if result.ContentType != nil {
+		file.ContentType = *result.ContentType
+	}
+
+	if result.LastModified != nil {
+		file.CreatedAt = *result.LastModified
+		file.UpdatedAt = *result.LastModified
+	}
+
+	if createdAt, err := time.Parse(time.RFC3339, result.Metadata["created_at"]); err == nil {
+		file.CreatedAt = createdAt
+	}
+
+	return file, nil
+}
+
+// scan checks a quarantined file, moving it into place once it is clean
+func (s s3service) scan(ctx context.Context, file entity.File, content string) {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	key := quarantineKey(file.ID)
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		logging.Error(ctx, "could not read file to scan", "quarantine_key", key, "error", err)
+		s.scanFailed(ctx, file)
+		return
+	}
+	defer object.Body.Close()
+
+	result, err := s.scanner.Scan(ctx, object.Body)
+	if err != nil {
+		logging.Error(ctx, "could not scan file", "quarantine_key", key, "error", err)
+		s.scanFailed(ctx, file)
+		return
+	}
+
+	if result.Infected {
+		logging.Warn(ctx, "file is infected", "signature", result.Signature)
+		file.ScanStatus = entity.ScanInfected
+		if err := s.copyObject(ctx, key, key, &file, ""); err != nil {
+			logging.Error(ctx, "could not flag file as infected", "quarantine_key", key, "error", err)
+			return
+		}
+
+		s.publish(ctx, event.Event{Type: event.Updated, File: file})
+		return
+	}
+
+	file.ScanStatus = entity.ScanClean
+	if err := s.copyObject(ctx, key, file.ID, &file, types.ObjectCannedACLPublicRead); err != nil {
+		logging.Error(ctx, "could not release file from quarantine", "error", err)
+		return
+	}
+
+	if err := s.deleteObjects(ctx, key); err != nil {
+		logging.Error(ctx, "could not delete quarantined file", "quarantine_key", key, "error", err)
+	}
+
+	s.publish(ctx, event.Event{Type: event.Updated, File: file})
+	s.indexFile(ctx, &file, content)
+	if s.hasThumbnails(&file) {
+		s.generateThumbnails(ctx, file)
+	}
+}
+
+// scanFailed flags a file that could not be scanned, so it is not left pending forever
+func (s s3service) scanFailed(ctx context.Context, file entity.File) {
+	// the scan may have failed because ctx timed out
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	key := quarantineKey(file.ID)
+	file.ScanStatus = entity.ScanFailed
+	if err := s.copyObject(ctx, key, key, &file, ""); err != nil {
+		logging.Error(ctx, "could not flag file as failed to scan", "quarantine_key", key, "error", err)
+		return
+	}
+
+	s.publish(ctx, event.Event{Type: event.Updated, File: file})
+}
+
+func (s s3service) hasThumbnails(file *entity.File) bool {
+	return len(s.thumbnails) > 0 && thumbnail.Supported(file.ContentType)
+}
+
+// generateThumbnails stores every configured thumbnail size of an image next to it
+func (s s3service) generateThumbnails(ctx context.Context, file entity.File) {
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.BucketName),
+		Key:    aws.String(file.ID),
+	})
+	if err != nil {
+		logging.Error(ctx, "could not read file to generate thumbnails", "error", err)
+		return
+	}
+	defer object.Body.Close()
+
+	thumbnails, err := thumbnail.Generate(object.Body, file.ContentType, s.thumbnails)
+	if err != nil {
+		logging.Error(ctx, "could not generate thumbnails", "error", err)
+		return
+	}
+
+	stored := 0
+	for _, thumb := range thumbnails {
+		_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(config.BucketName),
+			Key:         aws.String(thumbnail.Key(file.ID, thumb.Size)),
+			Body:        bytes.NewReader(thumb.Content),
+			ContentType: aws.String(thumb.ContentType),
+			ACL:         types.ObjectCannedACLPublicRead,
+		})
+		if err != nil {
+			logging.Error(ctx, "could not store thumbnail", "size", thumb.Size, "error", err)
+			continue
+		}
+
+		stored++
+	}
+
+	if stored > 0 {
+		s.publish(ctx, event.Event{Type: event.Updated, File: file})
+	}
+}
+
+// copyThumbnails copies the thumbnails of a moved or copied file, the ones of moved files are removed along with them
+func (s s3service) copyThumbnails(ctx context.Context, oldKey, newKey string) {
+	for _, size := range s.thumbnails {
+		_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(config.BucketName),
+			CopySource: aws.String(filepath.Join(config.BucketName, thumbnail.Key(oldKey, size))),
+			Key:        aws.String(thumbnail.Key(newKey, size)),
+			ACL:        types.ObjectCannedACLPublicRead,
+		})
+		if err = parseS3Error(err); err != nil && !errors.Is(err, ErrNotFound) {
+			logging.Error(ctx, "could not copy thumbnail", "size", size, "error", err)
+		}
+	}
+}
+
+// copyObject copies from one key to another replacing the metadata with the one from file
+func (s s3service) copyObject(ctx context.Context, from, to string, file *entity.File, acl types.ObjectCannedACL) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(config.BucketName),
+		CopySource:        aws.String(filepath.Join(config.BucketName, from)),
+		Key:               aws.String(to),
+		ACL:               acl,
+		ContentType:       aws.String(file.ContentType),
+		Metadata:          metadata(file),
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	return parseS3Error(err)
+}
+
+func (s s3service) deleteObjects(ctx context.Context, keys ...string) error {
+	objects := make([]types.ObjectIdentifier, 0, len(keys))
+	for _, key := range keys {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+	}
+
+	input := &s3.DeleteObjectsInput{
+		Delete: &types.Delete{
+			Objects: objects,
+		},
+		Bucket: aws.String(config.BucketName),
+	}
+
+	_, err := s.client.DeleteObjects(ctx, input)
+	return parseS3Error(err)
+}
+
+// background runs fn after the request is over, so it gets a context that is not
+// canceled with ctx but still carries its values, like the logger
+func (s s3service) background(ctx context.Context, fn func(ctx context.Context)) {
+	ctx = context.WithoutCancel(ctx)
+	if s.jobs == nil {
+		go fn(ctx)
+		return
+	}
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		fn(ctx)
+	}()
+}
+
+// content downloads the indexable part of a text file, other files have no content
+func (s s3service) content(ctx context.Context, file *entity.File) (string, error) {
+	if !index.IsText(file.ContentType) {
+		return "", nil
+	}
+
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.BucketName),
+		Key:    aws.String(file.ID),
+	})
+	if err != nil {
+		return "", parseS3Error(err)
+	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(result.Body, index.MaxContentLength))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToValidUTF8(string(content), ""), nil
+}
+
+func (s s3service) indexFile(ctx context.Context, file *entity.File, content string) {
+	if s.index == nil {
+		return
+	}
+
+	if err := s.index.Put(ctx, file); err != nil {
+		logging.Error(ctx, "could not index file", "error", err)
+		return
+	}
+
+	if err := s.index.PutContent(ctx, file.ID, content); err != nil {
+		logging.Error(ctx, "could not index file content", "error", err)
+	}
+}
+
+func metadata(file *entity.File) map[string]string {
+	result := map[string]string{
+		"created_at": file.CreatedAt.Format(time.RFC3339),
+	}
+
+	if file.ScanStatus != "" {
+		result["scan_status"] = string(file.ScanStatus)
+	}
+
+	return result
+}
+
+func quarantineKey(id string) string {
+	return filepath.Join(config.QuarantinePrefix(), id)
 }
 
 func parseKey(key string) (int, string, string, error) {
@@ -236,7 +1078,69 @@ func parseKey(key string) (int, string, string, error) {
 	return user, filepath.Join(parts[1 : len(parts)-1]...), parts[len(parts)-1], nil
 }
 
+// checksum is the md5 S3 uses as the ETag of objects uploaded in a single part, the ETag of
+// multipart uploads is not a checksum of the content
+func checksum(etag *string) string {
+	if etag == nil {
+		return ""
+	}
+
+	value := strings.Trim(*etag, `"`)
+	if strings.Contains(value, "-") {
+		return ""
+	}
+
+	return value
+}
+
+// seekable makes sure file can be read more than once, the s3 client hashes the body before sending it
+func seekable(file io.Reader) (io.ReadSeeker, error) {
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		return seeker, nil
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(content), nil
+}
+
+// detectContentType sniffs the type of file from its first bytes and rewinds it
+func detectContentType(file io.ReadSeeker, name, declared string) (string, error) {
+	head := make([]byte, contenttype.SniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return contenttype.Detect(head[:n], name, declared), nil
+}
+
+// readContent reads the indexable start of file and rewinds it
+func readContent(file io.ReadSeeker) (string, error) {
+	content, err := io.ReadAll(io.LimitReader(file, index.MaxContentLength))
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return strings.ToValidUTF8(string(content), ""), nil
+}
+
 func parseS3Error(err error) error {
+	if isPreconditionFailed(err) {
+		return ErrPreconditionFailed
+	}
+
 	var errNoSuchKey *types.NoSuchKey
 	if errors.As(err, &errNoSuchKey) {
 		return ErrNotFound
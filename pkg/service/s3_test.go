@@ -4,13 +4,33 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
+	"github.com/rafaelrubbioli/fileapi/pkg/auth"
 	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/contenttype"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/rafaelrubbioli/fileapi/pkg/idempotency"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/middleware"
+	"github.com/rafaelrubbioli/fileapi/pkg/scanner"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
 	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -29,8 +49,15 @@ func TestS3service_Create(t *testing.T) {
 	service := s3service{client: s3Mock}
 	content := bytes.NewReader([]byte("bla bla"))
 
+	t.Run("name outside of the path", func(t *testing.T) {
+		for _, name := range []string{"../../2/docs/x", "../../quarantine/1/a", "docs/a.txt", `docs\a.txt`} {
+			_, err := service.Create(ctx, 1, 7, name, "path", "text/plain", content, true)
+			require.Equal(t, ErrInvalidPath, err, name)
+		}
+	})
+
 	t.Run("success", func(t *testing.T) {
-		s3Mock.EXPECT().PutObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "1/path/test.txt", *input.Key)
@@ -46,7 +73,7 @@ func TestS3service_Create(t *testing.T) {
 	})
 
 	t.Run("file exists on path", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": time.Now().Format(time.RFC3339)},
 				ContentLength: 15,
@@ -58,7 +85,7 @@ func TestS3service_Create(t *testing.T) {
 	})
 
 	t.Run("get duplicate error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Create(ctx, 1, 12, "test.txt", "path/", "text/plain", content, false)
@@ -67,10 +94,10 @@ func TestS3service_Create(t *testing.T) {
 	})
 
 	t.Run("file not found on path", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, &types.NotFound{})
 
-		s3Mock.EXPECT().PutObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "1/path/test.txt", *input.Key)
@@ -85,8 +112,47 @@ func TestS3service_Create(t *testing.T) {
 		require.Equal(t, "path/", result.Path)
 	})
 
+	t.Run("stores detected content type", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				require.Equal(t, "image/png", *input.ContentType)
+				body, err := io.ReadAll(input.Body)
+				require.NoError(t, err)
+				require.Len(t, body, 10)
+				return nil, nil
+			})
+
+		png := bytes.NewReader([]byte("\x89PNG\r\n\x1a\n\x00\x00"))
+		result, err := service.Create(ctx, 1, 10, "test.txt", "path/", "text/plain", png, true)
+		require.NoError(t, err)
+		require.Equal(t, "image/png", result.ContentType)
+	})
+
+	t.Run("content type not allowed", func(t *testing.T) {
+		rules, err := contenttype.ParseRules("docs=allow:application/pdf")
+		require.NoError(t, err)
+
+		restricted := s3service{client: s3Mock, contentTypes: rules}
+		result, err := restricted.Create(ctx, 1, 12, "test.pdf", "docs/2021", "application/pdf", strings.NewReader("not a pdf"), true)
+		require.Equal(t, ErrContentTypeNotAllowed, err)
+		require.Nil(t, result)
+
+		plain := &s3.GetObjectOutput{
+			Metadata:      map[string]string{"created_at": time.Now().Format(time.RFC3339)},
+			ContentType:   aws.String("text/plain"),
+			ContentLength: 9,
+		}
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(plain, nil).Times(2)
+
+		_, err = restricted.Move(ctx, 1, "1/path/test.txt", "docs/2021/test.txt", true)
+		require.Equal(t, ErrContentTypeNotAllowed, err, "files can't be moved where they could not be uploaded")
+
+		_, err = restricted.Copy(ctx, 1, "1/path/test.txt", "docs/test.txt", true)
+		require.Equal(t, ErrContentTypeNotAllowed, err, "or copied")
+	})
+
 	t.Run("s3 error on create object", func(t *testing.T) {
-		s3Mock.EXPECT().PutObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Create(ctx, 1, 12, "test.txt", "path/", "text/plain", content, true)
@@ -107,7 +173,7 @@ func TestS3service_Get(t *testing.T) {
 	contentType := "text/plain"
 
 	t.Run("success", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "1/path/test.txt", *input.Key)
@@ -132,7 +198,7 @@ func TestS3service_Get(t *testing.T) {
 	})
 
 	t.Run("s3 error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Get(ctx, "1/path/test.txt")
@@ -141,7 +207,7 @@ func TestS3service_Get(t *testing.T) {
 	})
 
 	t.Run("s3 no such key error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, &types.NoSuchKey{})
 
 		result, err := service.Get(ctx, "1/path/test.txt")
@@ -150,7 +216,7 @@ func TestS3service_Get(t *testing.T) {
 	})
 
 	t.Run("s3 no not found error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, &types.NoSuchKey{})
 
 		result, err := service.Get(ctx, "1/path/test.txt")
@@ -159,7 +225,7 @@ func TestS3service_Get(t *testing.T) {
 	})
 
 	t.Run("invalid creation date", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{Metadata: map[string]string{"created_at": "invalid"}}, nil)
 
 		result, err := service.Get(ctx, "1/path/test.txt")
@@ -183,7 +249,7 @@ func TestS3service_Delete(t *testing.T) {
 	service := s3service{client: s3Mock}
 
 	t.Run("success", func(t *testing.T) {
-		s3Mock.EXPECT().DeleteObjects(ctx, gomock.Any()).
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "1/path/test.txt", *input.Delete.Objects[0].Key)
@@ -195,7 +261,7 @@ func TestS3service_Delete(t *testing.T) {
 	})
 
 	t.Run("s3 error", func(t *testing.T) {
-		s3Mock.EXPECT().DeleteObjects(ctx, gomock.Any()).
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		err := service.Delete(ctx, "1/path/test.txt")
@@ -203,6 +269,115 @@ func TestS3service_Delete(t *testing.T) {
 	})
 }
 
+func TestS3service_DeleteMany(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := s3service{client: s3Mock, thumbnails: []int{128}}
+
+	t.Run("grouped by request", func(t *testing.T) {
+		keys := make([]string, 501)
+		for i := range keys {
+			keys[i] = "1/path/" + strconv.Itoa(i) + ".png"
+		}
+
+		var sizes []int
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Times(2).
+			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+				require.True(t, input.Delete.Quiet)
+				sizes = append(sizes, len(input.Delete.Objects))
+				return &s3.DeleteObjectsOutput{}, nil
+			})
+
+		errs := service.DeleteMany(ctx, keys)
+		require.Len(t, errs, 501)
+		for _, err := range errs {
+			require.NoError(t, err)
+		}
+		require.Equal(t, []int{1000, 2}, sizes, "the thumbnail of a file is deleted along with it")
+	})
+
+	t.Run("errors of each key", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
+			Return(&s3.DeleteObjectsOutput{Errors: []types.Error{{Key: aws.String("1/b.txt"), Message: aws.String("Access Denied")}}}, nil)
+
+		errs := service.DeleteMany(ctx, []string{"1/a.txt", "invalid", "1/b.txt"})
+		require.NoError(t, errs[0])
+		require.Equal(t, ErrInvalidKey, errs[1])
+		require.EqualError(t, errs[2], "could not delete 1/b.txt: Access Denied")
+	})
+
+	t.Run("s3 error", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, errors.New("unavailable"))
+
+		errs := service.DeleteMany(ctx, []string{"1/a.txt", "1/b.txt"})
+		require.EqualError(t, errs[0], "unavailable")
+		require.EqualError(t, errs[1], "unavailable")
+	})
+}
+
+func TestS3service_Download(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := s3service{client: s3Mock, scanner: mocks.NewMockScanner(ctrl)}
+	metadata := map[string]string{"created_at": time.Now().Format(time.RFC3339)}
+
+	t.Run("success", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				require.Equal(t, "1/path/test.txt", *input.Key)
+				return &s3.GetObjectOutput{
+					Body:          io.NopCloser(strings.NewReader("content")),
+					Metadata:      map[string]string{"created_at": metadata["created_at"], "scan_status": string(entity.ScanClean)},
+					ContentLength: 7,
+				}, nil
+			})
+
+		file, content, err := service.Download(ctx, "1/path/test.txt")
+		require.NoError(t, err)
+		defer content.Close()
+
+		require.Equal(t, 7, file.Size)
+		read, err := io.ReadAll(content)
+		require.NoError(t, err)
+		require.Equal(t, "content", string(read))
+	})
+
+	t.Run("quarantined", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{})
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				require.Equal(t, quarantineKey("1/path/test.txt"), *input.Key)
+				return &s3.GetObjectOutput{Metadata: metadata}, nil
+			})
+
+		_, _, err := service.Download(ctx, "1/path/test.txt")
+		require.Equal(t, ErrNotScanned, err)
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(&s3.GetObjectOutput{
+			Body:     io.NopCloser(strings.NewReader("virus")),
+			Metadata: map[string]string{"created_at": metadata["created_at"], "scan_status": string(entity.ScanInfected)},
+		}, nil)
+
+		_, _, err := service.Download(ctx, "1/path/test.txt")
+		require.Equal(t, ErrNotScanned, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{}).Times(2)
+
+		_, _, err := service.Download(ctx, "1/path/test.txt")
+		require.Equal(t, ErrNotFound, err)
+	})
+}
+
 func TestS3service_GetByUser(t *testing.T) {
 	ctx := context.Background()
 	ctrl := gomock.NewController(t)
@@ -233,6 +408,39 @@ func TestS3service_GetByUser(t *testing.T) {
 		require.Equal(t, key2, result[1].ID)
 	})
 
+	t.Run("every page with checksums", func(t *testing.T) {
+		key1 := "1/path/test.txt"
+		key2 := "1/path/test2.txt"
+		token := "next"
+		etag1 := `"5d41402abc4b2a76b9719d911017c592"`
+		etag2 := `"9b2cf535f27731c974343645a3985328-2"`
+
+		gomock.InOrder(
+			s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+					require.Nil(t, input.ContinuationToken)
+					return &s3.ListObjectsV2Output{
+						Contents:              []types.Object{{Key: &key1, ETag: &etag1}},
+						IsTruncated:           true,
+						NextContinuationToken: &token,
+					}, nil
+				}),
+			s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
+				DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+					require.Equal(t, token, *input.ContinuationToken)
+					return &s3.ListObjectsV2Output{
+						Contents: []types.Object{{Key: &key2, ETag: &etag2}},
+					}, nil
+				}),
+		)
+
+		result, err := service.GetByUser(ctx, 1, "path")
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		require.Equal(t, "5d41402abc4b2a76b9719d911017c592", result[0].Checksum)
+		require.Empty(t, result[1].Checksum, "multipart etags are not checksums")
+	})
+
 	t.Run("bucket returns invalid key", func(t *testing.T) {
 		key := "invalid"
 		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
@@ -270,8 +478,13 @@ func TestS3service_Move(t *testing.T) {
 	createdAt := time.Now()
 	contentType := "text/plain"
 
+	t.Run("path outside of the user", func(t *testing.T) {
+		_, err := service.Move(ctx, 1, "1/path/test.txt", "../2/test.txt", true)
+		require.Equal(t, ErrInvalidPath, err)
+	})
+
 	t.Run("success with overwrite", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
 				ContentLength: 15,
@@ -279,16 +492,18 @@ func TestS3service_Move(t *testing.T) {
 				LastModified:  &createdAt,
 			}, nil)
 
-		s3Mock.EXPECT().CopyObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, input *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "fileapi/1/path/test.txt", *input.CopySource)
 				require.Equal(t, "1/newpath/test.txt", *input.Key)
 				require.Equal(t, types.ObjectCannedACLPublicRead, input.ACL)
+				require.Equal(t, contentType, *input.ContentType)
+				require.Equal(t, createdAt.Format(time.RFC3339), input.Metadata["created_at"])
 				return nil, nil
 			})
 
-		s3Mock.EXPECT().DeleteObjects(ctx, gomock.Any()).
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
 			Return(nil, nil)
 
 		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", true)
@@ -297,7 +512,7 @@ func TestS3service_Move(t *testing.T) {
 	})
 
 	t.Run("file already exists on destination path", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
 				ContentLength: 15,
@@ -305,7 +520,7 @@ func TestS3service_Move(t *testing.T) {
 				LastModified:  &createdAt,
 			}, nil)
 
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": time.Now().Format(time.RFC3339)},
 				ContentLength: 12,
@@ -317,7 +532,7 @@ func TestS3service_Move(t *testing.T) {
 	})
 
 	t.Run("get duplicate file error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
 				ContentLength: 15,
@@ -325,7 +540,7 @@ func TestS3service_Move(t *testing.T) {
 				LastModified:  &createdAt,
 			}, nil)
 
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", false)
@@ -334,7 +549,7 @@ func TestS3service_Move(t *testing.T) {
 	})
 
 	t.Run("duplicate not found error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
 				ContentLength: 15,
@@ -342,10 +557,10 @@ func TestS3service_Move(t *testing.T) {
 				LastModified:  &createdAt,
 			}, nil)
 
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, &types.NotFound{})
 
-		s3Mock.EXPECT().CopyObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, input *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "fileapi/1/path/test.txt", *input.CopySource)
@@ -354,7 +569,7 @@ func TestS3service_Move(t *testing.T) {
 				return nil, nil
 			})
 
-		s3Mock.EXPECT().DeleteObjects(ctx, gomock.Any()).
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
 			Return(nil, nil)
 
 		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", false)
@@ -363,7 +578,7 @@ func TestS3service_Move(t *testing.T) {
 	})
 
 	t.Run("get error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", true)
@@ -372,7 +587,7 @@ func TestS3service_Move(t *testing.T) {
 	})
 
 	t.Run("copy error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
 				ContentLength: 15,
@@ -380,7 +595,7 @@ func TestS3service_Move(t *testing.T) {
 				LastModified:  &createdAt,
 			}, nil)
 
-		s3Mock.EXPECT().CopyObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", true)
@@ -389,7 +604,7 @@ func TestS3service_Move(t *testing.T) {
 	})
 
 	t.Run("delete error", func(t *testing.T) {
-		s3Mock.EXPECT().GetObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
 			Return(&s3.GetObjectOutput{
 				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
 				ContentLength: 15,
@@ -397,7 +612,7 @@ func TestS3service_Move(t *testing.T) {
 				LastModified:  &createdAt,
 			}, nil)
 
-		s3Mock.EXPECT().CopyObject(ctx, gomock.Any()).
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
 			DoAndReturn(func(ctx context.Context, input *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
 				require.Equal(t, config.BucketName, *input.Bucket)
 				require.Equal(t, "fileapi/1/path/test.txt", *input.CopySource)
@@ -406,7 +621,7 @@ func TestS3service_Move(t *testing.T) {
 				return nil, nil
 			})
 
-		s3Mock.EXPECT().DeleteObjects(ctx, gomock.Any()).
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
 			Return(nil, errors.New(""))
 
 		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", true)
@@ -415,6 +630,819 @@ func TestS3service_Move(t *testing.T) {
 	})
 }
 
+func TestS3service_Copy(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	auditMock := mocks.NewMockSink(ctrl)
+	service := s3service{client: s3Mock, audit: auditMock, events: event.NewBus()}
+	events := service.Subscribe(ctx)
+	createdAt := time.Now().Add(-time.Hour)
+
+	t.Run("path outside of the user", func(t *testing.T) {
+		_, err := service.Copy(ctx, 2, "1/path/test.txt", "../1/test.txt", false)
+		require.Equal(t, ErrInvalidPath, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Metadata: map[string]string{"created_at": createdAt.Format(time.RFC3339)}, ContentLength: 15}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				require.Equal(t, "fileapi/1/path/test.txt", *input.CopySource)
+				require.Equal(t, "2/copy/test.txt", *input.Key)
+				require.Equal(t, types.MetadataDirectiveReplace, input.MetadataDirective)
+				require.NotEqual(t, createdAt.Format(time.RFC3339), input.Metadata["created_at"], "copies are new files")
+				return nil, nil
+			})
+		auditMock.EXPECT().Write(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry *audit.Entry) error {
+				require.Equal(t, audit.Copy, entry.Action)
+				require.Equal(t, "1/path/test.txt", entry.OldKey)
+				require.Equal(t, "2/copy/test.txt", entry.NewKey)
+				return nil
+			})
+
+		result, err := service.Copy(ctx, 2, "1/path/test.txt", "copy/test.txt", true)
+		require.NoError(t, err)
+		require.Equal(t, "2/copy/test.txt", result.ID)
+		require.Equal(t, 15, result.Size)
+		require.True(t, result.CreatedAt.After(createdAt), "copies are new files")
+
+		e := <-events
+		require.Equal(t, event.Created, e.Type)
+		require.Equal(t, "2/copy/test.txt", e.File.ID)
+	})
+
+	t.Run("file already exists on destination path", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Metadata: map[string]string{"created_at": createdAt.Format(time.RFC3339)}}, nil).Times(2)
+		auditMock.EXPECT().Write(gomock.Any(), gomock.Any()).Return(nil)
+
+		result, err := service.Copy(ctx, 1, "1/path/test.txt", "copy/test.txt", false)
+		require.Equal(t, ErrDuplicateFile, err)
+		require.Nil(t, result)
+		require.Empty(t, events)
+	})
+}
+
+func TestS3service_IfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := s3service{client: s3Mock}
+	ctx := WithIfMatch(context.Background(), `"abc"`)
+	stored := func(etag string) *s3.GetObjectOutput {
+		return &s3.GetObjectOutput{
+			Metadata: map[string]string{"created_at": time.Now().Format(time.RFC3339)},
+			ETag:     aws.String(`"` + etag + `"`),
+		}
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(stored("abc"), nil)
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(&s3.PutObjectOutput{ETag: aws.String(`"def"`)}, nil)
+
+		result, err := service.Create(ctx, 1, 5, "test.txt", "path", "text/plain", strings.NewReader("hello"), false)
+		require.NoError(t, err, "the matching file is replaced without overwrite")
+		require.Equal(t, "def", result.ETag)
+
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(stored("def"), nil)
+		_, err = service.Create(ctx, 1, 5, "test.txt", "path", "text/plain", strings.NewReader("hello"), true)
+		require.Equal(t, ErrPreconditionFailed, err)
+
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{})
+		_, err = service.Create(ctx, 1, 5, "test.txt", "path", "text/plain", strings.NewReader("hello"), true)
+		require.Equal(t, ErrPreconditionFailed, err, "missing files never match")
+	})
+
+	t.Run("move", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(stored("abc"), nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				require.Equal(t, `"abc"`, *input.CopySourceIfMatch)
+				return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+			})
+
+		_, err := service.Move(ctx, 1, "1/path/test.txt", "new/test.txt", true)
+		require.Equal(t, ErrPreconditionFailed, err, "changed after it was read")
+
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(stored("def"), nil)
+		_, err = service.Move(ctx, 1, "1/path/test.txt", "new/test.txt", true)
+		require.Equal(t, ErrPreconditionFailed, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(stored("def"), nil)
+		require.Equal(t, ErrPreconditionFailed, service.Delete(ctx, "1/path/test.txt"))
+
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(stored("abc"), nil)
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+		require.NoError(t, service.Delete(ctx, "1/path/test.txt"))
+	})
+}
+
+func TestS3service_Idempotency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := NewS3Service(s3Mock, WithIdempotency(idempotency.NewMemory(), time.Hour))
+	ctx := WithIdempotencyKey(context.Background(), "retry-1")
+	upload := func(ctx context.Context, name string) (*entity.File, error) {
+		return service.Create(ctx, 1, 5, name, "path", "text/plain", strings.NewReader("hello"), true)
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, errors.New("unavailable"))
+		_, err := upload(ctx, "test.txt")
+		require.Error(t, err)
+
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(&s3.PutObjectOutput{ETag: aws.String(`"abc"`)}, nil)
+		first, err := upload(ctx, "test.txt")
+		require.NoError(t, err, "failures are not replayed")
+
+		replayed, err := upload(ctx, "test.txt")
+		require.NoError(t, err)
+		require.Equal(t, first.ID, replayed.ID)
+		require.Equal(t, "abc", replayed.ETag)
+		require.True(t, first.CreatedAt.Equal(replayed.CreatedAt))
+
+		_, err = upload(ctx, "other.txt")
+		require.Equal(t, ErrIdempotencyKeyReused, err)
+
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		_, err = upload(auth.WithIdentity(ctx, auth.Identity{Name: "admin"}), "test.txt")
+		require.NoError(t, err, "keys of other clients are not replayed")
+
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		_, err = upload(anonymousFrom(t, ctx, "10.0.0.2"), "test.txt")
+		require.NoError(t, err, "keys of anonymous clients on other ips are not replayed")
+
+		_, err = upload(WithIdempotencyKey(ctx, strings.Repeat("a", 256)), "test.txt")
+		require.Equal(t, ErrInvalidIdempotencyKey, err)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Metadata: map[string]string{"created_at": time.Now().Format(time.RFC3339)}}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		for i := 0; i < 2; i++ {
+			result, err := service.Move(ctx, 1, "1/path/test.txt", "new/test.txt", true)
+			require.NoError(t, err, "the key of uploads is not the key of moves")
+			require.Equal(t, "1/new/test.txt", result.ID)
+		}
+	})
+
+	t.Run("concurrent retries", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				time.Sleep(10 * time.Millisecond)
+				return nil, nil
+			})
+
+		ctx := WithIdempotencyKey(context.Background(), "retry-2")
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := upload(ctx, "test.txt")
+				require.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestS3service_Index(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	indexMock := mocks.NewMockIndex(ctrl)
+	service := s3service{client: s3Mock, index: indexMock}
+
+	t.Run("create indexes file and content", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				body, err := io.ReadAll(input.Body)
+				require.NoError(t, err)
+				require.Equal(t, "bla bla", string(body))
+				return nil, nil
+			})
+		indexMock.EXPECT().Put(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, file *entity.File) error {
+				require.Equal(t, "1/path/test.txt", file.ID)
+				require.Equal(t, "text/plain; charset=utf-8", file.ContentType)
+				return nil
+			})
+		indexMock.EXPECT().PutContent(gomock.Any(), "1/path/test.txt", "bla bla").Return(nil)
+
+		_, err := service.Create(ctx, 1, 12, "test.txt", "path", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+	})
+
+	t.Run("create does not index binary content", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		indexMock.EXPECT().Put(gomock.Any(), gomock.Any()).Return(nil)
+		indexMock.EXPECT().PutContent(gomock.Any(), "1/path/test.png", "").Return(nil)
+
+		_, err := service.Create(ctx, 1, 12, "test.png", "path", "image/png", bytes.NewReader([]byte{0x89}), true)
+		require.NoError(t, err)
+	})
+
+	t.Run("index errors do not fail delete", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+		indexMock.EXPECT().Delete(gomock.Any(), "1/path/test.txt").Return(errors.New(""))
+
+		require.NoError(t, service.Delete(ctx, "1/path/test.txt"))
+	})
+
+	t.Run("move replaces indexed file", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{
+				Metadata:      map[string]string{"created_at": time.Now().Format(time.RFC3339)},
+				ContentLength: 15,
+			}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+		indexMock.EXPECT().Delete(gomock.Any(), "1/path/test.txt").Return(nil)
+		indexMock.EXPECT().Move(gomock.Any(), "1/path/test.txt", gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, file *entity.File) error {
+				require.Equal(t, "1/newpath/new.txt", file.ID)
+				require.Equal(t, "newpath", file.Path)
+				require.Equal(t, "new.txt", file.Name)
+				require.Equal(t, 15, file.Size)
+				return nil
+			})
+
+		_, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/new.txt", true)
+		require.NoError(t, err)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		filter := index.Filter{Name: "test"}
+		indexMock.EXPECT().Search(gomock.Any(), filter, index.Sort{}, index.Page{}).
+			Return([]*entity.File{{ID: "1/path/test.txt"}}, 1, nil)
+
+		files, total, err := service.Search(ctx, filter, index.Sort{}, index.Page{})
+		require.NoError(t, err)
+		require.Equal(t, 1, total)
+		require.Len(t, files, 1)
+	})
+
+	t.Run("search content", func(t *testing.T) {
+		user := 1
+		indexMock.EXPECT().SearchContent(gomock.Any(), "bla", &user, index.DefaultPageSize).
+			Return([]index.Match{{File: &entity.File{ID: "1/path/test.txt"}, Snippet: "<mark>bla</mark>"}}, nil)
+
+		matches, err := service.SearchContent(ctx, "bla", &user)
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+	})
+
+	t.Run("search without index", func(t *testing.T) {
+		_, _, err := s3service{client: s3Mock}.Search(ctx, index.Filter{}, index.Sort{}, index.Page{})
+		require.Equal(t, ErrNoIndex, err)
+	})
+}
+
+func TestS3service_Ping(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := s3service{client: s3Mock}
+
+	t.Run("success", func(t *testing.T) {
+		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				require.Equal(t, config.BucketName, *input.Bucket)
+				require.Equal(t, int32(1), input.MaxKeys)
+				return &s3.ListObjectsV2Output{}, nil
+			})
+
+		require.NoError(t, service.Ping(ctx))
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).Return(nil, errors.New("connection refused"))
+		require.EqualError(t, service.Ping(ctx), "connection refused")
+	})
+}
+
+func TestS3service_Reindex(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	indexMock := mocks.NewMockIndex(ctrl)
+	service := s3service{client: s3Mock, index: indexMock}
+
+	t.Run("success", func(t *testing.T) {
+		key1, key2, invalid := "1/path/test.txt", "2/other.png", "invalid"
+		token := "next"
+		contentType := "text/plain"
+		createdAt := time.Now()
+
+		indexMock.EXPECT().Reset(ctx).Return(nil)
+		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				require.Nil(t, input.ContinuationToken)
+				return &s3.ListObjectsV2Output{
+					Contents:              []types.Object{{Key: &key1}, {Key: &invalid}},
+					IsTruncated:           true,
+					NextContinuationToken: &token,
+				}, nil
+			})
+		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+				require.Equal(t, token, *input.ContinuationToken)
+				return &s3.ListObjectsV2Output{Contents: []types.Object{{Key: &key2}}}, nil
+			})
+		s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).
+			Return(&s3.HeadObjectOutput{
+				Metadata:      map[string]string{"created_at": createdAt.Format(time.RFC3339)},
+				ContentLength: 15,
+				ContentType:   &contentType,
+				LastModified:  &createdAt,
+			}, nil)
+		s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).
+			Return(&s3.HeadObjectOutput{LastModified: &createdAt}, nil)
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("text content"))}, nil)
+		indexMock.EXPECT().Put(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, file *entity.File) error {
+				require.Equal(t, key1, file.ID)
+				require.Equal(t, contentType, file.ContentType)
+				require.Equal(t, 15, file.Size)
+				return nil
+			})
+		indexMock.EXPECT().PutContent(gomock.Any(), key1, "text content").Return(nil)
+		indexMock.EXPECT().Put(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, file *entity.File) error {
+				require.Equal(t, key2, file.ID)
+				require.Equal(t, createdAt, file.CreatedAt)
+				return nil
+			})
+		indexMock.EXPECT().PutContent(gomock.Any(), key2, "").Return(nil)
+
+		count, err := service.Reindex(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("list error", func(t *testing.T) {
+		indexMock.EXPECT().Reset(ctx).Return(nil)
+		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).Return(nil, errors.New(""))
+
+		_, err := service.Reindex(ctx)
+		require.Error(t, err)
+	})
+
+	t.Run("without index", func(t *testing.T) {
+		_, err := s3service{client: s3Mock}.Reindex(ctx)
+		require.Equal(t, ErrNoIndex, err)
+	})
+}
+
+func TestS3service_Scan(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	scannerMock := mocks.NewMockScanner(ctrl)
+	service := s3service{client: s3Mock, scanner: scannerMock, jobs: &sync.WaitGroup{}}
+
+	expectQuarantine := func() {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Key)
+				require.Empty(t, input.ACL)
+				require.Equal(t, "PENDING", input.Metadata["scan_status"])
+				return nil, nil
+			})
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Key)
+				return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("bla bla"))}, nil
+			})
+	}
+
+	t.Run("clean file leaves quarantine", func(t *testing.T) {
+		expectQuarantine()
+		scannerMock.EXPECT().Scan(gomock.Any(), gomock.Any()).Return(scanner.Result{}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				require.Equal(t, "fileapi/quarantine/1/path/test.txt", *input.CopySource)
+				require.Equal(t, "1/path/test.txt", *input.Key)
+				require.Equal(t, types.ObjectCannedACLPublicRead, input.ACL)
+				require.Equal(t, types.MetadataDirectiveReplace, input.MetadataDirective)
+				require.Equal(t, "CLEAN", input.Metadata["scan_status"])
+				return nil, nil
+			})
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Delete.Objects[0].Key)
+				return nil, nil
+			})
+
+		result, err := service.Create(ctx, 1, 7, "test.txt", "path", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+		require.Equal(t, "1/path/test.txt", result.ID)
+		require.Equal(t, entity.ScanPending, result.ScanStatus)
+		require.NoError(t, service.Close(ctx))
+	})
+
+	t.Run("infected file stays in quarantine", func(t *testing.T) {
+		expectQuarantine()
+		scannerMock.EXPECT().Scan(gomock.Any(), gomock.Any()).Return(scanner.Result{Infected: true, Signature: "Eicar"}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Key)
+				require.Empty(t, input.ACL)
+				require.Equal(t, "INFECTED", input.Metadata["scan_status"])
+				return nil, nil
+			})
+
+		_, err := service.Create(ctx, 1, 7, "test.txt", "path", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+		require.NoError(t, service.Close(ctx))
+	})
+
+	t.Run("scan error flags file as failed", func(t *testing.T) {
+		expectQuarantine()
+		scannerMock.EXPECT().Scan(gomock.Any(), gomock.Any()).Return(scanner.Result{}, context.DeadlineExceeded)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				require.NoError(t, ctx.Err())
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Key)
+				require.Empty(t, input.ACL)
+				require.Equal(t, "FAILED", input.Metadata["scan_status"])
+				return nil, nil
+			})
+
+		_, err := service.Create(ctx, 1, 7, "test.txt", "path", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+		require.NoError(t, service.Close(ctx))
+	})
+
+	t.Run("get falls back to quarantine", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{})
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Key)
+				return &s3.GetObjectOutput{
+					Metadata: map[string]string{"created_at": time.Now().Format(time.RFC3339), "scan_status": "INFECTED"},
+				}, nil
+			})
+
+		result, err := service.Get(ctx, "1/path/test.txt")
+		require.NoError(t, err)
+		require.Equal(t, "1/path/test.txt", result.ID)
+		require.Equal(t, entity.ScanInfected, result.ScanStatus)
+	})
+
+	t.Run("quarantined files cannot move", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{
+				Metadata: map[string]string{"created_at": time.Now().Format(time.RFC3339), "scan_status": "PENDING"},
+			}, nil)
+
+		result, err := service.Move(ctx, 1, "1/path/test.txt", "newpath/test.txt", true)
+		require.Equal(t, ErrNotScanned, err)
+		require.Nil(t, result)
+	})
+
+	t.Run("delete removes quarantined copy", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+				require.Len(t, input.Delete.Objects, 2)
+				require.Equal(t, "1/path/test.txt", *input.Delete.Objects[0].Key)
+				require.Equal(t, "quarantine/1/path/test.txt", *input.Delete.Objects[1].Key)
+				return nil, nil
+			})
+
+		require.NoError(t, service.Delete(ctx, "1/path/test.txt"))
+	})
+}
+
+func TestS3service_Thumbnails(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := s3service{client: s3Mock, thumbnails: []int{16, 64}, jobs: &sync.WaitGroup{}}
+
+	var img bytes.Buffer
+	require.NoError(t, png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 32, 32))))
+
+	t.Run("create generates thumbnails", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(img.Bytes()))}, nil)
+
+		var keys []string
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Times(2).
+			DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				keys = append(keys, *input.Key)
+				require.Equal(t, "image/png", *input.ContentType)
+				return nil, nil
+			})
+
+		result, err := service.Create(ctx, 1, img.Len(), "cat.png", "pics", "image/png", bytes.NewReader(img.Bytes()), true)
+		require.NoError(t, err)
+		require.Equal(t, "image/png", result.ContentType)
+
+		require.NoError(t, service.Close(ctx))
+		require.Equal(t, []string{"1/pics/.thumbnails/16/cat.png", "1/pics/.thumbnails/64/cat.png"}, keys)
+	})
+
+	t.Run("no thumbnails for other files", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		_, err := service.Create(ctx, 1, 7, "notes.txt", "pics", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+		require.NoError(t, service.Close(ctx))
+	})
+
+	t.Run("reserved path", func(t *testing.T) {
+		_, err := service.Create(ctx, 1, 7, "cat.png", "pics/.thumbnails/16", "image/png", bytes.NewReader(img.Bytes()), true)
+		require.Equal(t, ErrReservedPath, err)
+
+		_, err = service.Move(ctx, 1, "1/pics/cat.png", ".thumbnails/cat.png", true)
+		require.Equal(t, ErrReservedPath, err)
+	})
+
+	t.Run("move copies thumbnails", func(t *testing.T) {
+		contentType := "image/png"
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{
+				Metadata:    map[string]string{"created_at": time.Now().Format(time.RFC3339)},
+				ContentType: &contentType,
+			}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+				require.Equal(t, "fileapi/1/pics/.thumbnails/16/cat.png", *input.CopySource)
+				require.Equal(t, "1/new/.thumbnails/16/cat.png", *input.Key)
+				return nil, nil
+			})
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).Return(nil, &types.NoSuchKey{})
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+				require.Len(t, input.Delete.Objects, 3)
+				require.Equal(t, "1/pics/cat.png", *input.Delete.Objects[0].Key)
+				require.Equal(t, "1/pics/.thumbnails/16/cat.png", *input.Delete.Objects[1].Key)
+				require.Equal(t, "1/pics/.thumbnails/64/cat.png", *input.Delete.Objects[2].Key)
+				return nil, nil
+			})
+
+		result, err := service.Move(ctx, 1, "1/pics/cat.png", "new/cat.png", true)
+		require.NoError(t, err)
+		require.Equal(t, "1/new/cat.png", result.ID)
+	})
+
+	t.Run("listing skips thumbnails", func(t *testing.T) {
+		key, thumb := "1/pics/cat.png", "1/pics/.thumbnails/16/cat.png"
+		s3Mock.EXPECT().ListObjectsV2(ctx, gomock.Any()).
+			Return(&s3.ListObjectsV2Output{Contents: []types.Object{{Key: &key}, {Key: &thumb}}}, nil)
+
+		result, err := service.GetByUser(ctx, 1, "pics")
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		require.Equal(t, key, result[0].ID)
+	})
+}
+
+func TestS3service_Events(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	service := s3service{client: s3Mock, events: event.NewBus()}
+	events := service.Subscribe(ctx)
+
+	t.Run("create", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		_, err := service.Create(ctx, 1, 7, "test.txt", "path", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+
+		e := <-events
+		require.Equal(t, event.Created, e.Type)
+		require.Equal(t, "1/path/test.txt", e.File.ID)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Metadata: map[string]string{"created_at": time.Now().Format(time.RFC3339)}}, nil)
+		s3Mock.EXPECT().CopyObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		_, err := service.Move(ctx, 1, "1/path/test.txt", "new/test.txt", true)
+		require.NoError(t, err)
+
+		e := <-events
+		require.Equal(t, event.Moved, e.Type)
+		require.Equal(t, "1/new/test.txt", e.File.ID)
+		require.Equal(t, "1/path/test.txt", e.OldID)
+		require.Empty(t, events)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		require.NoError(t, service.Delete(ctx, "1/new/test.txt"))
+
+		e := <-events
+		require.Equal(t, event.Deleted, e.Type)
+		require.Equal(t, entity.File{ID: "1/new/test.txt", User: 1, Path: "new", Name: "test.txt"}, e.File)
+	})
+
+	t.Run("failures are not published", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, errors.New(""))
+
+		require.Error(t, service.Delete(ctx, "1/new/test.txt"))
+		require.Empty(t, events)
+	})
+}
+
+func TestS3service_Webhooks(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	storeMock := mocks.NewMockStore(ctrl)
+	service := s3service{webhooks: storeMock}
+
+	t.Run("create", func(t *testing.T) {
+		hook := &webhook.Webhook{URL: "https://example.com/hook", Format: webhook.JSON}
+		storeMock.EXPECT().Create(gomock.Any(), hook).Return(nil)
+
+		require.NoError(t, service.CreateWebhook(ctx, hook))
+	})
+
+	t.Run("create invalid webhook", func(t *testing.T) {
+		err := service.CreateWebhook(ctx, &webhook.Webhook{URL: "file:///etc/passwd", Format: webhook.JSON})
+		require.Equal(t, ErrInvalidWebhook, err)
+	})
+
+	t.Run("delete not found", func(t *testing.T) {
+		storeMock.EXPECT().Delete(gomock.Any(), int64(1)).Return(webhook.ErrNotFound)
+
+		require.Equal(t, ErrNotFound, service.DeleteWebhook(ctx, 1))
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		filter := webhook.DeliveryFilter{Status: webhook.Failed}
+		storeMock.EXPECT().Deliveries(gomock.Any(), filter).Return([]*webhook.Delivery{{ID: 1}}, nil)
+
+		deliveries, err := service.WebhookDeliveries(ctx, filter)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+	})
+
+	t.Run("events are queued for delivery", func(t *testing.T) {
+		s3Mock := mocks.NewMockS3Client(ctrl)
+		service := s3service{client: s3Mock, webhooks: storeMock, dispatcher: webhook.NewDispatcher(storeMock, nil)}
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+		storeMock.EXPECT().List(gomock.Any()).Return([]*webhook.Webhook{{ID: 1, Format: webhook.JSON}}, nil)
+		storeMock.EXPECT().Enqueue(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, deliveries []*webhook.Delivery) error {
+				require.Len(t, deliveries, 1)
+				require.Equal(t, int64(1), deliveries[0].WebhookID)
+				require.Equal(t, event.Deleted, deliveries[0].Event)
+				require.Equal(t, "1/path/test.txt", deliveries[0].FileID)
+				return nil
+			})
+
+		require.NoError(t, service.Delete(ctx, "1/path/test.txt"))
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		service := s3service{}
+
+		_, err := service.Webhooks(ctx)
+		require.Equal(t, ErrNoWebhooks, err)
+		require.Equal(t, ErrNoWebhooks, service.DeleteWebhook(ctx, 1))
+	})
+}
+
+func TestS3service_Audit(t *testing.T) {
+	ctx := auth.WithIdentity(context.Background(), auth.Identity{Name: "alice", Admin: true})
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	auditMock := mocks.NewMockReader(ctrl)
+	service := s3service{client: s3Mock, audit: auditMock}
+
+	t.Run("upload", func(t *testing.T) {
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).Return(nil, nil)
+		auditMock.EXPECT().Write(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry *audit.Entry) error {
+				require.Equal(t, audit.Upload, entry.Action)
+				require.Equal(t, "alice", entry.Actor)
+				require.Equal(t, 1, entry.User)
+				require.Empty(t, entry.OldKey)
+				require.Equal(t, "1/path/test.txt", entry.NewKey)
+				require.Equal(t, audit.Success, entry.Result)
+				require.False(t, entry.Time.IsZero())
+				return nil
+			})
+
+		_, err := service.Create(ctx, 1, 7, "test.txt", "path", "text/plain", strings.NewReader("bla bla"), true)
+		require.NoError(t, err)
+	})
+
+	t.Run("failed move", func(t *testing.T) {
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).Return(nil, errors.New("unavailable"))
+		auditMock.EXPECT().Write(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry *audit.Entry) error {
+				require.Equal(t, audit.Move, entry.Action)
+				require.Equal(t, "1/path/test.txt", entry.OldKey)
+				require.Equal(t, "2/new/test.txt", entry.NewKey)
+				require.Equal(t, audit.Failure, entry.Result)
+				require.Equal(t, "unavailable", entry.Error)
+				return nil
+			})
+
+		_, err := service.Move(ctx, 2, "1/path/test.txt", "new/test.txt", true)
+		require.Error(t, err)
+	})
+
+	t.Run("audit errors do not fail delete", func(t *testing.T) {
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, nil)
+		auditMock.EXPECT().Write(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry *audit.Entry) error {
+				require.Equal(t, audit.Delete, entry.Action)
+				require.Equal(t, 1, entry.User)
+				require.Equal(t, "1/path/test.txt", entry.OldKey)
+				return errors.New("disk full")
+			})
+
+		require.NoError(t, service.Delete(ctx, "1/path/test.txt"))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		filter := audit.Filter{Action: audit.Delete}
+		auditMock.EXPECT().List(gomock.Any(), filter).Return([]*audit.Entry{{ID: 1}}, nil)
+
+		entries, err := service.AuditLog(ctx, filter)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("write only sink", func(t *testing.T) {
+		service := s3service{audit: mocks.NewMockSink(ctrl)}
+
+		_, err := service.AuditLog(ctx, audit.Filter{})
+		require.Equal(t, ErrNoAuditLog, err)
+	})
+}
+
+func TestS3service_Close(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("waits for background jobs", func(t *testing.T) {
+		service := s3service{jobs: &sync.WaitGroup{}}
+		done := false
+		service.background(ctx, func(context.Context) {
+			time.Sleep(10 * time.Millisecond)
+			done = true
+		})
+
+		require.NoError(t, service.Close(ctx))
+		require.True(t, done)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		service := s3service{jobs: &sync.WaitGroup{}}
+		release := make(chan struct{})
+		defer close(release)
+		service.background(ctx, func(context.Context) { <-release })
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		require.Equal(t, context.Canceled, service.Close(ctx))
+	})
+}
+
 func TestParseKey(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		user, path, file, err := parseKey("1/path/test/parse/file.txt")
@@ -434,3 +1462,14 @@ func TestParseKey(t *testing.T) {
 		require.Equal(t, ErrInvalidKey, err)
 	})
 }
+
+// anonymousFrom is ctx of an anonymous request sent from ip
+func anonymousFrom(t *testing.T, ctx context.Context, ip string) context.Context {
+	request := httptest.NewRequest(http.MethodPost, "/graphql", nil).WithContext(ctx)
+	request.RemoteAddr = ip + ":1234"
+	middleware.RequestMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), request)
+
+	return ctx
+}
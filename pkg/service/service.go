@@ -5,13 +5,31 @@ import (
 	"context"
 	"io"
 
+	"github.com/rafaelrubbioli/fileapi/pkg/audit"
 	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/rafaelrubbioli/fileapi/pkg/index"
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
 )
 
 type Service interface {
 	Create(ctx context.Context, user, size int, name, path, contentType string, file io.Reader, overwrite bool) (*entity.File, error)
 	Get(ctx context.Context, id string) (*entity.File, error)
+	Download(ctx context.Context, id string) (*entity.File, io.ReadCloser, error)
 	GetByUser(ctx context.Context, user int, prefix string) ([]*entity.File, error)
 	Delete(ctx context.Context, key string) error
+	DeleteMany(ctx context.Context, keys []string) []error
 	Move(ctx context.Context, user int, id, newPath string, overwrite bool) (*entity.File, error)
+	Copy(ctx context.Context, user int, id, newPath string, overwrite bool) (*entity.File, error)
+	Subscribe(ctx context.Context) <-chan event.Event
+	Search(ctx context.Context, filter index.Filter, sort index.Sort, page index.Page) ([]*entity.File, int, error)
+	SearchContent(ctx context.Context, query string, user *int) ([]index.Match, error)
+	Reindex(ctx context.Context) (int, error)
+	CreateWebhook(ctx context.Context, hook *webhook.Webhook) error
+	DeleteWebhook(ctx context.Context, id int64) error
+	Webhooks(ctx context.Context) ([]*webhook.Webhook, error)
+	WebhookDeliveries(ctx context.Context, filter webhook.DeliveryFilter) ([]*webhook.Delivery, error)
+	AuditLog(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error)
+	Ping(ctx context.Context) error
+	Close(ctx context.Context) error
 }
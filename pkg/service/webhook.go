@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/webhook"
+)
+
+func (s s3service) CreateWebhook(ctx context.Context, hook *webhook.Webhook) error {
+	if s.webhooks == nil {
+		return ErrNoWebhooks
+	}
+
+	if err := hook.Validate(); err != nil {
+		return ErrInvalidWebhook
+	}
+
+	return s.webhooks.Create(ctx, hook)
+}
+
+func (s s3service) DeleteWebhook(ctx context.Context, id int64) error {
+	if s.webhooks == nil {
+		return ErrNoWebhooks
+	}
+
+	err := s.webhooks.Delete(ctx, id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s s3service) Webhooks(ctx context.Context) ([]*webhook.Webhook, error) {
+	if s.webhooks == nil {
+		return nil, ErrNoWebhooks
+	}
+
+	return s.webhooks.List(ctx)
+}
+
+func (s s3service) WebhookDeliveries(ctx context.Context, filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	if s.webhooks == nil {
+		return nil, ErrNoWebhooks
+	}
+
+	return s.webhooks.Deliveries(ctx, filter)
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rafaelrubbioli/fileapi/pkg/metrics"
+	"github.com/rafaelrubbioli/fileapi/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Instrument traces every call to client and records its latency and errors, along with the bytes sent and read
+func Instrument(client S3Client) S3Client {
+	return instrumented{client: client}
+}
+
+type instrumented struct {
+	client S3Client
+}
+
+func (i instrumented) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	size := bodySize(params)
+	ctx, done := observe(ctx, "PutObject", params.Bucket, params.Key)
+	output, err := i.client.PutObject(ctx, params, optFns...)
+	if err == nil {
+		metrics.UploadedBytes.Add(float64(size))
+	}
+
+	return output, done(err)
+}
+
+func (i instrumented) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	ctx, done := observe(ctx, "GetObject", params.Bucket, params.Key)
+	output, err := i.client.GetObject(ctx, params, optFns...)
+	if err == nil && output != nil && output.Body != nil {
+		output.Body = countingReader{ReadCloser: output.Body}
+	}
+
+	return output, done(err)
+}
+
+func (i instrumented) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	ctx, done := observe(ctx, "HeadObject", params.Bucket, params.Key)
+	output, err := i.client.HeadObject(ctx, params, optFns...)
+	return output, done(err)
+}
+
+func (i instrumented) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	ctx, done := observe(ctx, "ListObjectsV2", params.Bucket, params.Prefix)
+	output, err := i.client.ListObjectsV2(ctx, params, optFns...)
+	return output, done(err)
+}
+
+func (i instrumented) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	ctx, done := observe(ctx, "CopyObject", params.Bucket, params.Key)
+	output, err := i.client.CopyObject(ctx, params, optFns...)
+	return output, done(err)
+}
+
+func (i instrumented) DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	ctx, done := observe(ctx, "DeleteObjects", params.Bucket, nil)
+	output, err := i.client.DeleteObjects(ctx, params, optFns...)
+	return output, done(err)
+}
+
+// observe starts a span for a call to method, the returned function ends it and records its metrics
+func observe(ctx context.Context, method string, bucket, key *string) (context.Context, func(error) error) {
+	attributes := []attribute.KeyValue{attribute.String("rpc.method", method)}
+	if bucket != nil {
+		attributes = append(attributes, attribute.String("aws.s3.bucket", *bucket))
+	}
+
+	if key != nil {
+		attributes = append(attributes, attribute.String("aws.s3.key", *key))
+	}
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "S3."+method, attributes...)
+	return ctx, func(err error) error {
+		metrics.S3RequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.S3RequestErrors.WithLabelValues(method).Inc()
+		}
+
+		tracing.End(span, err)
+		return err
+	}
+}
+
+// bodySize is how many bytes PutObject will send, known from the content length or by seeking the body
+func bodySize(params *s3.PutObjectInput) int64 {
+	if params.ContentLength > 0 {
+		return params.ContentLength
+	}
+
+	seeker, ok := params.Body.(io.Seeker)
+	if !ok {
+		return 0
+	}
+
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+
+	if _, err := seeker.Seek(current, io.SeekStart); err != nil {
+		return 0
+	}
+
+	return end - current
+}
+
+// countingReader counts the bytes read from S3 as they are read, since bodies are not always read to the end
+type countingReader struct {
+	io.ReadCloser
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	metrics.DownloadedBytes.Add(float64(n))
+	return n, err
+}
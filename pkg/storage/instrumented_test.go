@@ -0,0 +1,96 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rafaelrubbioli/fileapi/pkg/metrics"
+	"github.com/rafaelrubbioli/fileapi/pkg/storage"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestInstrument(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s3Mock := mocks.NewMockS3Client(ctrl)
+	client := storage.Instrument(s3Mock)
+
+	t.Run("put counts uploaded bytes", func(t *testing.T) {
+		uploaded := testutil.ToFloat64(metrics.UploadedBytes)
+		body := strings.NewReader("bla bla")
+		s3Mock.EXPECT().PutObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+				content, err := io.ReadAll(input.Body)
+				require.NoError(t, err)
+				require.Equal(t, "bla bla", string(content), "body is not consumed before it is sent")
+				return &s3.PutObjectOutput{}, nil
+			})
+
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{Body: body})
+		require.NoError(t, err)
+		require.Equal(t, uploaded+7, testutil.ToFloat64(metrics.UploadedBytes))
+	})
+
+	t.Run("get counts bytes as they are read", func(t *testing.T) {
+		downloaded := testutil.ToFloat64(metrics.DownloadedBytes)
+		s3Mock.EXPECT().GetObject(gomock.Any(), gomock.Any()).
+			Return(&s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader("bla bla"))}, nil)
+
+		result, err := client.GetObject(ctx, &s3.GetObjectInput{})
+		require.NoError(t, err)
+
+		buffer := make([]byte, 3)
+		_, err = io.ReadFull(result.Body, buffer)
+		require.NoError(t, err)
+		require.NoError(t, result.Body.Close())
+		require.Equal(t, downloaded+3, testutil.ToFloat64(metrics.DownloadedBytes))
+	})
+
+	t.Run("errors are counted by method", func(t *testing.T) {
+		failures := testutil.ToFloat64(metrics.S3RequestErrors.WithLabelValues("DeleteObjects"))
+		s3Mock.EXPECT().DeleteObjects(gomock.Any(), gomock.Any()).Return(nil, errors.New("unavailable"))
+
+		_, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{})
+		require.Error(t, err)
+		require.Equal(t, failures+1, testutil.ToFloat64(metrics.S3RequestErrors.WithLabelValues("DeleteObjects")))
+		require.Zero(t, testutil.ToFloat64(metrics.S3RequestErrors.WithLabelValues("HeadObject")))
+	})
+
+	t.Run("calls are traced", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+		s3Mock.EXPECT().HeadObject(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, _ *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+				require.True(t, trace.SpanContextFromContext(ctx).IsValid(), "the span is passed down to the sdk")
+				return nil, errors.New("not found")
+			})
+
+		_, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("fileapi"), Key: aws.String("1/a.txt")})
+		require.Error(t, err)
+
+		spans := recorder.Ended()
+		require.Len(t, spans, 1)
+		require.Equal(t, "S3.HeadObject", spans[0].Name())
+		require.Equal(t, codes.Error, spans[0].Status().Code)
+		require.Contains(t, spans[0].Attributes(), attribute.String("aws.s3.key", "1/a.txt"))
+	})
+}
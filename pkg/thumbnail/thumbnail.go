@@ -0,0 +1,148 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Dir is the folder thumbnails are kept in, next to their original file
+const Dir = ".thumbnails"
+
+// maxPixels avoids decoding images that would take too much memory
+const maxPixels = 50_000_000
+
+var ErrTooLarge = errors.New("image is too large to generate thumbnails")
+
+var decoders = map[string]func(io.Reader) (image.Image, error){
+	"image/jpeg": jpeg.Decode,
+	"image/png":  png.Decode,
+	"image/gif":  gif.Decode,
+}
+
+var configDecoders = map[string]func(io.Reader) (image.Config, error){
+	"image/jpeg": jpeg.DecodeConfig,
+	"image/png":  png.DecodeConfig,
+	"image/gif":  gif.DecodeConfig,
+}
+
+type Thumbnail struct {
+	Size        int
+	ContentType string
+	Content     []byte
+}
+
+// Supported reports if thumbnails can be generated for contentType
+func Supported(contentType string) bool {
+	_, ok := decoders[contentType]
+	return ok
+}
+
+// Key is where the thumbnail of size for the file id is stored
+func Key(id string, size int) string {
+	return path.Join(path.Dir(id), Dir, strconv.Itoa(size), path.Base(id))
+}
+
+// IsThumbnail reports if key belongs to a generated thumbnail
+func IsThumbnail(key string) bool {
+	return strings.Contains("/"+key+"/", "/"+Dir+"/")
+}
+
+// Closest picks the smallest size that is at least as big as requested,
+// or the largest one if none is
+func Closest(sizes []int, requested int) int {
+	if len(sizes) == 0 {
+		return 0
+	}
+
+	sorted := append([]int(nil), sizes...)
+	sort.Ints(sorted)
+	for _, size := range sorted {
+		if size >= requested {
+			return size
+		}
+	}
+
+	return sorted[len(sorted)-1]
+}
+
+// Generate scales the image down so its longest side fits each size. Images
+// smaller than a size are kept as they are. Jpeg images stay jpeg while
+// every other format becomes png.
+func Generate(file io.Reader, contentType string, sizes []int) ([]Thumbnail, error) {
+	decode, ok := decoders[contentType]
+	if !ok {
+		return nil, image.ErrFormat
+	}
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := configDecoders[contentType](bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, err := decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	thumbnails := make([]Thumbnail, 0, len(sizes))
+	for _, size := range sizes {
+		thumbnail := Thumbnail{Size: size, ContentType: "image/png"}
+		if contentType == "image/jpeg" {
+			thumbnail.ContentType = contentType
+		}
+
+		var buffer bytes.Buffer
+		scaled := scale(img, size)
+		if thumbnail.ContentType == "image/jpeg" {
+			err = jpeg.Encode(&buffer, scaled, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buffer, scaled)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		thumbnail.Content = buffer.Bytes()
+		thumbnails = append(thumbnails, thumbnail)
+	}
+
+	return thumbnails, nil
+}
+
+func scale(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	if width >= height {
+		width, height = size, max(1, height*size/width)
+	} else {
+		width, height = max(1, width*size/height), size
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+	return scaled
+}
@@ -0,0 +1,91 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	return img
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("png", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, png.Encode(&buffer, newImage(200, 100)))
+
+		thumbnails, err := Generate(&buffer, "image/png", []int{50, 400})
+		require.NoError(t, err)
+		require.Len(t, thumbnails, 2)
+
+		small, err := png.Decode(bytes.NewReader(thumbnails[0].Content))
+		require.NoError(t, err)
+		require.Equal(t, "image/png", thumbnails[0].ContentType)
+		require.Equal(t, image.Rect(0, 0, 50, 25), small.Bounds())
+
+		large, err := png.Decode(bytes.NewReader(thumbnails[1].Content))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 200, 100), large.Bounds())
+	})
+
+	t.Run("jpeg stays jpeg", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buffer, newImage(60, 120), nil))
+
+		thumbnails, err := Generate(&buffer, "image/jpeg", []int{30})
+		require.NoError(t, err)
+		require.Equal(t, "image/jpeg", thumbnails[0].ContentType)
+
+		small, err := jpeg.Decode(bytes.NewReader(thumbnails[0].Content))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 15, 30), small.Bounds())
+	})
+
+	t.Run("gif becomes png", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, gif.Encode(&buffer, newImage(40, 40), nil))
+
+		thumbnails, err := Generate(&buffer, "image/gif", []int{10})
+		require.NoError(t, err)
+		require.Equal(t, "image/png", thumbnails[0].ContentType)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := Generate(bytes.NewReader(nil), "image/webp", []int{10})
+		require.Equal(t, image.ErrFormat, err)
+	})
+
+	t.Run("invalid image", func(t *testing.T) {
+		_, err := Generate(bytes.NewReader([]byte("not a png")), "image/png", []int{10})
+		require.Error(t, err)
+	})
+}
+
+func TestKey(t *testing.T) {
+	require.Equal(t, "1/pics/.thumbnails/128/cat.png", Key("1/pics/cat.png", 128))
+	require.Equal(t, "1/.thumbnails/64/cat.png", Key("1/cat.png", 64))
+	require.True(t, IsThumbnail(Key("1/pics/cat.png", 128)))
+	require.False(t, IsThumbnail("1/pics/my.thumbnails/cat.png"))
+}
+
+func TestClosest(t *testing.T) {
+	sizes := []int{512, 128}
+	require.Equal(t, 128, Closest(sizes, 10))
+	require.Equal(t, 512, Closest(sizes, 129))
+	require.Equal(t, 512, Closest(sizes, 4000))
+	require.Zero(t, Closest(nil, 10))
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TraceIDExtension is the response extension with the trace id of the operation
+const TraceIDExtension = "traceID"
+
+// Extension creates a span for every graphql operation with a child span for every resolver called
+type Extension struct{}
+
+func (Extension) ExtensionName() string {
+	return "Tracing"
+}
+
+func (Extension) Validate(_ graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	spanName := "graphql"
+	var attributes []attribute.KeyValue
+	if graphql.HasOperationContext(ctx) {
+		operationContext := graphql.GetOperationContext(ctx)
+		if operation := operationContext.Operation; operation != nil {
+			spanName = strings.TrimSpace(fmt.Sprintf("%s %s", operation.Operation, operation.Name))
+			attributes = append(attributes,
+				attribute.String("graphql.operation.type", string(operation.Operation)),
+				attribute.String("graphql.operation.name", operation.Name),
+			)
+		}
+	}
+
+	ctx, span := Start(ctx, spanName, attributes...)
+	response := next(ctx)
+	if response == nil {
+		span.End()
+		return nil
+	}
+
+	var err error
+	if len(response.Errors) > 0 {
+		err = response.Errors
+	}
+	End(span, err)
+
+	if traceID := TraceID(ctx); traceID != "" {
+		if response.Extensions == nil {
+			response.Extensions = map[string]interface{}{}
+		}
+
+		response.Extensions[TraceIDExtension] = traceID
+	}
+
+	return response
+}
+
+// InterceptField only traces fields with resolvers, plain fields would only add noise
+func (Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fieldContext := graphql.GetFieldContext(ctx)
+	if fieldContext == nil || !fieldContext.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := Start(ctx, fieldContext.Object+"."+fieldContext.Field.Name,
+		attribute.String("graphql.field.path", fieldContext.Path().String()),
+	)
+
+	result, err := next(ctx)
+	End(span, err)
+	return result, err
+}
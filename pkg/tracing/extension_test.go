@@ -0,0 +1,52 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/graphql"
+	mocks "github.com/rafaelrubbioli/fileapi/test/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestExtension(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	serviceMock := mocks.NewMockService(ctrl)
+	serviceMock.EXPECT().GetByUser(gomock.Any(), 1, "").Return([]*entity.File{{ID: "1/a.txt", User: 1, Name: "a.txt"}}, nil)
+	handler := graphql.NewHandler(serviceMock, nil, nil)
+
+	request := httptest.NewRequest(http.MethodPost, "/graphql/", strings.NewReader(`{"query": "query files { listUserFiles(user: 1) { id name } }"}`))
+	request.Header.Set("Content-Type", "application/json")
+	written := httptest.NewRecorder()
+	handler.Handle(written, request)
+
+	var response struct {
+		Extensions map[string]string `json:"extensions"`
+	}
+	require.NoError(t, json.Unmarshal(written.Body.Bytes(), &response))
+
+	spans := recorder.Ended()
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+
+	require.ElementsMatch(t, []string{"query files", "Query.listUserFiles"}, names, "plain fields are not traced")
+	require.Equal(t, spans[0].SpanContext().TraceID().String(), response.Extensions["traceID"])
+	require.Equal(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+}
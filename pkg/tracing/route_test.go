@@ -0,0 +1,40 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/rafaelrubbioli/fileapi/pkg/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRouteMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	r := chi.NewRouter()
+	r.Use(tracing.RouteMiddleware)
+	r.Route("/v1", func(r chi.Router) {
+		r.Get("/files/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/v1/files/MS9hLnR4dA==", nil)
+	ctx, span := tracing.Start(request.Context(), "GET")
+	r.ServeHTTP(httptest.NewRecorder(), request.WithContext(ctx))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "GET /v1/files/{id}", spans[0].Name())
+	require.Contains(t, spans[0].Attributes(), attribute.String("http.route", "/v1/files/{id}"))
+}
@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/rafaelrubbioli/fileapi/pkg/logging"
+)
+
+// MaxAttempts is how many times a delivery is tried before it is marked as failed
+const MaxAttempts = 8
+
+const (
+	pollInterval   = 5 * time.Second
+	requestTimeout = 10 * time.Second
+	retryDelay     = 30 * time.Second
+	maxRetryDelay  = time.Hour
+	batchSize      = 100
+	// maxResponseRead is how much of the response is read so the connection can be reused
+	maxResponseRead = 64 << 10
+)
+
+// Dispatcher turns events into deliveries on the store outbox and sends them,
+// retrying failures with exponential backoff. Deliveries are sent at least once,
+// receivers should use the event id in the payload to ignore duplicates.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	wake   chan struct{}
+}
+
+// NewDispatcher sends deliveries with client, or a client with a default timeout that only
+// connects to public addresses when it is nil
+func NewDispatcher(store Store, client *http.Client) *Dispatcher {
+	if client == nil {
+		// the address is checked once the name is resolved, so names pointing to internal hosts are refused too
+		dialer := &net.Dialer{Timeout: requestTimeout, Control: publicOnly}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+
+		client = &http.Client{
+			Transport: transport,
+			Timeout:   requestTimeout,
+			// a redirect is treated as a failed delivery instead of sending the payload somewhere else
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	return &Dispatcher{
+		store:  store,
+		client: client,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Run sends the deliveries on the outbox as they are due until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+
+		for {
+			attempted, err := d.Deliver(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					logging.Error(ctx, "failed to deliver webhooks", "error", err)
+				}
+				break
+			}
+
+			if attempted < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// Enqueue adds a delivery to the outbox for every webhook matching e
+func (d *Dispatcher) Enqueue(ctx context.Context, e event.Event) error {
+	webhooks, err := d.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	payloads := map[Format][]byte{}
+	var deliveries []*Delivery
+	for _, webhook := range webhooks {
+		if !webhook.Matches(e) {
+			continue
+		}
+
+		payload, ok := payloads[webhook.Format]
+		if !ok {
+			if payload, err = NewPayload(id, e, webhook.Format); err != nil {
+				return err
+			}
+
+			payloads[webhook.Format] = payload
+		}
+
+		deliveries = append(deliveries, &Delivery{
+			WebhookID: webhook.ID,
+			Event:     e.Type,
+			FileID:    e.File.ID,
+			Payload:   payload,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := d.store.Enqueue(ctx, deliveries); err != nil {
+		return err
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Deliver sends the deliveries that are due and returns how many were attempted
+func (d *Dispatcher) Deliver(ctx context.Context) (int, error) {
+	deliveries, err := d.store.Due(ctx, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		webhook, err := d.store.Get(ctx, delivery.WebhookID)
+		if errors.Is(err, ErrNotFound) {
+			// deleted along with its deliveries since they were listed
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		status, err := d.send(ctx, webhook, delivery)
+		attempted(delivery, status, err, time.Now())
+		if err := d.store.Update(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, webhook *Webhook, delivery *Delivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", contentType(webhook.Format))
+	request.Header.Set("User-Agent", "fileapi-webhook")
+	request.Header.Set(EventHeader, string(delivery.Event))
+	request.Header.Set(DeliveryHeader, fmt.Sprint(delivery.ID))
+	timestamp := time.Now().Unix()
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	if webhook.Secret != "" {
+		request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, delivery.Payload))
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(response.Body, maxResponseRead))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected response status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// publicOnly refuses connections to addresses that are not public
+func publicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !IsPublic(ip) {
+		return ErrPrivateURL
+	}
+
+	return nil
+}
+
+// attempted records the result of sending delivery at now, scheduling a retry if it failed
+func attempted(delivery *Delivery, status int, err error, now time.Time) {
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.LastError = ""
+	if err == nil {
+		delivery.Status = Delivered
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= MaxAttempts {
+		delivery.Status = Failed
+		return
+	}
+
+	delivery.NextAttemptAt = now.Add(backoff(delivery.Attempts))
+}
+
+// backoff is how long to wait before the next attempt, doubling after every failed attempt
+func backoff(attempts int) time.Duration {
+	delay := retryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	e := event.Event{
+		Type:  event.Moved,
+		File:  entity.File{ID: "1/images/cat.png", User: 1, Path: "images", Name: "cat.png", ContentType: "image/png", Size: 10},
+		OldID: "1/cat.png",
+		Time:  time.Now(),
+	}
+
+	t.Run("signed json", func(t *testing.T) {
+		store := newTestStore(t)
+		server, requests := newTestServer(t, http.StatusNoContent)
+		user := 1
+		require.NoError(t, store.Create(ctx, &Webhook{URL: server.URL, User: &user, PathPrefix: "images", Secret: "secret", Format: JSON}))
+
+		dispatcher := NewDispatcher(store, server.Client())
+		require.NoError(t, dispatcher.Enqueue(ctx, e))
+
+		attempted, err := dispatcher.Deliver(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, attempted)
+
+		received := <-requests
+		require.Equal(t, "application/json", received.header.Get("Content-Type"))
+		require.Equal(t, "MOVED", received.header.Get(EventHeader))
+		timestamp, err := strconv.ParseInt(received.header.Get(TimestampHeader), 10, 64)
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now(), time.Unix(timestamp, 0), time.Minute)
+		require.Equal(t, Sign("secret", timestamp, received.body), received.header.Get(SignatureHeader))
+
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(received.body, &payload))
+		require.Equal(t, "MOVED", payload["type"])
+		require.Equal(t, encodeID("1/cat.png"), payload["oldID"])
+		require.Equal(t, encodeID("1/images/cat.png"), payload["file"].(map[string]interface{})["id"])
+
+		deliveries, err := store.Deliveries(ctx, DeliveryFilter{})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, Delivered, deliveries[0].Status)
+		require.Equal(t, 1, deliveries[0].Attempts)
+		require.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+	})
+
+	t.Run("cloudevents", func(t *testing.T) {
+		store := newTestStore(t)
+		server, requests := newTestServer(t, http.StatusOK)
+		require.NoError(t, store.Create(ctx, &Webhook{URL: server.URL, Format: CloudEvents}))
+
+		dispatcher := NewDispatcher(store, server.Client())
+		require.NoError(t, dispatcher.Enqueue(ctx, e))
+		_, err := dispatcher.Deliver(ctx)
+		require.NoError(t, err)
+
+		received := <-requests
+		require.Equal(t, "application/cloudevents+json", received.header.Get("Content-Type"))
+		require.Empty(t, received.header.Get(SignatureHeader))
+
+		var payload map[string]interface{}
+		require.NoError(t, json.Unmarshal(received.body, &payload))
+		require.Equal(t, "1.0", payload["specversion"])
+		require.Equal(t, "com.rubbioli.fileapi.file.moved", payload["type"])
+		require.Equal(t, encodeID("1/images/cat.png"), payload["subject"])
+		require.NotEmpty(t, payload["id"])
+		require.NotNil(t, payload["data"])
+	})
+
+	t.Run("only matching webhooks", func(t *testing.T) {
+		store := newTestStore(t)
+		other := 2
+		require.NoError(t, store.Create(ctx, &Webhook{URL: "http://example.com", User: &other, Format: JSON}))
+		require.NoError(t, store.Create(ctx, &Webhook{URL: "http://example.com", PathPrefix: "docs", Format: JSON}))
+		require.NoError(t, store.Create(ctx, &Webhook{URL: "http://example.com", Events: []event.Type{event.Created}, Format: JSON}))
+		matching := &Webhook{URL: "http://example.com", Events: []event.Type{event.Created, event.Moved}, Format: JSON}
+		require.NoError(t, store.Create(ctx, matching))
+
+		require.NoError(t, NewDispatcher(store, nil).Enqueue(ctx, e))
+
+		deliveries, err := store.Deliveries(ctx, DeliveryFilter{})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, matching.ID, deliveries[0].WebhookID)
+		require.Equal(t, "1/images/cat.png", deliveries[0].FileID)
+	})
+
+	t.Run("failures are retried later", func(t *testing.T) {
+		store := newTestStore(t)
+		server, requests := newTestServer(t, http.StatusInternalServerError)
+		require.NoError(t, store.Create(ctx, &Webhook{URL: server.URL, Format: JSON}))
+
+		dispatcher := NewDispatcher(store, server.Client())
+		require.NoError(t, dispatcher.Enqueue(ctx, e))
+		_, err := dispatcher.Deliver(ctx)
+		require.NoError(t, err)
+		<-requests
+
+		deliveries, err := store.Deliveries(ctx, DeliveryFilter{})
+		require.NoError(t, err)
+		require.Equal(t, Pending, deliveries[0].Status)
+		require.Equal(t, 1, deliveries[0].Attempts)
+		require.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+		require.Equal(t, "unexpected response status 500", deliveries[0].LastError)
+		require.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+
+		attempted, err := dispatcher.Deliver(ctx)
+		require.NoError(t, err)
+		require.Zero(t, attempted, "not due yet")
+	})
+
+	t.Run("private addresses are refused", func(t *testing.T) {
+		store := newTestStore(t)
+		server, requests := newTestServer(t, http.StatusOK)
+		require.NoError(t, store.Create(ctx, &Webhook{URL: server.URL, Format: JSON}))
+
+		dispatcher := NewDispatcher(store, nil)
+		require.NoError(t, dispatcher.Enqueue(ctx, e))
+		_, err := dispatcher.Deliver(ctx)
+		require.NoError(t, err)
+		require.Empty(t, requests)
+
+		deliveries, err := store.Deliveries(ctx, DeliveryFilter{})
+		require.NoError(t, err)
+		require.Equal(t, Pending, deliveries[0].Status)
+		require.Contains(t, deliveries[0].LastError, ErrPrivateURL.Error())
+	})
+
+	t.Run("run", func(t *testing.T) {
+		store := newTestStore(t)
+		server, requests := newTestServer(t, http.StatusOK)
+		require.NoError(t, store.Create(ctx, &Webhook{URL: server.URL, Format: JSON}))
+
+		ctx, cancel := context.WithCancel(ctx)
+		dispatcher := NewDispatcher(store, server.Client())
+		done := make(chan struct{})
+		go func() {
+			dispatcher.Run(ctx)
+			close(done)
+		}()
+
+		require.NoError(t, dispatcher.Enqueue(ctx, e))
+		select {
+		case received := <-requests:
+			require.Equal(t, "MOVED", received.header.Get(EventHeader))
+		case <-time.After(time.Second):
+			t.Fatal("webhook was not delivered")
+		}
+
+		cancel()
+		<-done
+	})
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	require.Equal(t, "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54", Sign("secret", 1700000000, body))
+	require.NotEqual(t, Sign("secret", 1700000000, body), Sign("secret", 1700000001, body), "timestamp is signed")
+}
+
+func TestAttempted(t *testing.T) {
+	now := time.Now()
+
+	delivery := &Delivery{Status: Pending}
+	attempted(delivery, 0, errors.New("connection refused"), now)
+	require.Equal(t, Pending, delivery.Status)
+	require.Equal(t, now.Add(retryDelay), delivery.NextAttemptAt)
+
+	attempted(delivery, 502, errors.New("unexpected response status 502"), now)
+	require.Equal(t, now.Add(2*retryDelay), delivery.NextAttemptAt)
+
+	delivery.Attempts = MaxAttempts - 1
+	attempted(delivery, 0, errors.New("timeout"), now)
+	require.Equal(t, Failed, delivery.Status)
+	require.Equal(t, "timeout", delivery.LastError)
+
+	delivery = &Delivery{Status: Pending, LastError: "timeout"}
+	attempted(delivery, 200, nil, now)
+	require.Equal(t, Delivered, delivery.Status)
+	require.Empty(t, delivery.LastError)
+
+	require.Equal(t, maxRetryDelay, backoff(20))
+}
+
+func TestWebhook_Validate(t *testing.T) {
+	require.NoError(t, (&Webhook{URL: "https://example.com/hook", Format: JSON}).Validate())
+	require.Equal(t, ErrInvalidURL, (&Webhook{URL: "ftp://example.com", Format: JSON}).Validate())
+	require.Equal(t, ErrInvalidURL, (&Webhook{URL: "/hook", Format: JSON}).Validate())
+	require.Equal(t, ErrInvalidFormat, (&Webhook{URL: "https://example.com"}).Validate())
+	require.Equal(t, ErrPrivateURL, (&Webhook{URL: "http://localhost:8080", Format: JSON}).Validate())
+	require.Equal(t, ErrPrivateURL, (&Webhook{URL: "http://169.254.169.254/latest", Format: JSON}).Validate())
+	require.Equal(t, ErrPrivateURL, (&Webhook{URL: "http://10.0.0.1", Format: JSON}).Validate())
+	require.Equal(t, ErrPrivateURL, (&Webhook{URL: "http://[::1]", Format: JSON}).Validate())
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/config"
+	"github.com/rafaelrubbioli/fileapi/pkg/entity"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+)
+
+const (
+	SignatureHeader = "X-Fileapi-Signature"
+	TimestampHeader = "X-Fileapi-Timestamp"
+	EventHeader     = "X-Fileapi-Event"
+	DeliveryHeader  = "X-Fileapi-Delivery"
+
+	cloudEventsContentType = "application/cloudevents+json"
+	cloudEventsTypePrefix  = "com.rubbioli.fileapi.file."
+)
+
+type file struct {
+	// ID is the same identifier used by the graphql api
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	User       int       `json:"user"`
+	FileType   string    `json:"fileType"`
+	Size       int       `json:"size"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	ScanStatus string    `json:"scanStatus,omitempty"`
+}
+
+type data struct {
+	File  file   `json:"file"`
+	OldID string `json:"oldID,omitempty"`
+}
+
+type jsonPayload struct {
+	ID   string     `json:"id"`
+	Type event.Type `json:"type"`
+	Time time.Time  `json:"time"`
+	data
+}
+
+// cloudEvent is a CloudEvents 1.0 event in structured mode
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            data      `json:"data"`
+}
+
+// NewPayload builds the body sent for e, id identifies the event across every webhook it is sent to
+func NewPayload(id string, e event.Event, format Format) ([]byte, error) {
+	body := data{File: newFile(e.File)}
+	if e.OldID != "" {
+		body.OldID = encodeID(e.OldID)
+	}
+
+	if format == CloudEvents {
+		return json.Marshal(cloudEvent{
+			SpecVersion:     "1.0",
+			ID:              id,
+			Source:          config.BaseURL(),
+			Type:            cloudEventsTypePrefix + strings.ToLower(string(e.Type)),
+			Subject:         body.File.ID,
+			Time:            e.Time,
+			DataContentType: "application/json",
+			Data:            body,
+		})
+	}
+
+	return json.Marshal(jsonPayload{ID: id, Type: e.Type, Time: e.Time, data: body})
+}
+
+// Sign is the value of the signature header, the hex encoded HMAC-SHA256 using secret of the
+// timestamp header, a dot and body. Signing the timestamp lets receivers refuse replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func contentType(format Format) string {
+	if format == CloudEvents {
+		return cloudEventsContentType
+	}
+
+	return "application/json"
+}
+
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+func newFile(f entity.File) file {
+	return file{
+		ID:         encodeID(f.ID),
+		Name:       f.Name,
+		Path:       f.Path,
+		User:       f.User,
+		FileType:   f.ContentType,
+		Size:       f.Size,
+		CreatedAt:  f.CreatedAt,
+		UpdatedAt:  f.UpdatedAt,
+		ScanStatus: string(f.ScanStatus),
+	}
+}
+
+func encodeID(key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
@@ -0,0 +1,298 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS webhooks (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	url         TEXT NOT NULL,
+	events      TEXT NOT NULL,
+	user        INTEGER,
+	path_prefix TEXT NOT NULL,
+	secret      TEXT NOT NULL,
+	format      TEXT NOT NULL,
+	created_at  INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id      INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event           TEXT NOT NULL,
+	file_id         TEXT NOT NULL,
+	payload         BLOB NOT NULL,
+	status          TEXT NOT NULL,
+	attempts        INTEGER NOT NULL,
+	response_status INTEGER NOT NULL,
+	last_error      TEXT NOT NULL,
+	next_attempt_at INTEGER NOT NULL,
+	created_at      INTEGER NOT NULL,
+	updated_at      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (webhook_id);
+`
+
+const (
+	webhookColumns  = "id, url, events, user, path_prefix, secret, format, created_at"
+	deliveryColumns = "id, webhook_id, event, file_id, payload, status, attempts, response_status, last_error, next_attempt_at, created_at, updated_at"
+)
+
+func NewSQLite(db *sql.DB) (Store, error) {
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("failed to migrate webhooks: %w", err)
+	}
+
+	return sqlite{db: db}, nil
+}
+
+type sqlite struct {
+	db *sql.DB
+}
+
+func (s sqlite) Create(ctx context.Context, webhook *Webhook) error {
+	if webhook.CreatedAt.IsZero() {
+		webhook.CreatedAt = time.Now()
+	}
+
+	events := make([]string, 0, len(webhook.Events))
+	for _, eventType := range webhook.Events {
+		events = append(events, string(eventType))
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhooks (url, events, user, path_prefix, secret, format, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		webhook.URL, strings.Join(events, ","), webhook.User, webhook.PathPrefix, webhook.Secret,
+		webhook.Format, webhook.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+
+	webhook.ID, err = result.LastInsertId()
+	return err
+}
+
+func (s sqlite) Get(ctx context.Context, id int64) (*Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrNotFound
+	}
+
+	return scanWebhook(rows)
+}
+
+func (s sqlite) List(ctx context.Context) ([]*Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s sqlite) Delete(ctx context.Context, id int64) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		// deleted explicitly so it doesn't depend on foreign keys being enabled
+		if _, err := tx.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err == nil && deleted == 0 {
+			return ErrNotFound
+		}
+
+		return err
+	})
+}
+
+func (s sqlite) Enqueue(ctx context.Context, deliveries []*Delivery) error {
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		for _, delivery := range deliveries {
+			now := time.Now()
+			if delivery.Status == "" {
+				delivery.Status = Pending
+			}
+
+			if delivery.NextAttemptAt.IsZero() {
+				delivery.NextAttemptAt = now
+			}
+
+			delivery.CreatedAt, delivery.UpdatedAt = now, now
+			result, err := tx.ExecContext(ctx, `
+				INSERT INTO webhook_deliveries (webhook_id, event, file_id, payload, status, attempts, response_status, last_error, next_attempt_at, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				delivery.WebhookID, delivery.Event, delivery.FileID, delivery.Payload, delivery.Status, delivery.Attempts,
+				delivery.ResponseStatus, delivery.LastError, delivery.NextAttemptAt.UnixNano(), now.UnixNano(), now.UnixNano(),
+			)
+			if err != nil {
+				return err
+			}
+
+			if delivery.ID, err = result.LastInsertId(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s sqlite) Due(ctx context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	return s.queryDeliveries(ctx, `
+		SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?`,
+		Pending, now.UnixNano(), limit,
+	)
+}
+
+func (s sqlite) Update(ctx context.Context, delivery *Delivery) error {
+	delivery.UpdatedAt = time.Now()
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_status = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.LastError,
+		delivery.NextAttemptAt.UnixNano(), delivery.UpdatedAt.UnixNano(), delivery.ID,
+	)
+	return err
+}
+
+func (s sqlite) Deliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if filter.WebhookID != nil {
+		conditions = append(conditions, "webhook_id = ?")
+		args = append(args, *filter.WebhookID)
+	}
+
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+
+	return s.queryDeliveries(ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries"+where+" ORDER BY id DESC LIMIT ?",
+		append(args, filter.Limit)...,
+	)
+}
+
+func (s sqlite) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]*Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*Delivery{}
+	for rows.Next() {
+		var (
+			delivery                            Delivery
+			nextAttemptAt, createdAt, updatedAt int64
+		)
+
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.FileID, &delivery.Payload, &delivery.Status,
+			&delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError, &nextAttemptAt, &createdAt, &updatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.NextAttemptAt = time.Unix(0, nextAttemptAt)
+		delivery.CreatedAt = time.Unix(0, createdAt)
+		delivery.UpdatedAt = time.Unix(0, updatedAt)
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (s sqlite) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanWebhook(rows *sql.Rows) (*Webhook, error) {
+	var (
+		webhook   Webhook
+		events    string
+		user      sql.NullInt64
+		createdAt int64
+	)
+
+	err := rows.Scan(&webhook.ID, &webhook.URL, &events, &user, &webhook.PathPrefix, &webhook.Secret, &webhook.Format, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if events != "" {
+		for _, eventType := range strings.Split(events, ",") {
+			webhook.Events = append(webhook.Events, event.Type(eventType))
+		}
+	}
+
+	if user.Valid {
+		value := int(user.Int64)
+		webhook.User = &value
+	}
+
+	webhook.CreatedAt = time.Unix(0, createdAt)
+	return &webhook, nil
+}
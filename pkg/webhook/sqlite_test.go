@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/database"
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) Store {
+	db, err := database.Open(database.Memory)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewSQLite(db)
+	require.NoError(t, err)
+	return store
+}
+
+func TestSQLite_Webhooks(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	user := 1
+	webhook := &Webhook{
+		URL:        "https://example.com/hook",
+		Events:     []event.Type{event.Created, event.Deleted},
+		User:       &user,
+		PathPrefix: "images",
+		Secret:     "secret",
+		Format:     CloudEvents,
+	}
+	require.NoError(t, store.Create(ctx, webhook))
+	require.NotZero(t, webhook.ID)
+
+	all := &Webhook{URL: "http://example.com/all", Format: JSON}
+	require.NoError(t, store.Create(ctx, all))
+
+	t.Run("get", func(t *testing.T) {
+		result, err := store.Get(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, webhook.URL, result.URL)
+		require.Equal(t, webhook.Events, result.Events)
+		require.Equal(t, &user, result.User)
+		require.Equal(t, "images", result.PathPrefix)
+		require.Equal(t, "secret", result.Secret)
+		require.Equal(t, CloudEvents, result.Format)
+		require.True(t, webhook.CreatedAt.Equal(result.CreatedAt))
+
+		_, err = store.Get(ctx, 100)
+		require.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		result, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		require.Nil(t, result[1].User)
+		require.Empty(t, result[1].Events)
+	})
+
+	t.Run("delete removes deliveries", func(t *testing.T) {
+		require.NoError(t, store.Enqueue(ctx, []*Delivery{{WebhookID: all.ID, Event: event.Created, FileID: "1/a.txt", Payload: []byte("{}")}}))
+		require.NoError(t, store.Delete(ctx, all.ID))
+
+		deliveries, err := store.Deliveries(ctx, DeliveryFilter{})
+		require.NoError(t, err)
+		require.Empty(t, deliveries)
+
+		require.Equal(t, ErrNotFound, store.Delete(ctx, all.ID))
+	})
+}
+
+func TestSQLite_Deliveries(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	first, second := &Webhook{URL: "http://a.com", Format: JSON}, &Webhook{URL: "http://b.com", Format: JSON}
+	require.NoError(t, store.Create(ctx, first))
+	require.NoError(t, store.Create(ctx, second))
+
+	now := time.Now()
+	deliveries := []*Delivery{
+		{WebhookID: first.ID, Event: event.Created, FileID: "1/a.txt", Payload: []byte(`{"a":1}`)},
+		{WebhookID: second.ID, Event: event.Deleted, FileID: "1/b.txt", Payload: []byte(`{"b":1}`), NextAttemptAt: now.Add(time.Hour)},
+		{WebhookID: second.ID, Event: event.Moved, FileID: "1/c.txt", Payload: []byte(`{"c":1}`)},
+	}
+	require.NoError(t, store.Enqueue(ctx, deliveries))
+	for _, delivery := range deliveries {
+		require.NotZero(t, delivery.ID)
+		require.Equal(t, Pending, delivery.Status)
+	}
+
+	t.Run("due", func(t *testing.T) {
+		due, err := store.Due(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		require.Equal(t, deliveries[0].ID, due[0].ID)
+		require.Equal(t, []byte(`{"a":1}`), due[0].Payload)
+		require.Equal(t, deliveries[2].ID, due[1].ID)
+
+		due, err = store.Due(ctx, now.Add(2*time.Hour), 1)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		delivery := deliveries[0]
+		delivery.Status = Delivered
+		delivery.Attempts = 2
+		delivery.ResponseStatus = 204
+		require.NoError(t, store.Update(ctx, delivery))
+
+		due, err := store.Due(ctx, time.Now(), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+
+		result, err := store.Deliveries(ctx, DeliveryFilter{Status: Delivered})
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		require.Equal(t, 2, result[0].Attempts)
+		require.Equal(t, 204, result[0].ResponseStatus)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		result, err := store.Deliveries(ctx, DeliveryFilter{WebhookID: &second.ID})
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		require.Equal(t, deliveries[2].ID, result[0].ID, "latest first")
+
+		result, err = store.Deliveries(ctx, DeliveryFilter{WebhookID: &second.ID, Status: Pending, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+	})
+}
@@ -0,0 +1,137 @@
+//go:generate go run -mod=mod github.com/golang/mock/mockgen -package=mocks -source=$GOFILE -destination=../../test/mock/webhook.go
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rafaelrubbioli/fileapi/pkg/event"
+)
+
+var (
+	ErrNotFound      = errors.New("webhook not found")
+	ErrInvalidURL    = errors.New("webhook url must be an absolute http or https url")
+	ErrPrivateURL    = errors.New("webhook url must point to a public address")
+	ErrInvalidFormat = errors.New("unknown webhook format")
+)
+
+type Format string
+
+const (
+	JSON        Format = "JSON"
+	CloudEvents Format = "CLOUDEVENTS"
+)
+
+type Status string
+
+const (
+	Pending   Status = "PENDING"
+	Delivered Status = "DELIVERED"
+	// Failed deliveries ran out of attempts and will not be retried
+	Failed Status = "FAILED"
+)
+
+// DefaultPageSize is how many deliveries are listed when no limit is given
+const DefaultPageSize = 50
+
+type Webhook struct {
+	ID  int64
+	URL string
+	// Events the webhook is sent for, empty means every event
+	Events []event.Type
+	// User only sends events for files of this user when set
+	User       *int
+	PathPrefix string
+	// Secret signs the payloads when set
+	Secret    string
+	Format    Format
+	CreatedAt time.Time
+}
+
+func (w *Webhook) Validate() error {
+	target, err := url.Parse(w.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return ErrInvalidURL
+	}
+
+	// names are only resolved when delivering, where the address they resolve to is checked again
+	host := target.Hostname()
+	if ip := net.ParseIP(host); strings.EqualFold(host, "localhost") || (ip != nil && !IsPublic(ip)) {
+		return ErrPrivateURL
+	}
+
+	if w.Format != JSON && w.Format != CloudEvents {
+		return ErrInvalidFormat
+	}
+
+	return nil
+}
+
+// Matches reports if e should be sent to the webhook
+func (w *Webhook) Matches(e event.Event) bool {
+	if !e.In(w.User, w.PathPrefix) {
+		return false
+	}
+
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, eventType := range w.Events {
+		if eventType == e.Type {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delivery is an attempt to send an event to a webhook, kept until it is delivered or runs out of attempts
+type Delivery struct {
+	ID        int64
+	WebhookID int64
+	Event     event.Type
+	FileID    string
+	// Payload is built once so every retry sends the same body
+	Payload  []byte
+	Status   Status
+	Attempts int
+	// ResponseStatus is the http status of the last attempt, zero if it got no response
+	ResponseStatus int
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type DeliveryFilter struct {
+	WebhookID *int64
+	Status    Status
+	Limit     int
+}
+
+// Store keeps webhooks and their outbox of deliveries
+type Store interface {
+	Create(ctx context.Context, webhook *Webhook) error
+	Get(ctx context.Context, id int64) (*Webhook, error)
+	List(ctx context.Context) ([]*Webhook, error)
+	// Delete removes the webhook along with its deliveries
+	Delete(ctx context.Context, id int64) error
+	Enqueue(ctx context.Context, deliveries []*Delivery) error
+	// Due lists pending deliveries whose next attempt is at or before now, oldest first
+	Due(ctx context.Context, now time.Time, limit int) ([]*Delivery, error)
+	Update(ctx context.Context, delivery *Delivery) error
+	// Deliveries lists the latest deliveries first
+	Deliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error)
+}
+
+// IsPublic reports if ip can be reached from the internet, webhooks are never sent to loopback,
+// private or link local addresses so they can't be used to reach internal hosts
+func IsPublic(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
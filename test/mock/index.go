@@ -0,0 +1,138 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: index.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	entity "github.com/rafaelrubbioli/fileapi/pkg/entity"
+	index "github.com/rafaelrubbioli/fileapi/pkg/index"
+)
+
+// MockIndex is a mock of Index interface.
+type MockIndex struct {
+	ctrl     *gomock.Controller
+	recorder *MockIndexMockRecorder
+}
+
+// MockIndexMockRecorder is the mock recorder for MockIndex.
+type MockIndexMockRecorder struct {
+	mock *MockIndex
+}
+
+// NewMockIndex creates a new mock instance.
+func NewMockIndex(ctrl *gomock.Controller) *MockIndex {
+	mock := &MockIndex{ctrl: ctrl}
+	mock.recorder = &MockIndexMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIndex) EXPECT() *MockIndexMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockIndex) Delete(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockIndexMockRecorder) Delete(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockIndex)(nil).Delete), ctx, id)
+}
+
+// Move mocks base method.
+func (m *MockIndex) Move(ctx context.Context, oldID string, file *entity.File) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Move", ctx, oldID, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Move indicates an expected call of Move.
+func (mr *MockIndexMockRecorder) Move(ctx, oldID, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Move", reflect.TypeOf((*MockIndex)(nil).Move), ctx, oldID, file)
+}
+
+// Put mocks base method.
+func (m *MockIndex) Put(ctx context.Context, file *entity.File) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Put", ctx, file)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Put indicates an expected call of Put.
+func (mr *MockIndexMockRecorder) Put(ctx, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Put", reflect.TypeOf((*MockIndex)(nil).Put), ctx, file)
+}
+
+// PutContent mocks base method.
+func (m *MockIndex) PutContent(ctx context.Context, id, content string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutContent", ctx, id, content)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutContent indicates an expected call of PutContent.
+func (mr *MockIndexMockRecorder) PutContent(ctx, id, content interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutContent", reflect.TypeOf((*MockIndex)(nil).PutContent), ctx, id, content)
+}
+
+// Reset mocks base method.
+func (m *MockIndex) Reset(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockIndexMockRecorder) Reset(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockIndex)(nil).Reset), ctx)
+}
+
+// Search mocks base method.
+func (m *MockIndex) Search(ctx context.Context, filter index.Filter, sort index.Sort, page index.Page) ([]*entity.File, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, filter, sort, page)
+	ret0, _ := ret[0].([]*entity.File)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockIndexMockRecorder) Search(ctx, filter, sort, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockIndex)(nil).Search), ctx, filter, sort, page)
+}
+
+// SearchContent mocks base method.
+func (m *MockIndex) SearchContent(ctx context.Context, query string, user *int, limit int) ([]index.Match, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchContent", ctx, query, user, limit)
+	ret0, _ := ret[0].([]index.Match)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchContent indicates an expected call of SearchContent.
+func (mr *MockIndexMockRecorder) SearchContent(ctx, query, user, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchContent", reflect.TypeOf((*MockIndex)(nil).SearchContent), ctx, query, user, limit)
+}
@@ -0,0 +1,52 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: scanner.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	io "io"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	scanner "github.com/rafaelrubbioli/fileapi/pkg/scanner"
+)
+
+// MockScanner is a mock of Scanner interface.
+type MockScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockScannerMockRecorder
+}
+
+// MockScannerMockRecorder is the mock recorder for MockScanner.
+type MockScannerMockRecorder struct {
+	mock *MockScanner
+}
+
+// NewMockScanner creates a new mock instance.
+func NewMockScanner(ctrl *gomock.Controller) *MockScanner {
+	mock := &MockScanner{ctrl: ctrl}
+	mock.recorder = &MockScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockScanner) EXPECT() *MockScannerMockRecorder {
+	return m.recorder
+}
+
+// Scan mocks base method.
+func (m *MockScanner) Scan(ctx context.Context, file io.Reader) (scanner.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", ctx, file)
+	ret0, _ := ret[0].(scanner.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockScannerMockRecorder) Scan(ctx, file interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockScanner)(nil).Scan), ctx, file)
+}
@@ -10,7 +10,11 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	audit "github.com/rafaelrubbioli/fileapi/pkg/audit"
 	entity "github.com/rafaelrubbioli/fileapi/pkg/entity"
+	event "github.com/rafaelrubbioli/fileapi/pkg/event"
+	index "github.com/rafaelrubbioli/fileapi/pkg/index"
+	webhook "github.com/rafaelrubbioli/fileapi/pkg/webhook"
 )
 
 // MockService is a mock of Service interface.
@@ -36,19 +40,77 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// AuditLog mocks base method.
+func (m *MockService) AuditLog(ctx context.Context, filter audit.Filter) ([]*audit.Entry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditLog", ctx, filter)
+	ret0, _ := ret[0].([]*audit.Entry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuditLog indicates an expected call of AuditLog.
+func (mr *MockServiceMockRecorder) AuditLog(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditLog", reflect.TypeOf((*MockService)(nil).AuditLog), ctx, filter)
+}
+
+// Close mocks base method.
+func (m *MockService) Close(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockServiceMockRecorder) Close(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockService)(nil).Close), ctx)
+}
+
+// Copy mocks base method.
+func (m *MockService) Copy(ctx context.Context, user int, id, newPath string, overwrite bool) (*entity.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Copy", ctx, user, id, newPath, overwrite)
+	ret0, _ := ret[0].(*entity.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Copy indicates an expected call of Copy.
+func (mr *MockServiceMockRecorder) Copy(ctx, user, id, newPath, overwrite interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Copy", reflect.TypeOf((*MockService)(nil).Copy), ctx, user, id, newPath, overwrite)
+}
+
 // Create mocks base method.
-func (m *MockService) Create(ctx context.Context, user, size int, name, path, contentType string, file io.Reader) (*entity.File, error) {
+func (m *MockService) Create(ctx context.Context, user, size int, name, path, contentType string, file io.Reader, overwrite bool) (*entity.File, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, user, size, name, path, contentType, file)
+	ret := m.ctrl.Call(m, "Create", ctx, user, size, name, path, contentType, file, overwrite)
 	ret0, _ := ret[0].(*entity.File)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockServiceMockRecorder) Create(ctx, user, size, name, path, contentType, file interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) Create(ctx, user, size, name, path, contentType, file, overwrite interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockService)(nil).Create), ctx, user, size, name, path, contentType, file)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockService)(nil).Create), ctx, user, size, name, path, contentType, file, overwrite)
+}
+
+// CreateWebhook mocks base method.
+func (m *MockService) CreateWebhook(ctx context.Context, hook *webhook.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, hook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockServiceMockRecorder) CreateWebhook(ctx, hook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockService)(nil).CreateWebhook), ctx, hook)
 }
 
 // Delete mocks base method.
@@ -65,6 +127,50 @@ func (mr *MockServiceMockRecorder) Delete(ctx, key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockService)(nil).Delete), ctx, key)
 }
 
+// DeleteMany mocks base method.
+func (m *MockService) DeleteMany(ctx context.Context, keys []string) []error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMany", ctx, keys)
+	ret0, _ := ret[0].([]error)
+	return ret0
+}
+
+// DeleteMany indicates an expected call of DeleteMany.
+func (mr *MockServiceMockRecorder) DeleteMany(ctx, keys interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMany", reflect.TypeOf((*MockService)(nil).DeleteMany), ctx, keys)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockService) DeleteWebhook(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockServiceMockRecorder) DeleteWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockService)(nil).DeleteWebhook), ctx, id)
+}
+
+// Download mocks base method.
+func (m *MockService) Download(ctx context.Context, id string) (*entity.File, io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Download", ctx, id)
+	ret0, _ := ret[0].(*entity.File)
+	ret1, _ := ret[1].(io.ReadCloser)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Download indicates an expected call of Download.
+func (mr *MockServiceMockRecorder) Download(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockService)(nil).Download), ctx, id)
+}
+
 // Get mocks base method.
 func (m *MockService) Get(ctx context.Context, id string) (*entity.File, error) {
 	m.ctrl.T.Helper()
@@ -96,16 +202,120 @@ func (mr *MockServiceMockRecorder) GetByUser(ctx, user, prefix interface{}) *gom
 }
 
 // Move mocks base method.
-func (m *MockService) Move(ctx context.Context, user int, id, newPath string) (*entity.File, error) {
+func (m *MockService) Move(ctx context.Context, user int, id, newPath string, overwrite bool) (*entity.File, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Move", ctx, user, id, newPath)
+	ret := m.ctrl.Call(m, "Move", ctx, user, id, newPath, overwrite)
 	ret0, _ := ret[0].(*entity.File)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Move indicates an expected call of Move.
-func (mr *MockServiceMockRecorder) Move(ctx, user, id, newPath interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) Move(ctx, user, id, newPath, overwrite interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Move", reflect.TypeOf((*MockService)(nil).Move), ctx, user, id, newPath, overwrite)
+}
+
+// Ping mocks base method.
+func (m *MockService) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockServiceMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockService)(nil).Ping), ctx)
+}
+
+// Reindex mocks base method.
+func (m *MockService) Reindex(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reindex", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reindex indicates an expected call of Reindex.
+func (mr *MockServiceMockRecorder) Reindex(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reindex", reflect.TypeOf((*MockService)(nil).Reindex), ctx)
+}
+
+// Search mocks base method.
+func (m *MockService) Search(ctx context.Context, filter index.Filter, sort index.Sort, page index.Page) ([]*entity.File, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, filter, sort, page)
+	ret0, _ := ret[0].([]*entity.File)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockServiceMockRecorder) Search(ctx, filter, sort, page interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockService)(nil).Search), ctx, filter, sort, page)
+}
+
+// SearchContent mocks base method.
+func (m *MockService) SearchContent(ctx context.Context, query string, user *int) ([]index.Match, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchContent", ctx, query, user)
+	ret0, _ := ret[0].([]index.Match)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchContent indicates an expected call of SearchContent.
+func (mr *MockServiceMockRecorder) SearchContent(ctx, query, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchContent", reflect.TypeOf((*MockService)(nil).SearchContent), ctx, query, user)
+}
+
+// Subscribe mocks base method.
+func (m *MockService) Subscribe(ctx context.Context) <-chan event.Event {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Subscribe", ctx)
+	ret0, _ := ret[0].(<-chan event.Event)
+	return ret0
+}
+
+// Subscribe indicates an expected call of Subscribe.
+func (mr *MockServiceMockRecorder) Subscribe(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockService)(nil).Subscribe), ctx)
+}
+
+// WebhookDeliveries mocks base method.
+func (m *MockService) WebhookDeliveries(ctx context.Context, filter webhook.DeliveryFilter) ([]*webhook.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookDeliveries", ctx, filter)
+	ret0, _ := ret[0].([]*webhook.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookDeliveries indicates an expected call of WebhookDeliveries.
+func (mr *MockServiceMockRecorder) WebhookDeliveries(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookDeliveries", reflect.TypeOf((*MockService)(nil).WebhookDeliveries), ctx, filter)
+}
+
+// Webhooks mocks base method.
+func (m *MockService) Webhooks(ctx context.Context) ([]*webhook.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webhooks", ctx)
+	ret0, _ := ret[0].([]*webhook.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Webhooks indicates an expected call of Webhooks.
+func (mr *MockServiceMockRecorder) Webhooks(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Move", reflect.TypeOf((*MockService)(nil).Move), ctx, user, id, newPath)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webhooks", reflect.TypeOf((*MockService)(nil).Webhooks), ctx)
 }